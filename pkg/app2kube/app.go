@@ -8,8 +8,11 @@ import (
 	"unicode"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	batch "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/yaml"
@@ -37,7 +40,9 @@ type IngressCommon struct {
 	Annotations   map[string]string `json:"annotations"`
 	Class         string            `json:"class"`
 	ClusterIssuer string            `json:"clusterIssuer"`
+	Gateway       ParentReference   `json:"gateway"`
 	Letsencrypt   bool              `json:"letsencrypt"`
+	Mode          string            `json:"mode"`
 	ServiceName   string            `json:"serviceName"`
 	ServicePort   int32             `json:"servicePort"`
 	SslRedirect   bool              `json:"sslRedirect"`
@@ -79,7 +84,7 @@ type CommonSpec struct {
 	GracePeriod              int64                       `json:"gracePeriod"`
 	Image                    ImageSpec                   `json:"image"`
 	Ingress                  IngressCommon               `json:"ingress"`
-	MountServiceAccountToken bool                        `json:"mountServiceAccountToken"`
+	MountServiceAccountToken *bool                       `json:"mountServiceAccountToken"`
 	NodeSelector             map[string]string           `json:"nodeSelector"`
 	PodAntiAffinity          string                      `json:"podAntiAffinity"`
 	Resources                *apiv1.ResourceRequirements `json:"resources"`
@@ -104,18 +109,129 @@ type CronjobSpec struct {
 	TimeZone                   string                     `json:"timeZone"`
 }
 
+// HookSpec is a single named hook Job: one container run to completion at a
+// fixed point of `apply`/`delete` (e.g. a database migration).
+type HookSpec struct {
+	ActiveDeadlineSeconds *int64          `json:"activeDeadlineSeconds"`
+	BackoffLimit          *int32          `json:"backoffLimit"`
+	Container             apiv1.Container `json:"container"`
+}
+
+// HooksSpec groups the app's hook Jobs by the phase they run in, each keyed by
+// hook name and run in sorted key order.
+type HooksSpec struct {
+	PostApply map[string]HookSpec `json:"postApply"`
+	PreApply  map[string]HookSpec `json:"preApply"`
+	PreDelete map[string]HookSpec `json:"preDelete"`
+}
+
 // DeploymentSpec is the App's deployment configuration.
 type DeploymentSpec struct {
+	Autoscaling             AutoscalingSpec            `json:"autoscaling"`
 	BlueGreenColor          string                     `json:"blueGreenColor"`
 	Containers              map[string]apiv1.Container `json:"containers"`
 	InitContainers          map[string]apiv1.Container `json:"initContainers"`
+	Kind                    string                     `json:"kind"`
 	ProgressDeadlineSeconds *int32                     `json:"progressDeadlineSeconds"`
 	ReplicaCount            *int32                     `json:"replicaCount"`
 	ReplicaCountStaging     int32                      `json:"replicaCountStaging"`
 	RevisionHistoryLimit    int32                      `json:"revisionHistoryLimit"`
+	StatefulSet             StatefulSetSpec            `json:"statefulSet"`
 	Strategy                appsv1.DeploymentStrategy  `json:"strategy"`
 }
 
+// WorkloadSpec is one named workload of the app (e.g. a queue worker next to
+// the web Deployment): a full deployment spec plus the pod settings it
+// overrides from common and the Services exposing it.
+type WorkloadSpec struct {
+	DeploymentSpec
+	NodeSelector map[string]string           `json:"nodeSelector"`
+	Resources    *apiv1.ResourceRequirements `json:"resources"`
+	Service      map[string]Service          `json:"service"`
+	Tolerations  []apiv1.Toleration          `json:"tolerations"`
+}
+
+// AutoscalingSpec configures the HorizontalPodAutoscaler of the app's
+// workload. The utilization targets are shorthands for the common Resource
+// metrics; Metrics takes any additional native autoscaling/v2 metric (Pods,
+// Object, External, ContainerResource).
+type AutoscalingSpec struct {
+	Behavior                          *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior"`
+	Enabled                           bool                                           `json:"enabled"`
+	MaxReplicas                       int32                                          `json:"maxReplicas"`
+	Metrics                           []autoscalingv2.MetricSpec                     `json:"metrics"`
+	MinReplicas                       *int32                                         `json:"minReplicas"`
+	TargetCPUUtilizationPercentage    *int32                                         `json:"targetCPUUtilizationPercentage"`
+	TargetMemoryUtilizationPercentage *int32                                         `json:"targetMemoryUtilizationPercentage"`
+}
+
+// StatefulSetSpec holds the settings that only apply when deployment.kind is
+// StatefulSet.
+type StatefulSetSpec struct {
+	PodManagementPolicy appsv1.PodManagementPolicyType   `json:"podManagementPolicy"`
+	UpdateStrategy      appsv1.StatefulSetUpdateStrategy `json:"updateStrategy"`
+}
+
+// NetworkPolicySpec configures the NetworkPolicy isolating the app's pods. The
+// allow shorthands cover the usual peers of a web app in a default-deny
+// namespace; any of them admits traffic only to the app's Service ports.
+type NetworkPolicySpec struct {
+	AllowDNS                   bool                      `json:"allowDNS"`
+	AllowFromApps              []string                  `json:"allowFromApps"`
+	AllowSameApp               bool                      `json:"allowSameApp"`
+	Egress                     []NetworkPolicyEgressRule `json:"egress"`
+	Enabled                    bool                      `json:"enabled"`
+	IngressControllerNamespace string                    `json:"ingressControllerNamespace"`
+}
+
+// NetworkPolicyEgressRule allows egress to a set of CIDRs (any destination when
+// empty) on a set of ports (any port when empty).
+type NetworkPolicyEgressRule struct {
+	CIDRs []string                         `json:"cidrs"`
+	Ports []networkingv1.NetworkPolicyPort `json:"ports"`
+}
+
+// ServiceAccountSpec controls the ServiceAccount app2kube creates for the app.
+type ServiceAccountSpec struct {
+	Annotations map[string]string `json:"annotations"`
+	Create      bool              `json:"create"`
+}
+
+// RBACSpec holds the API permissions granted to the app's ServiceAccount:
+// Rules in its own namespace (Role/RoleBinding), ClusterRules cluster-wide
+// (ClusterRole/ClusterRoleBinding).
+type RBACSpec struct {
+	ClusterRules []rbacv1.PolicyRule `json:"clusterRules"`
+	Rules        []rbacv1.PolicyRule `json:"rules"`
+}
+
+// CanarySpec configures the canary track deployed by `apply --canary`: the
+// share of traffic (or the header/cookie) nginx routes to it, and the weight
+// steps a progressive rollout walks through before an optional promotion.
+type CanarySpec struct {
+	AutoPromote  bool            `json:"autoPromote"`
+	Cookie       string          `json:"cookie"`
+	Header       string          `json:"header"`
+	HeaderValue  string          `json:"headerValue"`
+	Pause        metav1.Duration `json:"pause"`
+	ReplicaCount *int32          `json:"replicaCount"`
+	Steps        []int32         `json:"steps"`
+	Weight       *int32          `json:"weight"`
+}
+
+// MetricsSpec configures the Prometheus Operator monitor scraping the app: a
+// ServiceMonitor (the default) or a PodMonitor on the named port.
+type MetricsSpec struct {
+	Enabled       bool              `json:"enabled"`
+	Interval      string            `json:"interval"`
+	Kind          string            `json:"kind"`
+	Labels        map[string]string `json:"labels"`
+	Path          string            `json:"path"`
+	Port          string            `json:"port"`
+	Relabelings   []RelabelConfig   `json:"relabelings"`
+	ScrapeTimeout string            `json:"scrapeTimeout"`
+}
+
 // VolumeSpec is a single named persistent volume claim and its mount path.
 type VolumeSpec struct {
 	Spec      apiv1.PersistentVolumeClaimSpec `json:"spec"`
@@ -124,23 +240,35 @@ type VolumeSpec struct {
 
 // App instance
 type App struct {
-	aesPassword   string
-	rsaPublicKey  string
-	rsaPrivateKey string
-	Branch        string                 `json:"branch"`
-	Common        CommonSpec             `json:"common"`
-	ConfigMap     map[string]string      `json:"configmap"`
-	Cronjob       map[string]CronjobSpec `json:"cronjob"`
-	Deployment    DeploymentSpec         `json:"deployment"`
-	Env           map[string]string      `json:"env"`
-	Ingress       []Ingress              `json:"ingress"`
-	Labels        map[string]string      `json:"labels"`
-	Name          string                 `json:"name"`
-	Namespace     string                 `json:"namespace"`
-	Secrets       map[string]string      `json:"secrets"`
-	Service       map[string]Service     `json:"service"`
-	Staging       Staging                `json:"staging"`
-	Volumes       map[string]VolumeSpec  `json:"volumes"`
+	aesPassword    string
+	canary         bool
+	rsaPublicKey   string
+	rsaPrivateKey  string
+	lenientValues  bool
+	templatePolicy *templatePolicy
+	overlays       []string
+	workload       string
+	Branch         string                  `json:"branch"`
+	Canary         CanarySpec              `json:"canary"`
+	Common         CommonSpec              `json:"common"`
+	ConfigMap      map[string]string       `json:"configmap"`
+	Cronjob        map[string]CronjobSpec  `json:"cronjob"`
+	Deployment     DeploymentSpec          `json:"deployment"`
+	Env            map[string]string       `json:"env"`
+	Hooks          HooksSpec               `json:"hooks"`
+	Ingress        []Ingress               `json:"ingress"`
+	Labels         map[string]string       `json:"labels"`
+	Metrics        MetricsSpec             `json:"metrics"`
+	Name           string                  `json:"name"`
+	Namespace      string                  `json:"namespace"`
+	NetworkPolicy  NetworkPolicySpec       `json:"networkPolicy"`
+	RBAC           RBACSpec                `json:"rbac"`
+	Secrets        map[string]string       `json:"secrets"`
+	Service        map[string]Service      `json:"service"`
+	ServiceAccount ServiceAccountSpec      `json:"serviceAccount"`
+	Staging        Staging                 `json:"staging"`
+	Volumes        map[string]VolumeSpec   `json:"volumes"`
+	Workloads      map[string]WorkloadSpec `json:"workloads"`
 }
 
 // GetObjectMeta return App metadata. Annotations is left nil by default so
@@ -164,11 +292,22 @@ func (app *App) GetReleaseName() string {
 }
 
 // releaseName composes the raw, uncapped release name from Name/Staging/Branch,
-// lowercased. GetReleaseName caps it at the subdomain limit (253) and the Service
-// helper caps it at the stricter label limit (63); centralizing the composition
-// here means each resource's length rule applies exactly once instead of a
-// 253-cap being re-capped to 63.
+// lowercased, plus the canary suffix on the canary track (CanaryApp).
+// GetReleaseName caps it at the subdomain limit (253) and the Service helper
+// caps it at the stricter label limit (63); centralizing the composition here
+// means each resource's length rule applies exactly once instead of a 253-cap
+// being re-capped to 63.
 func (app *App) releaseName() string {
+	if app.canary {
+		return app.stableReleaseName() + canarySuffix
+	}
+	return app.stableReleaseName()
+}
+
+// stableReleaseName is releaseName without the canary suffix: the name of the
+// objects the canary track shares with the stable one (volume claims, the
+// ServiceAccount).
+func (app *App) stableReleaseName() string {
 	releaseName := app.Name
 	if app.Staging.Active {
 		// The branch (when set) identifies the release; otherwise the staging
@@ -186,25 +325,49 @@ func (app *App) releaseName() string {
 
 // GetDeploymentName of App. The Deployment and PDB object names are DNS-1123
 // subdomains, so they are capped at the subdomain limit (253) even when the
-// color suffix pushes a long release name past it.
+// color suffix pushes a long release name past it. A named workload
+// (WorkloadApps) is "<release>-<workload>".
 func (app *App) GetDeploymentName() string {
 	deploymentName := app.GetReleaseName()
+	if app.workload != "" {
+		deploymentName += "-" + app.workload
+	}
 	if app.Deployment.BlueGreenColor != "" {
 		deploymentName += "-" + app.Deployment.BlueGreenColor
 	}
 	return truncateNameTo(strings.ToLower(deploymentName), MaxSubdomainNameLength)
 }
 
+// IsStatefulSet reports whether the app's pods are rendered as a StatefulSet
+// (deployment.kind: StatefulSet) instead of a Deployment. The StatefulSet keeps
+// the Deployment's object name (GetDeploymentName) and selector, so the PDB,
+// Services and tracking address it the same way.
+func (app *App) IsStatefulSet() bool {
+	return strings.EqualFold(app.Deployment.Kind, KindStatefulSet)
+}
+
+// GetHeadlessServiceName returns the name of the governing headless Service a
+// StatefulSet requires, "<release>-headless", capped at the label limit like
+// every other Service name.
+func (app *App) GetHeadlessServiceName() string {
+	return truncateName(app.releaseName() + headlessServiceSuffix)
+}
+
 // GetServiceName returns the cluster Service name for a named service entry:
-// the release name when name is empty, otherwise "<release>-<name>" lowercased.
+// the release name when name is empty, otherwise "<release>-<name>" lowercased;
+// a named workload's Services are prefixed "<release>-<workload>" instead.
 // Exported alongside GetReleaseName/GetDeploymentName because Service names are
 // part of the public contract — Ingress backends reference them and callers may
 // want to predict them (#66).
 func (app *App) GetServiceName(name string) string {
+	base := app.releaseName()
+	if app.workload != "" {
+		base += "-" + app.workload
+	}
 	if name == "" {
-		return truncateName(app.releaseName())
+		return truncateName(base)
 	}
-	return truncateName(app.releaseName() + "-" + strings.ToLower(name))
+	return truncateName(base + "-" + strings.ToLower(name))
 }
 
 // GetVolumeClaimName returns the PersistentVolumeClaim name for a named volume,
@@ -214,7 +377,7 @@ func (app *App) GetServiceName(name string) string {
 // cannot drift to mismatched names — a mismatch would make pods fail to schedule
 // with "persistentvolumeclaim not found".
 func (app *App) GetVolumeClaimName(volName string) string {
-	return truncateNameTo(app.GetReleaseName()+"-"+volName, MaxSubdomainNameLength)
+	return truncateNameTo(app.stableReleaseName()+"-"+volName, MaxSubdomainNameLength)
 }
 
 // GetColorLabels returns a copy of the app labels, adding the blue/green color
@@ -222,12 +385,16 @@ func (app *App) GetVolumeClaimName(volName string) string {
 // Deployment/Service/PDB spec.selector, which therefore stay byte-identical to
 // what pre-v0.7 releases emitted — important because spec.selector is immutable
 // and a narrower selector would make `kubectl apply` reject upgrades of existing
-// Deployments.
+// Deployments. The pods of a named workload carry their own instance label
+// (workloadInstance) so the app's Services never select them.
 func (app *App) GetColorLabels() map[string]string {
 	labels := make(map[string]string, len(app.Labels))
 	for k, v := range app.Labels {
 		labels[k] = v
 	}
+	if app.workload != "" {
+		labels[LabelInstance] = app.workloadInstance(app.workload)
+	}
 	if app.Deployment.BlueGreenColor != "" {
 		labels[LabelColor] = app.Deployment.BlueGreenColor
 	}
@@ -296,16 +463,37 @@ func (app *App) getAffinity() (*apiv1.Affinity, error) {
 
 // LoadValues merges the given value sources, unmarshals them into the App and
 // applies validation and staging transformations. It returns the merged raw
-// YAML. The work is split into parse/validate/applyStaging so each step can be
-// understood and tested in isolation.
+// YAML. It is LoadValueSources for the -f, --set, --set-string and --set-file
+// sources.
 func (app *App) LoadValues(valueFiles ValueFiles, values, stringValues, fileValues []string) ([]byte, error) {
-	rawVals, err := app.parseValues(valueFiles, values, stringValues, fileValues)
+	return app.LoadValueSources(ValueSources{
+		Files:        valueFiles,
+		Values:       values,
+		StringValues: stringValues,
+		FileValues:   fileValues,
+	})
+}
+
+// LoadValueSources merges the given value sources, unmarshals them into the
+// App and applies validation and staging transformations. It returns the
+// merged raw YAML. The work is split into parse/validate/applyStaging so each
+// step can be understood and tested in isolation.
+func (app *App) LoadValueSources(src ValueSources) ([]byte, error) {
+	rawVals, err := app.parseValues(src)
 	if err != nil {
 		return nil, err
 	}
+	if err := app.finishLoad(); err != nil {
+		return nil, err
+	}
+	return rawVals, nil
+}
 
+// finishLoad validates the values unmarshaled into the App and applies the
+// defaults and the staging transformations.
+func (app *App) finishLoad() error {
 	if err := app.validate(); err != nil {
-		return nil, err
+		return err
 	}
 
 	app.ensureLabels()
@@ -320,23 +508,56 @@ func (app *App) LoadValues(valueFiles ValueFiles, values, stringValues, fileValu
 		app.Common.Image.Tag = "latest"
 	}
 
-	if err := app.applyStaging(); err != nil {
-		return nil, err
+	return app.applyStaging()
+}
+
+// SetSchemaValidation enables (the default) or disables the strict check of
+// the values LoadValues merges against ValuesSchema. Disabling it restores the
+// lenient behavior, where unknown keys are ignored, for legacy value files.
+func (app *App) SetSchemaValidation(enabled bool) {
+	app.lenientValues = !enabled
+}
+
+// SetSafeTemplates enables or disables safe template mode for the value files
+// LoadValues renders. Safe templates, for value files that are not trusted,
+// cannot read the environment or local files, resolve DNS or generate random
+// data; env is allowed only for the listed variables. A template using
+// anything else fails with the file, line and the refused function.
+func (app *App) SetSafeTemplates(enabled bool, allowedEnv ...string) {
+	app.templatePolicy = nil
+	if enabled {
+		app.templatePolicy = newTemplatePolicy(allowedEnv)
 	}
+}
 
-	return rawVals, nil
+// Overlays returns the environment overlay files the last LoadValueSources
+// merged.
+func (app *App) Overlays() []string {
+	return app.overlays
 }
 
 // parseValues merges the value sources and unmarshals them into the App.
-func (app *App) parseValues(valueFiles ValueFiles, values, stringValues, fileValues []string) ([]byte, error) {
-	rawVals, err := vals(valueFiles, values, stringValues, fileValues)
+// Unless SetSchemaValidation(false) was called, the merged values are first
+// checked against ValuesSchema, so a mistyped key fails the load instead of
+// being silently ignored by the lenient unmarshal.
+func (app *App) parseValues(src ValueSources) ([]byte, error) {
+	loaded, err := vals(src, app.templatePolicy)
 	if err != nil {
 		return nil, err
 	}
-	if err := yaml.Unmarshal(rawVals, &app); err != nil {
+	if err := loaded.requireApp(src); err != nil {
 		return nil, err
 	}
-	return rawVals, nil
+	app.overlays = loaded.overlays
+	if !app.lenientValues {
+		if err := validateValues(ValuesSchema(), loaded.values, loaded.origins); err != nil {
+			return nil, err
+		}
+	}
+	if err := yaml.Unmarshal(loaded.raw, &app); err != nil {
+		return nil, err
+	}
+	return loaded.raw, nil
 }
 
 // validate checks required fields after parsing.
@@ -344,7 +565,46 @@ func (app *App) validate() error {
 	if app.Name == "" {
 		return errors.New("app name is required")
 	}
-	return nil
+	switch {
+	case app.Deployment.Kind == "",
+		strings.EqualFold(app.Deployment.Kind, KindDeployment),
+		strings.EqualFold(app.Deployment.Kind, KindStatefulSet):
+	default:
+		return fmt.Errorf("unknown deployment.kind %q (must be %s or %s)", app.Deployment.Kind, KindDeployment, KindStatefulSet)
+	}
+	// An HPA with maxReplicas < 1 or minReplicas > maxReplicas is rejected by
+	// the apiserver; fail before anything is applied.
+	if as := app.Deployment.Autoscaling; as.Enabled {
+		if as.MaxReplicas < 1 {
+			return errors.New("deployment.autoscaling.maxReplicas must be at least 1")
+		}
+		if as.MinReplicas != nil && (*as.MinReplicas < 1 || *as.MinReplicas > as.MaxReplicas) {
+			return fmt.Errorf("deployment.autoscaling.minReplicas must be between 1 and maxReplicas (%d)", as.MaxReplicas)
+		}
+	}
+	switch {
+	case app.Common.Ingress.Mode == "",
+		strings.EqualFold(app.Common.Ingress.Mode, IngressModeIngress),
+		strings.EqualFold(app.Common.Ingress.Mode, IngressModeGateway):
+	default:
+		return fmt.Errorf("unknown common.ingress.mode %q (must be %s or %s)", app.Common.Ingress.Mode, IngressModeIngress, IngressModeGateway)
+	}
+	// The governing headless Service of a StatefulSet would share its name
+	// with the Service of a `headless` entry.
+	if app.IsStatefulSet() {
+		for _, name := range sortedKeys(app.Service) {
+			if app.GetServiceName(name) == app.GetHeadlessServiceName() {
+				return fmt.Errorf("service %q is named like the headless Service of the StatefulSet (%s): rename it", name, app.GetHeadlessServiceName())
+			}
+		}
+	}
+	if err := app.validateMetrics(); err != nil {
+		return err
+	}
+	if err := app.validateWorkloads(); err != nil {
+		return err
+	}
+	return app.validateCanary()
 }
 
 // applyStaging rewrites replica counts, instance labels, ingress hosts and
@@ -364,6 +624,9 @@ func (app *App) applyStaging() error {
 	app.Common.Image.PullPolicy = apiv1.PullAlways
 	app.Deployment.BlueGreenColor = ""
 	app.Deployment.RevisionHistoryLimit = 0
+	// A staging deploy runs the pinned replicaCountStaging; an HPA would
+	// immediately resize it.
+	app.Deployment.Autoscaling.Enabled = false
 	app.Staging.Name = sanitizeDNSName(app.Staging.Name)
 	app.Branch = sanitizeDNSName(app.Branch)
 
@@ -0,0 +1,226 @@
+package app2kube
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// appsKey lists the applications of multi-application values: each entry
+// is the values of one application, inline with its name, or a value file
+// or directory of value files named after their application. Every value
+// outside apps is shared by all of them.
+const appsKey = "apps"
+
+// appEntry is one application of multi-application values: its values
+// inline, from apps[index], or the value file it is read from.
+type appEntry struct {
+	name   string
+	index  int
+	values map[string]any
+	file   string
+}
+
+// appEntries returns the applications an apps value lists, in order, with
+// the value files of a directory entry sorted by name. A non-nil policy
+// refuses value file entries, which would read any local file.
+func appEntries(value any, policy *templatePolicy) ([]appEntry, error) {
+	var list []any
+	switch val := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		list = []any{val}
+	case []any:
+		list = val
+	default:
+		return nil, fmt.Errorf("%s: expected a list, got %s", appsKey, valueType(value))
+	}
+
+	var entries []appEntry
+	for i, item := range list {
+		where := appsKey + "[" + strconv.Itoa(i) + "]"
+		switch val := item.(type) {
+		case map[string]any:
+			name, ok := val["name"].(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("%s: an application needs a name", where)
+			}
+			entries = append(entries, appEntry{name: name, index: i, values: val})
+		case string:
+			if policy != nil {
+				return nil, fmt.Errorf("%s: application files are not allowed in safe template mode", where)
+			}
+			files, err := appFiles(val)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			for _, file := range files {
+				name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+				entries = append(entries, appEntry{name: name, index: i, file: file})
+			}
+		default:
+			return nil, fmt.Errorf("%s: expected an application or a file name, got %s", where, valueType(item))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if seen[entry.name] {
+			return nil, fmt.Errorf("%s: application %q is declared twice", appsKey, entry.name)
+		}
+		seen[entry.name] = true
+	}
+	return entries, nil
+}
+
+// appFiles returns the value file at path or, for a directory, the .yml and
+// .yaml files in it.
+func appFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range dirEntries {
+		if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no value files in %s", path)
+	}
+	return files, nil
+}
+
+// appPaths resolves the value file entries of an apps value relative to the
+// file's directory.
+func (f *valueFile) appPaths(value any) any {
+	resolve := func(item any) any {
+		if name, ok := item.(string); ok && !filepath.IsAbs(name) {
+			return filepath.Join(f.dir, name)
+		}
+		return item
+	}
+	list, ok := value.([]any)
+	if !ok {
+		return resolve(value)
+	}
+	resolved := make([]any, len(list))
+	for i, item := range list {
+		resolved[i] = resolve(item)
+	}
+	return resolved
+}
+
+// selectApp returns the values of the application src.App: the shared
+// values of the files, every value but apps, with its entry merged over
+// them and then the flags applied, so they win over the entry too. The
+// shared values come without the flags, which would else apply twice: an
+// --unset of a list index would remove two entries. A value file entry is
+// rendered like the value files, and names the application unless it sets a
+// name itself. The origins of the entry are added to origins.
+func selectApp(files map[string]any, entries []appEntry, origins valueOrigins, src ValueSources, policy *templatePolicy) (map[string]any, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no application %q: the values declare no %s", src.App, appsKey)
+	}
+	i := slices.IndexFunc(entries, func(e appEntry) bool { return e.name == src.App })
+	if i < 0 {
+		return nil, fmt.Errorf("no application %q in %s (%s)", src.App, appsKey, strings.Join(appNames(entries), ", "))
+	}
+	entry := entries[i]
+
+	shared := copyValues(files).(map[string]any)
+	delete(shared, appsKey)
+	var values map[string]any
+	if entry.file == "" {
+		origins.promote(appsKey + "[" + strconv.Itoa(entry.index) + "]")
+		values = mergeValues(shared, copyValues(entry.values).(map[string]any))
+		if err := applySetValues(values, src); err != nil {
+			return nil, err
+		}
+	} else {
+		shared["name"] = entry.name
+		origins.record(map[string]any{"name": entry.name}, nil, appsKey+": "+entry.file, nil)
+		f, err := newValueFile(entry.file)
+		if err != nil {
+			return nil, err
+		}
+		var layers []valueLayer
+		if values, layers, err = mergeValueFiles(shared, []*valueFile{f}, src, policy); err != nil {
+			return nil, err
+		}
+		merged := shared
+		for _, layer := range layers {
+			if layer.file.err != nil {
+				return nil, layer.file.err
+			}
+			if layer.values == nil {
+				continue
+			}
+			var lines map[string]int
+			if layer.file.last != nil {
+				lines = valueLines(layer.file.text)
+			}
+			origins.record(layer.values, merged, layer.file.source, lines)
+			merged = mergeValues(merged, copyValues(layer.values).(map[string]any))
+		}
+	}
+	// An application does not declare applications itself.
+	delete(values, appsKey)
+	return values, nil
+}
+
+// appNames returns the names of the entries.
+func appNames(entries []appEntry) []string {
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// promote records the layers of every value under prefix again at its path
+// below prefix, after the layers already there: the values of an inline
+// application entry are merged over the shared ones.
+func (o valueOrigins) promote(prefix string) {
+	promoted := valueOrigins{}
+	for path, layers := range o {
+		if rest, ok := strings.CutPrefix(path, prefix+"."); ok {
+			promoted[rest] = layers
+		}
+	}
+	for path, layers := range promoted {
+		o[path] = append(o[path], layers...)
+	}
+}
+
+// requireApp rejects values declaring applications when none is selected.
+func (l *loadedValues) requireApp(src ValueSources) error {
+	if len(l.apps) > 0 && src.App == "" {
+		return fmt.Errorf("the values declare the applications %s: select one", strings.Join(l.apps, ", "))
+	}
+	return nil
+}
+
+// AppNames returns the names of the applications the value sources declare
+// under apps, in order, or nil for the values of a single application. Load
+// each with LoadValueSources and ValueSources.App set to its name.
+func (app *App) AppNames(src ValueSources) ([]string, error) {
+	src.App = ""
+	loaded, err := vals(src, app.templatePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return loaded.apps, nil
+}
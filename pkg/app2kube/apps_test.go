@@ -0,0 +1,146 @@
+package app2kube
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadApps(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app2kube.yml": `namespace: shop
+common:
+  image:
+    repository: example/shop
+deployment:
+  replicaCount: 2
+  containers:
+    app: {}
+apps:
+  - name: api
+    deployment:
+      replicaCount: 3
+  - services
+`,
+		"services/worker.yml": `deployment:
+  containers:
+    app:
+      args: ["{{ .Release.Name }}"]
+`,
+		"services/cron.yaml": `name: cron-jobs
+`,
+		"services/README.md": `not a value file`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := ValueSources{Files: ValueFiles{filepath.Join(dir, "app2kube.yml")}, Values: []string{"deployment.replicaCount=5"}}
+
+	names, err := NewApp().AppNames(src)
+	if err != nil {
+		t.Fatalf("AppNames: %v", err)
+	}
+	if want := []string{"api", "cron", "worker"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("AppNames: got %v, want %v", names, want)
+	}
+
+	if _, err := NewApp().LoadValueSources(src); err == nil || !strings.Contains(err.Error(), "declare the applications api, cron, worker") {
+		t.Errorf("expected an error without an application selected, got %v", err)
+	}
+	src.App = "nope"
+	if _, err := NewApp().LoadValueSources(src); err == nil || !strings.Contains(err.Error(), `no application "nope"`) {
+		t.Errorf("expected an unknown application error, got %v", err)
+	}
+
+	for name, want := range map[string]struct {
+		name     string
+		replicas int32
+		args     []string
+	}{
+		"api":    {name: "api", replicas: 5},
+		"worker": {name: "worker", replicas: 5, args: []string{"worker"}},
+		"cron":   {name: "cron-jobs", replicas: 5},
+	} {
+		src.App = name
+		app := NewApp()
+		if _, err := app.LoadValueSources(src); err != nil {
+			t.Fatalf("%s: LoadValueSources: %v", name, err)
+		}
+		if app.Name != want.name || app.Namespace != "shop" || app.Common.Image.Repository != "example/shop" {
+			t.Errorf("%s: got name %q, namespace %q, image %q", name, app.Name, app.Namespace, app.Common.Image.Repository)
+		}
+		if app.Deployment.ReplicaCount == nil || *app.Deployment.ReplicaCount != want.replicas {
+			t.Errorf("%s: replicaCount: got %v, want %d (the flags win over the application)", name, app.Deployment.ReplicaCount, want.replicas)
+		}
+		if got := app.Deployment.Containers["app"].Args; !reflect.DeepEqual(got, want.args) {
+			t.Errorf("%s: args: got %v, want %v", name, got, want.args)
+		}
+	}
+
+	// The values of an inline application are explained where it sets them.
+	src.App, src.Values = "api", nil
+	out, err := NewApp().ExplainValues(src, "deployment.replicaCount")
+	if err != nil {
+		t.Fatalf("ExplainValues: %v", err)
+	}
+	if want := "3 # " + filepath.Join(dir, "app2kube.yml") + ":12 (overrides " + filepath.Join(dir, "app2kube.yml") + ":6 = 2)"; !strings.Contains(out, want) {
+		t.Errorf("explain: got\n%s\nwant %s", out, want)
+	}
+}
+
+// The flags apply once to an application, however it is declared: an --unset
+// of a list index removes one entry.
+func TestLoadAppsUnsetListIndex(t *testing.T) {
+	files := writeValueFiles(t,
+		[2]string{"app2kube.yml", `ingress:
+  - host: a.example.com
+  - host: b.example.com
+  - host: c.example.com
+apps:
+  - name: api
+  - worker.yml
+`},
+		[2]string{"worker.yml", "namespace: jobs\n"})
+	for _, name := range []string{"api", "worker"} {
+		app := NewApp()
+		src := ValueSources{Files: files[:1], App: name, Unset: []string{"ingress[1]"}}
+		if _, err := app.LoadValueSources(src); err != nil {
+			t.Fatalf("%s: LoadValueSources: %v", name, err)
+		}
+		var hosts []string
+		for _, ingress := range app.Ingress {
+			hosts = append(hosts, ingress.Host)
+		}
+		if want := []string{"a.example.com", "c.example.com"}; !reflect.DeepEqual(hosts, want) {
+			t.Errorf("%s: hosts: got %v, want %v", name, hosts, want)
+		}
+	}
+}
+
+func TestAppEntriesErrors(t *testing.T) {
+	cases := map[string]struct {
+		value  any
+		policy *templatePolicy
+		want   string
+	}{
+		"unnamed":   {value: []any{map[string]any{"namespace": "x"}}, want: "apps[0]: an application needs a name"},
+		"twice":     {value: []any{map[string]any{"name": "a"}, map[string]any{"name": "a"}}, want: `application "a" is declared twice`},
+		"missing":   {value: []any{"missing.yml"}, want: "apps[0]: stat missing.yml"},
+		"safe":      {value: []any{"api.yml"}, policy: newTemplatePolicy(nil), want: "application files are not allowed in safe template mode"},
+		"not list":  {value: map[string]any{"api": nil}, want: "apps: expected a list, got object"},
+		"not entry": {value: []any{float64(1)}, want: "apps[0]: expected an application or a file name, got integer"},
+	}
+	for name, tc := range cases {
+		if _, err := appEntries(tc.value, tc.policy); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want %q", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package app2kube
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// TrackCanary is the LabelTrack value of the canary track.
+const TrackCanary = "canary"
+
+// defaultCanaryWeight is the traffic share of a canary when neither --weight,
+// canary.weight nor canary.steps set one.
+const defaultCanaryWeight int32 = 10
+
+// CanaryResources are the resource types the canary track renders: its own
+// config, workload, Services and canary Ingress. Everything else (PVCs,
+// ServiceAccount, CronJobs, hooks, named workloads, PDB, HPA) stays with the
+// stable track.
+var CanaryResources = []OutputResource{
+	OutputSecret,
+	OutputConfigMap,
+	OutputDeployment,
+	OutputNetworkPolicy,
+	OutputService,
+	OutputIngress,
+}
+
+// IsCanary reports whether the app is the canary track returned by CanaryApp.
+func (app *App) IsCanary() bool {
+	return app.canary
+}
+
+// validateCanary rejects canary weights nginx would refuse.
+func (app *App) validateCanary() error {
+	c := app.Canary
+	if c.Weight != nil && (*c.Weight < 0 || *c.Weight > 100) {
+		return fmt.Errorf("canary.weight must be between 0 and 100, got %d", *c.Weight)
+	}
+	for i, step := range c.Steps {
+		if step < 0 || step > 100 {
+			return fmt.Errorf("canary.steps[%d] must be between 0 and 100, got %d", i, step)
+		}
+		if i > 0 && step <= c.Steps[i-1] {
+			return fmt.Errorf("canary.steps must be increasing, got %d after %d", step, c.Steps[i-1])
+		}
+	}
+	if c.Pause.Duration < 0 {
+		return fmt.Errorf("canary.pause must not be negative")
+	}
+	if c.ReplicaCount != nil && *c.ReplicaCount < 1 {
+		return fmt.Errorf("canary.replicaCount must be at least 1")
+	}
+	return nil
+}
+
+// CanaryWeights returns the traffic weights a canary rollout walks through:
+// the explicit weight (the --weight flag) alone when given, otherwise
+// canary.steps, otherwise canary.weight (default 10).
+func (app *App) CanaryWeights(weight *int32) []int32 {
+	switch {
+	case weight != nil:
+		return []int32{*weight}
+	case len(app.Canary.Steps) > 0:
+		return slices.Clone(app.Canary.Steps)
+	case app.Canary.Weight != nil:
+		return []int32{*app.Canary.Weight}
+	}
+	return []int32{defaultCanaryWeight}
+}
+
+// CanaryApp returns the canary track of the app: a copy rendered under the
+// "<release>-canary" name, whose pods carry the instance label
+// "<instance>-canary" so the stable Services never select them, and whose
+// Ingresses are nginx canary Ingresses routing the canary.weight share of the
+// stable hosts' traffic to it. The canary mounts the stable volume claims and
+// runs as the stable ServiceAccount; its TLS is served by the stable Ingress.
+// Its Services are ClusterIP.
+func (app *App) CanaryApp() (*App, error) {
+	switch {
+	case len(app.Deployment.Containers) == 0:
+		return nil, errors.New("canary requires deployment.containers")
+	case app.IsStatefulSet():
+		return nil, errors.New("canary is not supported with deployment.kind StatefulSet")
+	case app.UsesGatewayAPI():
+		return nil, errors.New("canary requires common.ingress.mode ingress (nginx canary annotations)")
+	case app.Deployment.BlueGreenColor != "":
+		return nil, errors.New("canary cannot be combined with blue-green deployment")
+	}
+
+	canary := *app
+	canary.canary = true
+
+	canary.Labels = make(map[string]string, len(app.Labels)+1)
+	for k, v := range app.Labels {
+		canary.Labels[k] = v
+	}
+	canary.Labels[LabelInstance] = truncateName(app.Labels[LabelInstance] + canarySuffix)
+	canary.Labels[LabelTrack] = TrackCanary
+
+	canary.Deployment.ReplicaCount = ptr.To(int32(1))
+	if app.Canary.ReplicaCount != nil {
+		canary.Deployment.ReplicaCount = ptr.To(*app.Canary.ReplicaCount)
+	}
+	canary.Deployment.Autoscaling.Enabled = false
+
+	// The stable Ingress terminates TLS for the shared hosts; a canary copy
+	// of its Secrets or Certificates would fight over the same names.
+	canary.Common.Ingress.Letsencrypt = false
+	canary.Common.Ingress.SslRedirect = false
+	canary.Ingress = make([]Ingress, len(app.Ingress))
+	for i, ing := range app.Ingress {
+		ing.Letsencrypt = false
+		ing.SslRedirect = false
+		ing.TLSCrt = ""
+		ing.TLSKey = ""
+		ing.TLSSecretName = ""
+		canary.Ingress[i] = ing
+	}
+	// The canary Services only back the canary Ingresses: a node port or a
+	// load balancer of their own would clash with those of the stable ones.
+	canary.Service = make(map[string]Service, len(app.Service))
+	for name, svc := range app.Service {
+		if svc.Type != "" {
+			svc.Type = apiv1.ServiceTypeClusterIP
+		}
+		canary.Service[name] = svc
+	}
+	canary.Cronjob = nil
+	canary.Hooks = HooksSpec{}
+	canary.Workloads = nil
+	return &canary, nil
+}
+
+// canaryIngressAnnotations returns the nginx canary annotations of the canary
+// track's Ingresses (none on the stable track). The weight is the first entry
+// of CanaryWeights unless the CLI set canary.weight to the current step.
+func (app *App) canaryIngressAnnotations() map[string]string {
+	if !app.canary {
+		return nil
+	}
+	annotations := map[string]string{
+		"nginx.ingress.kubernetes.io/canary":        "true",
+		"nginx.ingress.kubernetes.io/canary-weight": strconv.Itoa(int(app.CanaryWeights(nil)[0])),
+	}
+	if app.Canary.Header != "" {
+		annotations["nginx.ingress.kubernetes.io/canary-by-header"] = app.Canary.Header
+		if app.Canary.HeaderValue != "" {
+			annotations["nginx.ingress.kubernetes.io/canary-by-header-value"] = app.Canary.HeaderValue
+		}
+	}
+	if app.Canary.Cookie != "" {
+		annotations["nginx.ingress.kubernetes.io/canary-by-cookie"] = app.Canary.Cookie
+	}
+	return annotations
+}
+
+// SetCanaryWeight sets the traffic share the canary track's Ingresses route to
+// it, for the CLI to walk canary.steps.
+func (app *App) SetCanaryWeight(weight int32) {
+	app.Canary.Weight = ptr.To(weight)
+	app.Canary.Steps = nil
+}
@@ -0,0 +1,183 @@
+package app2kube
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+const canaryValues = `
+name: example
+namespace: prod
+configmap:
+  KEY: value
+deployment:
+  replicaCount: 3
+  containers:
+    app:
+      image: example/app:v2
+      ports:
+        - name: http
+          containerPort: 8080
+volumes:
+  data:
+    mountPath: /data
+    spec:
+      accessModes:
+        - ReadWriteMany
+ingress:
+  - host: example.com
+    tlsSecretName: example-tls
+    sslRedirect: true
+canary:
+  weight: 20
+  header: X-Canary
+  cookie: canary
+`
+
+// The canary track renders under its own names and instance label so the
+// stable Services never select its pods, while sharing the stable volume
+// claims and leaving TLS to the stable Ingress.
+func TestCanaryApp(t *testing.T) {
+	app := mustUnmarshalApp(t, canaryValues)
+	canary, err := app.CanaryApp()
+	if err != nil {
+		t.Fatalf("CanaryApp: %v", err)
+	}
+	if !canary.IsCanary() || app.IsCanary() {
+		t.Fatalf("only the copy must be the canary track")
+	}
+	if app.Labels[LabelTrack] != "" {
+		t.Errorf("CanaryApp must not mutate the stable labels: %v", app.Labels)
+	}
+
+	m, err := canary.GetManifest("yaml", CanaryResources...)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	for _, want := range []string{
+		"# Deployment: example-canary",
+		"# ConfigMap: example-canary",
+		"# Service: example-canary-http",
+		"# Ingress: example-canary-example.com",
+		"app.kubernetes.io/instance: production-canary",
+		"app2kube.io/track: canary",
+		"replicas: 1",
+		"claimName: example-data",
+		"nginx.ingress.kubernetes.io/canary: \"true\"",
+		"nginx.ingress.kubernetes.io/canary-weight: \"20\"",
+		"nginx.ingress.kubernetes.io/canary-by-header: X-Canary",
+		"nginx.ingress.kubernetes.io/canary-by-cookie: canary",
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("canary manifest missing %q:\n%s", want, m)
+		}
+	}
+	for _, unwanted := range []string{"tls:", "example-tls", "ssl-redirect: \"true\"", "# PersistentVolumeClaim:"} {
+		if strings.Contains(m, unwanted) {
+			t.Errorf("canary manifest must not contain %q:\n%s", unwanted, m)
+		}
+	}
+
+	stable, err := app.GetManifest("yaml", OutputIngress)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	if strings.Contains(stable, "nginx.ingress.kubernetes.io/canary") {
+		t.Errorf("the stable Ingress must not carry canary annotations:\n%s", stable)
+	}
+}
+
+// A NodePort or LoadBalancer Service of the stable track is ClusterIP on the
+// canary track, which would otherwise claim the same node port or provision a
+// second load balancer.
+func TestCanaryAppServiceType(t *testing.T) {
+	app := mustUnmarshalApp(t, canaryValues+`service:
+  http:
+    port: 30080
+    internalPort: 8080
+    type: NodePort
+  lb:
+    port: 443
+    internalPort: 8443
+    type: LoadBalancer
+`)
+	canary, err := app.CanaryApp()
+	if err != nil {
+		t.Fatalf("CanaryApp: %v", err)
+	}
+	services, err := canary.GetServices()
+	if err != nil {
+		t.Fatalf("GetServices: %v", err)
+	}
+	for _, svc := range services {
+		if svc.Spec.Type != apiv1.ServiceTypeClusterIP || svc.Spec.Ports[0].NodePort != 0 {
+			t.Errorf("%s: type %s, node port %d, want a ClusterIP", svc.Name, svc.Spec.Type, svc.Spec.Ports[0].NodePort)
+		}
+	}
+	if app.Service["http"].Type != apiv1.ServiceTypeNodePort {
+		t.Errorf("CanaryApp must not mutate the stable Services: %v", app.Service)
+	}
+}
+
+func TestCanaryAppUnsupported(t *testing.T) {
+	cases := map[string]func(app *App){
+		"statefulset": func(app *App) { app.Deployment.Kind = KindStatefulSet },
+		"gateway":     func(app *App) { app.Common.Ingress.Mode = IngressModeGateway },
+		"blue-green":  func(app *App) { app.Deployment.BlueGreenColor = "blue" },
+		"no workload": func(app *App) { app.Deployment.Containers = nil },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			app := deployApp(t)
+			mutate(app)
+			if _, err := app.CanaryApp(); err == nil {
+				t.Errorf("CanaryApp must fail for %s", name)
+			}
+		})
+	}
+}
+
+func TestCanaryWeights(t *testing.T) {
+	app := deployApp(t)
+	if got := app.CanaryWeights(nil); !slices.Equal(got, []int32{10}) {
+		t.Errorf("default weights: got %v", got)
+	}
+	app.Canary.Weight = ptr.To(int32(30))
+	if got := app.CanaryWeights(nil); !slices.Equal(got, []int32{30}) {
+		t.Errorf("canary.weight: got %v", got)
+	}
+	app.Canary.Steps = []int32{10, 50, 100}
+	if got := app.CanaryWeights(nil); !slices.Equal(got, []int32{10, 50, 100}) {
+		t.Errorf("canary.steps: got %v", got)
+	}
+	if got := app.CanaryWeights(ptr.To(int32(5))); !slices.Equal(got, []int32{5}) {
+		t.Errorf("--weight must override the values: got %v", got)
+	}
+}
+
+func TestValidateCanary(t *testing.T) {
+	cases := map[string]struct {
+		spec  CanarySpec
+		valid bool
+	}{
+		"empty":              {CanarySpec{}, true},
+		"steps":              {CanarySpec{Steps: []int32{10, 50, 100}}, true},
+		"weight over 100":    {CanarySpec{Weight: ptr.To(int32(101))}, false},
+		"negative step":      {CanarySpec{Steps: []int32{-1}}, false},
+		"steps not rising":   {CanarySpec{Steps: []int32{50, 50}}, false},
+		"zero replica count": {CanarySpec{ReplicaCount: ptr.To(int32(0))}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			app := deployApp(t)
+			app.Canary = tc.spec
+			if err := app.validateCanary(); (err == nil) != tc.valid {
+				t.Errorf("validateCanary() = %v, want valid=%v", err, tc.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,737 @@
+package app2kube
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ComposeOptions tunes ImportCompose.
+type ComposeOptions struct {
+	// Dir is the directory of the compose file: env_file paths are relative
+	// to it and its base name is the default project name.
+	Dir string
+	// Name names the application of Containers, by default the project name.
+	Name string
+	// Containers makes the services containers of one Deployment instead of
+	// applications of their own.
+	Containers bool
+	// Encrypt encrypts the values that look secret, such as App.EncryptSecret
+	// does; without it, or when it fails, they are kept in plain text.
+	Encrypt func(plaintext string) (string, error)
+}
+
+// composeSecretKey matches the names of the variables whose values look secret.
+var composeSecretKey = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|PASSPHRASE|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|ACCESS_?KEY|CREDENTIAL)`)
+
+// composeURLPassword matches a URL carrying a password, such as a DSN.
+var composeURLPassword = regexp.MustCompile(`://[^/@:\s]+:[^/@\s]+@`)
+
+// composeMemory matches a compose byte size, such as 512m or 1.5gb.
+var composeMemory = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*([bkmgt]?)b?$`)
+
+// composeClaimSpec is the claim of a named volume, which compose does not size.
+var composeClaimSpec = map[string]any{
+	"accessModes": []any{"ReadWriteOnce"},
+	"resources":   map[string]any{"requests": map[string]any{"storage": "1Gi"}},
+}
+
+// composeImporter collects the problems of an ImportCompose and the
+// top-level volumes the services refer to.
+type composeImporter struct {
+	opts     ComposeOptions
+	problems []string
+	volumes  map[string]any
+}
+
+// composeService is a compose service mapped to values: its image, its
+// variables, the values of its container and the service and volumes
+// entries of its ports and named volumes.
+type composeService struct {
+	name  string
+	image string
+	// built is set for a service with a build and no image.
+	built     bool
+	env       map[string]string
+	configmap map[string]string
+	secrets   map[string]string
+	container map[string]any
+	service   map[string]any
+	volumes   map[string]any
+	replicas  any
+}
+
+// ImportCompose reads a docker-compose file and returns the values of its
+// services, each an application of its own under apps, or, with
+// opts.Containers, each a container of one Deployment. The image or build
+// gives common.image, environment gives env and env_file configmap, with the
+// values that look secret in secrets; ports and expose give the container
+// ports and service entries, named volumes give volumes claims and the
+// healthcheck gives the probes. Anything the values cannot represent, such as
+// depends_on or networks, is listed in Problems.
+func ImportCompose(compose []byte, opts ComposeOptions) (*Import, error) {
+	var project map[string]any
+	if err := yaml.Unmarshal(compose, &project); err != nil {
+		return nil, err
+	}
+	services, _ := project["services"].(map[string]any)
+	if len(services) == 0 {
+		return nil, errors.New("no services to import")
+	}
+
+	c := &composeImporter{opts: opts}
+	c.volumes, _ = project["volumes"].(map[string]any)
+	for _, key := range sortedKeys(project) {
+		switch {
+		case key == "services", key == "volumes", key == "name", key == "version", strings.HasPrefix(key, "x-"):
+		default:
+			c.problems = append(c.problems, key+": not imported")
+		}
+	}
+	for _, name := range sortedKeys(c.volumes) {
+		volume, _ := c.volumes[name].(map[string]any)
+		for _, key := range sortedKeys(volume) {
+			c.problems = append(c.problems, fmt.Sprintf("volumes.%s.%s: not imported", name, key))
+		}
+	}
+	if bytes.Contains(compose, []byte("${")) {
+		c.problems = append(c.problems, "variables such as ${TAG} are not interpolated: set their values")
+	}
+
+	var imported []*composeService
+	for _, name := range sortedKeys(services) {
+		svc, ok := services[name].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("services.%s: not a map", name)
+		}
+		s, err := c.importService(name, svc)
+		if err != nil {
+			return nil, fmt.Errorf("services.%s: %w", name, err)
+		}
+		imported = append(imported, s)
+	}
+
+	var values map[string]any
+	if opts.Containers {
+		name := opts.Name
+		if name == "" {
+			name, _ = project["name"].(string)
+		}
+		if name == "" {
+			dir, err := filepath.Abs(opts.Dir)
+			if err != nil {
+				return nil, err
+			}
+			name = filepath.Base(dir)
+		}
+		values = c.containerValues(sanitizeDNSName(name), imported)
+	} else {
+		values = c.appValues(imported)
+	}
+	return &Import{Values: values, Problems: c.problems}, nil
+}
+
+// report records a problem with a service setting.
+func (c *composeImporter) report(service, key, format string, args ...any) {
+	c.problems = append(c.problems, fmt.Sprintf("services.%s.%s: ", service, key)+fmt.Sprintf(format, args...))
+}
+
+// importService maps the settings of a service.
+func (c *composeImporter) importService(name string, svc map[string]any) (*composeService, error) {
+	s := &composeService{
+		name:      name,
+		env:       map[string]string{},
+		configmap: map[string]string{},
+		secrets:   map[string]string{},
+		container: map[string]any{},
+		service:   map[string]any{},
+		volumes:   map[string]any{},
+	}
+	s.image, _ = svc["image"].(string)
+	if build, ok := svc["build"]; ok && s.image == "" {
+		context, _ := build.(string)
+		if m, ok := build.(map[string]any); ok {
+			context, _ = m["context"].(string)
+		}
+		s.image, s.built = name, true
+		c.report(name, "build", "the image is built from %s: build it with app2kube build and set the repository it is pushed to", context)
+	}
+	if s.image == "" {
+		return nil, errors.New("an image or a build is required")
+	}
+
+	for _, key := range sortedKeys(svc) {
+		value := svc[key]
+		var err error
+		switch key {
+		case "image", "build":
+		case "environment":
+			err = c.importEnvironment(s, value)
+		case "env_file":
+			err = c.importEnvFiles(s, value)
+		case "ports":
+			err = c.importPorts(s, value, true)
+		case "expose":
+			err = c.importPorts(s, value, false)
+		case "volumes":
+			err = c.importVolumes(s, value)
+		case "healthcheck":
+			err = c.importHealthcheck(s, value)
+		case "command":
+			s.container["args"] = c.commandLine(name, key, value)
+		case "entrypoint":
+			s.container["command"] = c.commandLine(name, key, value)
+		case "working_dir":
+			s.container["workingDir"] = value
+		case "deploy":
+			err = c.importDeploy(s, value)
+		case "restart":
+			if value != "always" && value != "unless-stopped" {
+				c.report(name, key, "%v is not imported: the pods of a Deployment always restart", value)
+			}
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				c.report(name, key, "not imported")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	// A variable that looks secret goes to secrets, wherever it is set.
+	for _, vars := range []map[string]string{s.env, s.configmap} {
+		for key, value := range vars {
+			if composeSecretKey.MatchString(key) || composeURLPassword.MatchString(value) {
+				s.secrets[key] = value
+				delete(vars, key)
+			}
+		}
+	}
+	return s, nil
+}
+
+// composeString returns a scalar of the compose file as a string.
+func composeString(value any) string {
+	switch val := value.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// importEnvironment maps environment, a map or a list of KEY=VALUE, to env.
+func (c *composeImporter) importEnvironment(s *composeService, value any) error {
+	set := func(key string, val any, ok bool) {
+		if !ok || val == nil {
+			c.report(s.name, "environment."+key, "the value comes from the host: set it")
+			return
+		}
+		s.env[key] = composeString(val)
+	}
+	switch env := value.(type) {
+	case map[string]any:
+		for key, val := range env {
+			set(key, val, true)
+		}
+	case []any:
+		for _, item := range env {
+			key, val, ok := strings.Cut(composeString(item), "=")
+			set(key, val, ok)
+		}
+	default:
+		return errors.New("a map or a list is required")
+	}
+	return nil
+}
+
+// importEnvFiles maps the variables of the env files to configmap.
+func (c *composeImporter) importEnvFiles(s *composeService, value any) error {
+	var files []any
+	switch val := value.(type) {
+	case string:
+		files = []any{val}
+	case []any:
+		files = val
+	default:
+		return errors.New("a path or a list is required")
+	}
+	for _, file := range files {
+		path, required := composeString(file), true
+		if m, ok := file.(map[string]any); ok {
+			path = composeString(m["path"])
+			if r, ok := m["required"].(bool); ok {
+				required = r
+			}
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.opts.Dir, path)
+		}
+		vars, err := readEnvFile(path)
+		if errors.Is(err, os.ErrNotExist) && !required {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for key, val := range vars {
+			s.configmap[key] = val
+		}
+	}
+	return nil
+}
+
+// readEnvFile reads the KEY=VALUE lines of an env file, skipping blank lines
+// and comments and unquoting the values.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: KEY=VALUE expected", path, n)
+		}
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		vars[strings.TrimSpace(key)] = val
+	}
+	return vars, scanner.Err()
+}
+
+// importPorts maps ports, or expose without published, to the container
+// ports and a service entry per port, keyed "port-<number>".
+func (c *composeImporter) importPorts(s *composeService, value any, published bool) error {
+	list, ok := value.([]any)
+	if !ok {
+		return errors.New("a list is required")
+	}
+	key := "ports"
+	if !published {
+		key = "expose"
+	}
+	for _, item := range list {
+		var name, protocol, target, port string
+		if m, ok := item.(map[string]any); ok {
+			name, _ = m["name"].(string)
+			protocol, _ = m["protocol"].(string)
+			target, port = composeString(m["target"]), composeString(m["published"])
+			if m["mode"] == "host" {
+				c.report(s.name, key, "the host mode of %s is not imported", target)
+			}
+		} else {
+			spec := composeString(item)
+			spec, protocol, _ = strings.Cut(spec, "/")
+			if i := strings.LastIndex(spec, ":"); i >= 0 {
+				target, port = spec[i+1:], spec[:i]
+				// Drop the host IP, an IPv6 one in brackets included.
+				if j := strings.LastIndexAny(port, ":]"); j >= 0 {
+					port = port[j+1:]
+				}
+			} else {
+				target = spec
+			}
+		}
+		number, err := strconv.ParseInt(target, 10, 32)
+		if err != nil || number <= 0 || strings.Contains(port, "-") {
+			c.report(s.name, key, "%v is not imported: only single ports are", item)
+			continue
+		}
+		if name == "" {
+			name = "port-" + target
+		}
+		if _, ok := s.service[name]; ok {
+			continue
+		}
+		containerPort := map[string]any{"name": name, "containerPort": number}
+		entry := map[string]any{"port": number}
+		if port != "" && port != target {
+			external, err := strconv.ParseInt(port, 10, 32)
+			if err != nil {
+				c.report(s.name, key, "%v is not imported: only single ports are", item)
+				continue
+			}
+			entry = map[string]any{"externalPort": external, "internalPort": number}
+		}
+		if strings.EqualFold(protocol, "udp") {
+			containerPort["protocol"] = "UDP"
+			entry["protocol"] = "UDP"
+		}
+		ports, _ := s.container["ports"].([]any)
+		s.container["ports"] = append(ports, containerPort)
+		s.service[name] = entry
+	}
+	return nil
+}
+
+// importVolumes maps the named volumes of a service to volumes claims.
+func (c *composeImporter) importVolumes(s *composeService, value any) error {
+	list, ok := value.([]any)
+	if !ok {
+		return errors.New("a list is required")
+	}
+	for _, item := range list {
+		var kind, source, target string
+		var readOnly bool
+		if m, ok := item.(map[string]any); ok {
+			kind, _ = m["type"].(string)
+			source, target = composeString(m["source"]), composeString(m["target"])
+			readOnly, _ = m["read_only"].(bool)
+		} else {
+			parts := strings.Split(composeString(item), ":")
+			switch {
+			case len(parts) == 1:
+				target = parts[0]
+			default:
+				source, target = parts[0], parts[1]
+				readOnly = len(parts) > 2 && slices.Contains(strings.Split(parts[2], ","), "ro")
+			}
+			switch {
+			case source == "":
+				kind = "volume"
+			case strings.HasPrefix(source, "/"), strings.HasPrefix(source, "."), strings.HasPrefix(source, "~"):
+				kind = "bind"
+			default:
+				kind = "volume"
+			}
+		}
+		switch {
+		case kind != "volume":
+			c.report(s.name, "volumes", "the %s mount %s is not imported", kind, target)
+			continue
+		case source == "":
+			c.report(s.name, "volumes", "the anonymous volume %s is not imported", target)
+			continue
+		case readOnly:
+			c.report(s.name, "volumes", "the volume %s is mounted read-write", source)
+		}
+		if _, ok := c.volumes[source]; !ok {
+			c.report(s.name, "volumes", "the volume %s is not declared", source)
+		}
+		s.volumes[sanitizeDNSName(source)] = map[string]any{"mountPath": target, "spec": copyValues(composeClaimSpec)}
+	}
+	return nil
+}
+
+// importHealthcheck maps the healthcheck to the same exec liveness and
+// readiness probes.
+func (c *composeImporter) importHealthcheck(s *composeService, value any) error {
+	check, ok := value.(map[string]any)
+	if !ok {
+		return errors.New("a map is required")
+	}
+	if disable, _ := check["disable"].(bool); disable {
+		return nil
+	}
+	var command []any
+	switch test := check["test"].(type) {
+	case string:
+		command = []any{"/bin/sh", "-c", test}
+	case []any:
+		if len(test) == 0 {
+			return errors.New("test: a command is required")
+		}
+		switch test[0] {
+		case "NONE":
+			return nil
+		case "CMD":
+			command = test[1:]
+		case "CMD-SHELL":
+			command = append([]any{"/bin/sh", "-c"}, test[1:]...)
+		default:
+			return fmt.Errorf("test: %v is not CMD, CMD-SHELL or NONE", test[0])
+		}
+	default:
+		return errors.New("test: a command is required")
+	}
+
+	probe := map[string]any{"exec": map[string]any{"command": command}}
+	for _, field := range []struct{ compose, probe string }{
+		{"interval", "periodSeconds"},
+		{"timeout", "timeoutSeconds"},
+		{"start_period", "initialDelaySeconds"},
+	} {
+		if value, ok := check[field.compose]; ok {
+			d, err := time.ParseDuration(composeString(value))
+			if err != nil {
+				return fmt.Errorf("%s: %w", field.compose, err)
+			}
+			probe[field.probe] = int64(math.Ceil(d.Seconds()))
+		}
+	}
+	if retries, ok := check["retries"].(float64); ok {
+		probe["failureThreshold"] = int64(retries)
+	}
+	for _, key := range sortedKeys(check) {
+		if !slices.Contains([]string{"test", "interval", "timeout", "start_period", "retries", "disable"}, key) {
+			c.report(s.name, "healthcheck."+key, "not imported")
+		}
+	}
+	s.container["livenessProbe"] = probe
+	s.container["readinessProbe"] = copyValues(probe)
+	return nil
+}
+
+// commandLine returns a command, or entrypoint, as a list: a string is split
+// on spaces, like compose without its shell quoting.
+func (c *composeImporter) commandLine(service, key string, value any) any {
+	line, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if strings.ContainsAny(line, `"'\`) {
+		c.report(service, key, "split on spaces: check the quoting")
+	}
+	var words []any
+	for _, word := range strings.Fields(line) {
+		words = append(words, word)
+	}
+	return words
+}
+
+// importDeploy maps the replicas and the resources of deploy.
+func (c *composeImporter) importDeploy(s *composeService, value any) error {
+	deploy, ok := value.(map[string]any)
+	if !ok {
+		return errors.New("a map is required")
+	}
+	for _, key := range sortedKeys(deploy) {
+		switch key {
+		case "replicas":
+			s.replicas = deploy[key]
+		case "resources":
+			resources, _ := deploy[key].(map[string]any)
+			for _, field := range []struct{ compose, values string }{{"limits", "limits"}, {"reservations", "requests"}} {
+				spec, _ := resources[field.compose].(map[string]any)
+				for _, name := range sortedKeys(spec) {
+					switch name {
+					case "cpus":
+						s.setResource(field.values, "cpu", composeString(spec[name]))
+					case "memory":
+						memory := composeString(spec[name])
+						if m := composeMemory.FindStringSubmatch(memory); m != nil {
+							memory = m[1] + map[string]string{"": "", "b": "", "k": "Ki", "m": "Mi", "g": "Gi", "t": "Ti"}[strings.ToLower(m[2])]
+						}
+						s.setResource(field.values, "memory", memory)
+					default:
+						c.report(s.name, "deploy.resources."+field.compose+"."+name, "not imported")
+					}
+				}
+			}
+		default:
+			c.report(s.name, "deploy."+key, "not imported")
+		}
+	}
+	return nil
+}
+
+// setResource sets a resource of the container under requests or limits.
+func (s *composeService) setResource(kind, name, quantity string) {
+	resources, _ := s.container["resources"].(map[string]any)
+	if resources == nil {
+		resources = map[string]any{}
+		s.container["resources"] = resources
+	}
+	list, _ := resources[kind].(map[string]any)
+	if list == nil {
+		list = map[string]any{}
+		resources[kind] = list
+	}
+	list[name] = quantity
+}
+
+// stringValues returns variables as values.
+func stringValues(vars map[string]string) map[string]any {
+	values := make(map[string]any, len(vars))
+	for key, value := range vars {
+		values[key] = value
+	}
+	return values
+}
+
+// encrypt returns the secrets encrypted with opts.Encrypt, or as they are
+// when it is not set or fails, which is reported once.
+func (c *composeImporter) encrypt(secrets map[string]string) map[string]any {
+	encrypted := map[string]any{}
+	for _, key := range sortedKeys(secrets) {
+		value := secrets[key]
+		if c.opts.Encrypt != nil {
+			v, err := c.opts.Encrypt(value)
+			if err == nil {
+				encrypted[key] = v
+				continue
+			}
+			c.reportPlain(err.Error())
+		} else {
+			c.reportPlain("no encryption key")
+		}
+		encrypted[key] = value
+	}
+	return encrypted
+}
+
+// reportPlain reports, once, that the secrets are kept in plain text.
+func (c *composeImporter) reportPlain(reason string) {
+	problem := "secrets: kept in plain text (" + reason + "): encrypt them with app2kube config encrypt"
+	if !slices.Contains(c.problems, problem) {
+		c.problems = append(c.problems, problem)
+	}
+}
+
+// appValues returns the values of the services as applications under apps,
+// or the values of the only one.
+func (c *composeImporter) appValues(services []*composeService) map[string]any {
+	var apps []any
+	for _, s := range services {
+		im := &importer{values: map[string]any{}}
+		im.setValue("name", s.name)
+		repository, tag := c.splitImage(s)
+		im.setValue("common.image.repository", repository)
+		if tag != "" {
+			im.setValue("common.image.tag", tag)
+		}
+		im.setValue("env", stringValues(s.env))
+		im.setValue("configmap", stringValues(s.configmap))
+		im.setValue("secrets", c.encrypt(s.secrets))
+		im.setValue("deployment.replicaCount", s.replicas)
+		parent, _ := im.parent("deployment.containers.x")
+		parent[s.name] = s.container
+		im.setValue("service", s.service)
+		im.setValue("volumes", s.volumes)
+		if len(services) > 1 && len(s.service) > 0 {
+			c.problems = append(c.problems, fmt.Sprintf("services.%s: the other services reach it at %s-%s, not %s", s.name, s.name, sortedKeys(s.service)[0], s.name))
+		}
+		apps = append(apps, im.values)
+	}
+	if len(apps) == 1 {
+		return apps[0].(map[string]any)
+	}
+	for _, volume := range sortedKeys(c.volumes) {
+		var users []string
+		for _, s := range services {
+			if _, ok := s.volumes[sanitizeDNSName(volume)]; ok {
+				users = append(users, s.name)
+			}
+		}
+		if len(users) > 1 {
+			c.problems = append(c.problems, fmt.Sprintf("volumes.%s: shared by %s, each of which gets a claim of its own", volume, strings.Join(users, ", ")))
+		}
+	}
+	return map[string]any{"apps": apps}
+}
+
+// containerValues returns the values of the services as containers of one
+// Deployment. The first service built here, or else the first with ports, or
+// the first one, runs the app image and gets the variables and volumes; the
+// others keep their image, so their variables go to their container env and
+// their volumes are mounted explicitly.
+func (c *composeImporter) containerValues(name string, services []*composeService) map[string]any {
+	app := services[0]
+	for _, s := range slices.Backward(services) {
+		if s.built || (!app.built && len(s.service) > 0) {
+			app = s
+		}
+	}
+	im := &importer{values: map[string]any{}}
+	im.setValue("name", name)
+	repository, tag := c.splitImage(app)
+	im.setValue("common.image.repository", repository)
+	if tag != "" {
+		im.setValue("common.image.tag", tag)
+	}
+	im.setValue("env", stringValues(app.env))
+	im.setValue("configmap", stringValues(app.configmap))
+	im.setValue("secrets", c.encrypt(app.secrets))
+	im.setValue("deployment.replicaCount", app.replicas)
+	im.setValue("volumes", app.volumes)
+
+	entries, _ := im.parent("service.x")
+	containers, _ := im.parent("deployment.containers.x")
+	for _, s := range services {
+		for key, entry := range s.service {
+			if _, ok := entries[key]; ok {
+				c.report(s.name, "ports", "%s is not imported: another service has the port", key)
+				continue
+			}
+			entries[key] = entry
+		}
+		containers[s.name] = s.container
+		if s == app {
+			continue
+		}
+		s.container["image"] = s.image
+		if s.replicas != nil && s.replicas != app.replicas {
+			c.report(s.name, "deploy.replicas", "not imported: the containers share the replicas of %s", app.name)
+		}
+		var env []any
+		for _, vars := range []map[string]string{s.env, s.configmap, s.secrets} {
+			for _, key := range sortedKeys(vars) {
+				env = append(env, map[string]any{"name": key, "value": vars[key]})
+			}
+		}
+		if len(s.secrets) > 0 {
+			c.report(s.name, "environment", "%s kept in plain text in the container env: app2kube injects secrets into the app image only", strings.Join(sortedKeys(s.secrets), ", "))
+		}
+		if env != nil {
+			s.container["env"] = env
+		}
+		var mounts []any
+		for _, key := range sortedKeys(s.volumes) {
+			volume := s.volumes[key].(map[string]any)
+			mounts = append(mounts, map[string]any{"name": key, "mountPath": volume["mountPath"]})
+			if volumes, _ := im.values["volumes"].(map[string]any); volumes[key] == nil {
+				im.setValue("volumes."+key, volume)
+				c.report(s.name, "volumes", "%s is mounted at %s in the %s container too", key, volume["mountPath"], app.name)
+			}
+		}
+		if mounts != nil {
+			s.container["volumeMounts"] = mounts
+		}
+	}
+	if len(entries) == 0 {
+		delete(im.values, "service")
+	}
+	if len(services) > 1 {
+		c.problems = append(c.problems, "services: the containers share the pod, so they reach each other at localhost, not by service name")
+	}
+	return im.values
+}
+
+// splitImage returns the repository and the tag of the image of a service,
+// dropping a digest, which common.image cannot pin.
+func (c *composeImporter) splitImage(s *composeService) (string, string) {
+	image := s.image
+	if before, _, ok := strings.Cut(image, "@"); ok {
+		c.report(s.name, "image", "the digest of %s is not imported", image)
+		image = before
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
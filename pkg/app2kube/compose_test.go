@@ -0,0 +1,246 @@
+package app2kube
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const composeFile = `name: shop
+services:
+  web:
+    build: .
+    command: bundle exec puma -p 3000
+    environment:
+      RAILS_ENV: production
+      DATABASE_URL: postgres://shop:hunter2@db/shop
+      PORT: 3000
+    env_file: web.env
+    ports:
+      - "127.0.0.1:8080:3000"
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost:3000/up"]
+      interval: 10s
+      retries: 5
+    depends_on: [db]
+    networks: [front]
+    deploy:
+      replicas: 2
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 512M
+  db:
+    image: postgres:16
+    environment:
+      - POSTGRES_PASSWORD=hunter2
+      - POSTGRES_DB=shop
+    expose: ["5432"]
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+      - ./init.sql:/docker-entrypoint-initdb.d/init.sql
+volumes:
+  pgdata: {}
+networks:
+  front: {}
+`
+
+// composeDir returns a directory holding the env file of composeFile.
+func composeDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "web.env"), []byte("# web\nLOG_LEVEL=info\nexport API_TOKEN=\"abc\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestImportCompose(t *testing.T) {
+	imported, err := ImportCompose([]byte(composeFile), ComposeOptions{
+		Dir:     composeDir(t),
+		Encrypt: func(s string) (string, error) { return "enc:" + s, nil },
+	})
+	if err != nil {
+		t.Fatalf("ImportCompose: %v", err)
+	}
+	got, err := imported.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `apps:
+- common:
+    image:
+      repository: postgres
+      tag: "16"
+  deployment:
+    containers:
+      db:
+        ports:
+        - containerPort: 5432
+          name: port-5432
+  env:
+    POSTGRES_DB: shop
+  name: db
+  secrets:
+    POSTGRES_PASSWORD: enc:hunter2
+  service:
+    port-5432:
+      port: 5432
+  volumes:
+    pgdata:
+      mountPath: /var/lib/postgresql/data
+      spec:
+        accessModes:
+        - ReadWriteOnce
+        resources:
+          requests:
+            storage: 1Gi
+- common:
+    image:
+      repository: web
+  configmap:
+    LOG_LEVEL: info
+  deployment:
+    containers:
+      web:
+        args:
+        - bundle
+        - exec
+        - puma
+        - -p
+        - "3000"
+        livenessProbe:
+          exec:
+            command:
+            - curl
+            - -f
+            - http://localhost:3000/up
+          failureThreshold: 5
+          periodSeconds: 10
+        ports:
+        - containerPort: 3000
+          name: port-3000
+        readinessProbe:
+          exec:
+            command:
+            - curl
+            - -f
+            - http://localhost:3000/up
+          failureThreshold: 5
+          periodSeconds: 10
+        resources:
+          limits:
+            cpu: "0.5"
+            memory: 512Mi
+    replicaCount: 2
+  env:
+    PORT: "3000"
+    RAILS_ENV: production
+  name: web
+  secrets:
+    API_TOKEN: enc:abc
+    DATABASE_URL: enc:postgres://shop:hunter2@db/shop
+  service:
+    port-3000:
+      externalPort: 8080
+      internalPort: 3000
+`
+	if string(got) != want {
+		t.Errorf("values:\n%s\nwant:\n%s", got, want)
+	}
+
+	wantProblems := []string{
+		"networks: not imported",
+		"services.db.volumes: the bind mount /docker-entrypoint-initdb.d/init.sql is not imported",
+		"services.web.build: the image is built from .: build it with app2kube build and set the repository it is pushed to",
+		"services.web.depends_on: not imported",
+		"services.web.networks: not imported",
+		"services.db: the other services reach it at db-port-5432, not db",
+		"services.web: the other services reach it at web-port-3000, not web",
+	}
+	if !reflect.DeepEqual(imported.Problems, wantProblems) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(imported.Problems, "\n"), strings.Join(wantProblems, "\n"))
+	}
+	if _, err := imported.Check(); err == nil {
+		t.Error("expected Check to refuse values not imported from manifests")
+	}
+}
+
+// The services as containers of one Deployment render, the secrets
+// encrypted with the key of the environment.
+func TestImportComposeContainers(t *testing.T) {
+	t.Setenv(EnvPassword, "compose")
+	imported, err := ImportCompose([]byte(composeFile), ComposeOptions{
+		Dir:        composeDir(t),
+		Containers: true,
+		Encrypt:    NewApp().EncryptSecret,
+	})
+	if err != nil {
+		t.Fatalf("ImportCompose: %v", err)
+	}
+	values, err := imported.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets := imported.Values["secrets"].(map[string]any); !IsEncrypted(secrets["API_TOKEN"].(string)) {
+		t.Errorf("secrets not encrypted: %v", secrets)
+	}
+	for _, want := range []string{
+		"services.db.environment: POSTGRES_PASSWORD kept in plain text in the container env: app2kube injects secrets into the app image only",
+		"services.db.volumes: pgdata is mounted at /var/lib/postgresql/data in the web container too",
+		"services: the containers share the pod, so they reach each other at localhost, not by service name",
+	} {
+		if !slices.Contains(imported.Problems, want) {
+			t.Errorf("problems: no %q in\n%s", want, strings.Join(imported.Problems, "\n"))
+		}
+	}
+
+	app := NewApp()
+	if _, err := app.LoadValueSources(ValueSources{Files: writeValueFiles(t, [2]string{"shop.yml", string(values)})}); err != nil {
+		t.Fatalf("load: %v\n%s", err, values)
+	}
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	for _, want := range []string{
+		"name: shop-port-5432",
+		"image: web:latest",
+		"image: postgres:16",
+		"claimName: shop-pgdata",
+		"name: POSTGRES_PASSWORD",
+	} {
+		if !strings.Contains(manifest, want) {
+			t.Errorf("manifest: no %q in\n%s", want, manifest)
+		}
+	}
+}
+
+func TestImportComposeErrors(t *testing.T) {
+	cases := map[string]string{
+		"services: {}\n":                       "no services to import",
+		"services:\n  web:\n    ports: [80]\n": "services.web: an image or a build is required",
+		"services:\n  web:\n    image: a\n    healthcheck: {test: [RUN, x]}\n": "services.web: healthcheck: test: RUN is not CMD, CMD-SHELL or NONE",
+		"services:\n  web:\n    image: a\n    env_file: missing.env\n":         "services.web: env_file: open",
+	}
+	for compose, want := range cases {
+		if _, err := ImportCompose([]byte(compose), ComposeOptions{Dir: t.TempDir()}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", compose, err, want)
+		}
+	}
+
+	// Without a key the secrets are kept, and reported, in plain text.
+	imported, err := ImportCompose([]byte("services:\n  web:\n    image: a\n    environment: [DB_PASSWORD=x]\n    env_file: [{path: missing.env, required: false}]\n"), ComposeOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("ImportCompose: %v", err)
+	}
+	if got := imported.Values["secrets"]; !reflect.DeepEqual(got, map[string]any{"DB_PASSWORD": "x"}) {
+		t.Errorf("secrets: got %v", got)
+	}
+	if want := []string{"secrets: kept in plain text (no encryption key): encrypt them with app2kube config encrypt"}; !reflect.DeepEqual(imported.Problems, want) {
+		t.Errorf("problems: got %q, want %q", imported.Problems, want)
+	}
+}
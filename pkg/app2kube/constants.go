@@ -10,6 +10,17 @@ const (
 	LabelManagedBy = "app.kubernetes.io/managed-by"
 	LabelColor     = "app.kubernetes.io/color"
 
+	// LabelComponent names the workload (workloads.<name>) an object or pod
+	// belongs to.
+	LabelComponent = "app.kubernetes.io/component"
+
+	// LabelTrack marks the objects and pods of the canary track.
+	LabelTrack = "app2kube.io/track"
+
+	// LabelHook marks a hook Job with the phase it runs in, so hook Jobs are
+	// told apart from the CronJob-spawned Jobs that share the app labels.
+	LabelHook = "app2kube.io/hook"
+
 	// ManagedByValue is the value of the managed-by label.
 	ManagedByValue = "app2kube"
 )
@@ -23,11 +34,26 @@ const (
 	EnvDecryptKey = "APP2KUBE_DECRYPT_KEY"
 )
 
+// Workload kinds accepted by deployment.kind. The app's pods run in a
+// Deployment unless StatefulSet is requested.
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+	// KindJob is the kind of the hook Jobs; it is not a deployment.kind value.
+	KindJob = "Job"
+)
+
 const (
 	// sharedDataVolumeName is the EmptyDir shared between an app's containers.
 	sharedDataVolumeName = "shared-data"
 	// tlsSecretPrefix prefixes a host-derived TLS Secret name.
 	tlsSecretPrefix = "tls-"
+	// canarySuffix names the canary track: its release ("<release>-canary"),
+	// Ingress and instance label.
+	canarySuffix = "-canary"
+	// headlessServiceSuffix names the governing headless Service of a
+	// StatefulSet ("<release>-headless").
+	headlessServiceSuffix = "-headless"
 )
 
 // cert-manager Certificate generation constants. The type is rendered from a
@@ -47,6 +73,34 @@ const (
 	clusterIssuerKind = "ClusterIssuer"
 )
 
+// Routing modes accepted by common.ingress.mode: the `ingress:` entries render
+// Ingress objects unless gateway mode asks for Gateway API HTTPRoutes.
+const (
+	IngressModeIngress = "ingress"
+	IngressModeGateway = "gateway"
+)
+
+// Gateway API HTTPRoute generation constants. The type is rendered from a
+// minimal local struct (gateway.go) so the Gateway API module is not pulled
+// into go.mod.
+const (
+	gatewayAPIVersion = "gateway.networking.k8s.io/v1"
+	httpRouteKind     = "HTTPRoute"
+	// httpRoutePathType is the match type of every route path: the Gateway
+	// API counterpart of the nginx prefix semantics of an Ingress path.
+	httpRoutePathType = "PathPrefix"
+)
+
+// Prometheus Operator monitor generation constants. Like the cert-manager
+// Certificate, the monitors are rendered from minimal local structs
+// (monitor.go) so the operator is not pulled into go.mod.
+const (
+	prometheusOperatorAPIVersion = "monitoring.coreos.com/v1"
+
+	KindServiceMonitor = "ServiceMonitor"
+	KindPodMonitor     = "PodMonitor"
+)
+
 // Pod-template annotation keys carrying the sha256 of the referenced config, so
 // a change to the ConfigMap/Secret content rolls the workload (#22).
 const (
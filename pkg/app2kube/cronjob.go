@@ -3,6 +3,7 @@ package app2kube
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 
 	batch "k8s.io/api/batch/v1"
@@ -11,6 +12,22 @@ import (
 	"k8s.io/utils/ptr"
 )
 
+// dropClaimTemplateMounts removes the app.Volumes mounts from containers of a
+// standalone Job pod (CronJob, hook) in StatefulSet mode. A StatefulSet's
+// volumes are per-replica claims; such a pod has no ordinal and no standalone
+// PVC exists, so it must not reference a claim that is never created.
+func (app *App) dropClaimTemplateMounts(containers []apiv1.Container) {
+	if !app.IsStatefulSet() {
+		return
+	}
+	for i := range containers {
+		containers[i].VolumeMounts = slices.DeleteFunc(containers[i].VolumeMounts, func(vm apiv1.VolumeMount) bool {
+			_, ok := app.Volumes[vm.Name]
+			return ok
+		})
+	}
+}
+
 // GetCronJobs resource
 func (app *App) GetCronJobs() (crons []*batch.CronJob, err error) {
 	// Track the final object names so two distinct cron keys that collapse to the
@@ -86,6 +103,8 @@ func (app *App) GetCronJobs() (crons []*batch.CronJob, err error) {
 			containers = append(containers, container)
 		}
 
+		app.dropClaimTemplateMounts(containers)
+
 		// A cronjob with neither `container` nor `containers` would render an
 		// invalid CronJob carrying an empty pod; fail loudly instead of emitting it.
 		if len(containers) == 0 {
@@ -34,12 +34,12 @@ func (app *App) podSecurityContext() *apiv1.PodSecurityContext {
 func (app *App) commonPodSpec(affinity *apiv1.Affinity) apiv1.PodSpec {
 	spec := apiv1.PodSpec{
 		Affinity:                     affinity,
-		AutomountServiceAccountToken: ptr.To(app.Common.MountServiceAccountToken),
+		AutomountServiceAccountToken: ptr.To(app.mountServiceAccountToken()),
 		DNSPolicy:                    app.Common.DNSPolicy,
 		EnableServiceLinks:           ptr.To(app.Common.EnableServiceLinks),
 		NodeSelector:                 app.Common.NodeSelector,
 		SecurityContext:              app.podSecurityContext(),
-		ServiceAccountName:           app.Common.ServiceAccountName,
+		ServiceAccountName:           app.GetServiceAccountName(),
 		Tolerations:                  app.Common.Tolerations,
 	}
 	if app.Common.Image.PullSecrets != "" {
@@ -83,11 +83,12 @@ func (app *App) podVolumes(replicas int32, containerGroups ...[]apiv1.Container)
 		}
 		vol := app.Volumes[volName]
 		// A ReadWriteOnce(-only) PVC can be bound on a single node; mounting it
-		// into a multi-replica Deployment makes pods on other nodes unschedulable
-		// (a StatefulSet would be correct but is out of scope). Warn instead of
-		// silently emitting a spec that deadlocks (#48).
+		// into a multi-replica Deployment makes pods on other nodes
+		// unschedulable. Warn instead of silently emitting a spec that
+		// deadlocks (#48); deployment.kind: StatefulSet gives every replica its
+		// own claim instead.
 		if replicas > 1 && len(vol.Spec.AccessModes) > 0 && !pvcAllowsMultiAttach(vol.Spec.AccessModes) {
-			fmt.Fprintf(os.Stderr, "WARNING: PVC %q (%v) is mounted into a %d-replica Deployment; pods on different nodes cannot share a ReadWriteOnce volume and scheduling will block (use a single replica or a ReadWriteMany volume; StatefulSet is out of scope)\n", volName, vol.Spec.AccessModes, replicas)
+			fmt.Fprintf(os.Stderr, "WARNING: PVC %q (%v) is mounted into a %d-replica Deployment; pods on different nodes cannot share a ReadWriteOnce volume and scheduling will block (use a single replica, a ReadWriteMany volume or deployment.kind: StatefulSet)\n", volName, vol.Spec.AccessModes, replicas)
 		}
 		volumes = append(volumes, apiv1.Volume{
 			Name: volName,
@@ -101,63 +102,113 @@ func (app *App) podVolumes(replicas int32, containerGroups ...[]apiv1.Container)
 	return volumes
 }
 
-// GetDeployment resource
-func (app *App) GetDeployment() (deployment *appsv1.Deployment, err error) {
-	if len(app.Deployment.Containers) > 0 {
-		// A mutable :latest common image tag in a non-staging deploy is not
-		// reproducible (and relies on the pull policy to refresh cached nodes);
-		// warn so the operator can pin a specific tag or digest (#45).
-		if !app.Staging.Active && app.Common.Image.Repository != "" && app.Common.Image.Tag == "latest" {
-			fmt.Fprintf(os.Stderr, "WARNING: image %s:latest is a mutable tag; the deploy is not reproducible — pin a specific tag or digest\n", app.Common.Image.Repository)
+// workloadReplicas returns the replica count of the app's workload: an unset
+// replicaCount defaults to 1; an explicit value (including 0, for
+// scale-to-zero) is honored. The field is *int32 so unset is distinguishable
+// from an explicit 0 (#42).
+func (app *App) workloadReplicas() int32 {
+	replicas := int32(1)
+	if app.Deployment.ReplicaCount != nil {
+		replicas = *app.Deployment.ReplicaCount
+		if replicas < 0 {
+			replicas = 0
 		}
+	}
+	return replicas
+}
 
-		// An unset replicaCount defaults to 1; an explicit value (including 0, for
-		// scale-to-zero) is honored. The field is *int32 so unset is
-		// distinguishable from an explicit 0 (#42).
-		replicas := int32(1)
-		if app.Deployment.ReplicaCount != nil {
-			replicas = *app.Deployment.ReplicaCount
-			if replicas < 0 {
-				replicas = 0
-			}
-		}
+// specReplicas returns the workload's spec.replicas. With autoscaling enabled
+// it is nil: the HPA owns the replica count, and pinning it in the applied
+// manifest would make every apply reset the scale the HPA chose.
+func (app *App) specReplicas() *int32 {
+	if app.Deployment.Autoscaling.Enabled {
+		return nil
+	}
+	return ptr.To(app.workloadReplicas())
+}
 
-		// Iterate in sorted key order so the rendered container list is stable
-		// across runs; an unsorted (map-random) order would change the pod
-		// template on every render and roll the Deployment on each apply.
-		var containers []apiv1.Container
-		for _, name := range sortedKeys(app.Deployment.Containers) {
-			container := app.Deployment.Containers[name]
-			container.Name = strings.ToLower(name)
-			err = app.processContainer(&container, false)
-			if err != nil {
-				return
-			}
-			containers = append(containers, container)
+// peakReplicas returns the largest replica count the workload can run at: the
+// HPA's maxReplicas when autoscaling, otherwise the fixed replica count.
+func (app *App) peakReplicas() int32 {
+	if app.Deployment.Autoscaling.Enabled {
+		return app.Deployment.Autoscaling.MaxReplicas
+	}
+	return app.workloadReplicas()
+}
+
+// workloadPodTemplate builds the pod template shared by the Deployment and the
+// StatefulSet: the processed main/init containers, the affinity, the config
+// checksum annotations and the pod volumes. replicas only feeds the
+// ReadWriteOnce multi-attach warning in podVolumes.
+func (app *App) workloadPodTemplate(replicas int32) (apiv1.PodTemplateSpec, error) {
+	// A mutable :latest common image tag in a non-staging deploy is not
+	// reproducible (and relies on the pull policy to refresh cached nodes);
+	// warn so the operator can pin a specific tag or digest (#45).
+	if !app.Staging.Active && app.Common.Image.Repository != "" && app.Common.Image.Tag == "latest" {
+		fmt.Fprintf(os.Stderr, "WARNING: image %s:latest is a mutable tag; the deploy is not reproducible — pin a specific tag or digest\n", app.Common.Image.Repository)
+	}
+
+	// Iterate in sorted key order so the rendered container list is stable
+	// across runs; an unsorted (map-random) order would change the pod
+	// template on every render and roll the Deployment on each apply.
+	var containers []apiv1.Container
+	for _, name := range sortedKeys(app.Deployment.Containers) {
+		container := app.Deployment.Containers[name]
+		container.Name = strings.ToLower(name)
+		if err := app.processContainer(&container, false); err != nil {
+			return apiv1.PodTemplateSpec{}, err
 		}
+		containers = append(containers, container)
+	}
 
-		var initContainers []apiv1.Container
-		for _, name := range sortedKeys(app.Deployment.InitContainers) {
-			icontainer := app.Deployment.InitContainers[name]
-			icontainer.Name = strings.ToLower(name)
-			err = app.processContainer(&icontainer, true)
-			if err != nil {
-				return
-			}
-			initContainers = append(initContainers, icontainer)
+	var initContainers []apiv1.Container
+	for _, name := range sortedKeys(app.Deployment.InitContainers) {
+		icontainer := app.Deployment.InitContainers[name]
+		icontainer.Name = strings.ToLower(name)
+		if err := app.processContainer(&icontainer, true); err != nil {
+			return apiv1.PodTemplateSpec{}, err
 		}
+		initContainers = append(initContainers, icontainer)
+	}
+
+	affinity, err := app.getAffinity()
+	if err != nil {
+		return apiv1.PodTemplateSpec{}, err
+	}
 
-		affinity, err := app.getAffinity()
+	// Roll the workload when the config it consumes changes: a checksum of the
+	// referenced ConfigMap/Secret in the pod template makes an envFrom change
+	// part of the template (#22). Computed from the rendered containers, so
+	// only the config actually wired in is hashed.
+	checksums := app.configChecksumAnnotations(append(append([]apiv1.Container{}, containers...), initContainers...))
+
+	// Shared pod-level settings, image pull secrets and grace period; the
+	// workload-specific container/init/volume fields are filled in below.
+	// processContainer mounts shared-data and app.Volumes only on app-image
+	// containers (main and init), so podVolumes builds the matching volume set.
+	podSpec := app.commonPodSpec(affinity)
+	podSpec.Containers = containers
+	podSpec.InitContainers = initContainers
+	podSpec.Volumes = app.podVolumes(replicas, containers, initContainers)
+
+	return apiv1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      app.GetColorLabels(),
+			Annotations: checksums,
+		},
+		Spec: podSpec,
+	}, nil
+}
+
+// GetDeployment resource. Nothing is emitted when the app runs as a
+// StatefulSet (GetStatefulSet renders the pods instead).
+func (app *App) GetDeployment() (deployment *appsv1.Deployment, err error) {
+	if len(app.Deployment.Containers) > 0 && !app.IsStatefulSet() {
+		template, err := app.workloadPodTemplate(app.peakReplicas())
 		if err != nil {
 			return nil, err
 		}
 
-		// Roll the Deployment when the config it consumes changes: a checksum of
-		// the referenced ConfigMap/Secret in the pod template makes an envFrom
-		// change part of the template (#22). Computed from the rendered
-		// containers, so only the config actually wired in is hashed.
-		checksums := app.configChecksumAnnotations(append(append([]apiv1.Container{}, containers...), initContainers...))
-
 		// Bound a wedged rollout so `kubectl rollout status`/kubedog reports
 		// failure instead of hanging (#46). Defaults to 15 minutes (900s) to match
 		// app2kube's default track timeout (cmd.defaultTrackTimeout), so the
@@ -168,19 +219,10 @@ func (app *App) GetDeployment() (deployment *appsv1.Deployment, err error) {
 			progressDeadline = ptr.To(int32(15 * 60))
 		}
 
-		// Shared pod-level settings, image pull secrets and grace period; the
-		// deployment-specific container/init/volume fields are filled in below.
-		// processContainer mounts shared-data and app.Volumes only on app-image
-		// containers (main and init), so podVolumes builds the matching volume set.
-		podSpec := app.commonPodSpec(affinity)
-		podSpec.Containers = containers
-		podSpec.InitContainers = initContainers
-		podSpec.Volumes = app.podVolumes(replicas, containers, initContainers)
-
 		deployment = &appsv1.Deployment{
 			ObjectMeta: app.GetObjectMeta(app.GetDeploymentName()),
 			Spec: appsv1.DeploymentSpec{
-				Replicas:                ptr.To(replicas),
+				Replicas:                app.specReplicas(),
 				RevisionHistoryLimit:    ptr.To(app.Deployment.RevisionHistoryLimit),
 				ProgressDeadlineSeconds: progressDeadline,
 				// The selector carries the full label set (GetColorLabels), matching
@@ -198,13 +240,7 @@ func (app *App) GetDeployment() (deployment *appsv1.Deployment, err error) {
 				// maxUnavailable:0 — which, combined with an auto readiness probe,
 				// could wedge the rollout of an app slow to accept connections.
 				Strategy: app.Deployment.Strategy,
-				Template: apiv1.PodTemplateSpec{
-					ObjectMeta: metav1.ObjectMeta{
-						Labels:      app.GetColorLabels(),
-						Annotations: checksums,
-					},
-					Spec: podSpec,
-				},
+				Template: template,
 			},
 		}
 
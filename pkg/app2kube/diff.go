@@ -0,0 +1,253 @@
+package app2kube
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Check renders the imported values like the manifest command and compares
+// the result with the imported objects: the round trip of an import. It
+// returns the differences, an object at a time, each line a value the
+// rendered object changes (~), adds (+) or drops (-), by its path; a list of
+// named items, such as containers or env, is compared by name. Objects are
+// paired by kind and name, then in order, so a renamed one is compared too.
+func (imp *Import) Check() ([]string, error) {
+	if imp.objects == nil {
+		return nil, errors.New("nothing to compare: the values are not imported from manifests")
+	}
+	if err := validateValues(ValuesSchema(), imp.Values, nil); err != nil {
+		return nil, err
+	}
+	raw, err := yaml.Marshal(imp.Values)
+	if err != nil {
+		return nil, err
+	}
+	app := NewApp()
+	if err := yaml.Unmarshal(raw, app); err != nil {
+		return nil, err
+	}
+	if err := app.finishLoad(); err != nil {
+		return nil, err
+	}
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := decodeObjects([]byte(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest leaves the namespace out.
+	objects := slices.DeleteFunc(slices.Clone(imp.objects), func(obj map[string]any) bool {
+		return objectKind(obj) == "Namespace"
+	})
+	return diffObjects(objects, rendered), nil
+}
+
+// diffObjects returns the differences between the objects want and the
+// objects got.
+func diffObjects(want, got []map[string]any) []string {
+	pairs := make([]int, len(want))
+	used := make([]bool, len(got))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	match := func(same func(w, g map[string]any) bool) {
+		for i, w := range want {
+			if pairs[i] >= 0 {
+				continue
+			}
+			for j, g := range got {
+				if !used[j] && same(w, g) {
+					pairs[i], used[j] = j, true
+					break
+				}
+			}
+		}
+	}
+	match(func(w, g map[string]any) bool {
+		return objectKind(w) == objectKind(g) && objectName(w) == objectName(g)
+	})
+	match(func(w, g map[string]any) bool { return objectKind(w) == objectKind(g) })
+
+	var lines []string
+	for i, w := range want {
+		ref := objectKind(w) + "/" + objectName(w)
+		if pairs[i] < 0 {
+			lines = append(lines, "- "+ref+": not rendered")
+			continue
+		}
+		g := got[pairs[i]]
+		var changes []string
+		diffValues("", normalizeObject(w), normalizeObject(g), &changes)
+		header := ref + ":"
+		if objectName(g) != objectName(w) {
+			header = ref + " → " + objectKind(g) + "/" + objectName(g) + ":"
+		} else if len(changes) == 0 {
+			continue
+		}
+		lines = append(lines, header)
+		for _, change := range changes {
+			lines = append(lines, "  "+change)
+		}
+	}
+	for j, g := range got {
+		if !used[j] {
+			lines = append(lines, "+ "+objectKind(g)+"/"+objectName(g)+": not in the manifests")
+		}
+	}
+	return lines
+}
+
+// serverFields are the fields the API server fills in, left out of the
+// comparison so a live object compares like its manifest.
+var serverFields = map[string][]string{
+	"metadata":              {"creationTimestamp", "deletionGracePeriodSeconds", "finalizers", "generateName", "generation", "managedFields", "ownerReferences", "resourceVersion", "selfLink", "uid"},
+	"Service":               {"clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy"},
+	"PersistentVolumeClaim": {"volumeName"},
+}
+
+// normalizeObject returns an object as compared: decoded into its Kubernetes
+// type, when known, so quantities and defaults are written alike, without
+// empty values, status, server-side fields and managed annotations.
+func normalizeObject(obj map[string]any) map[string]any {
+	value := pruneEmpty(copyValues(obj))
+	apiVersion, _ := obj["apiVersion"].(string)
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+		if typed, err := scheme.Scheme.New(gv.WithKind(objectKind(obj))); err == nil && convert(obj, typed) == nil {
+			value = toValue(typed)
+		}
+	}
+	m, _ := value.(map[string]any)
+	if m == nil {
+		return map[string]any{}
+	}
+	delete(m, "status")
+	meta := objectMeta(m)
+	for _, field := range serverFields["metadata"] {
+		delete(meta, field)
+	}
+	// The names are compared when pairing the objects.
+	delete(meta, "name")
+	if spec, ok := m["spec"].(map[string]any); ok {
+		for _, field := range serverFields[objectKind(obj)] {
+			delete(spec, field)
+		}
+	}
+	dropManaged(m)
+	return m
+}
+
+// dropManaged removes the managed annotations and the creationTimestamp of
+// every object metadata in value.
+func dropManaged(value any) {
+	switch val := value.(type) {
+	case map[string]any:
+		delete(val, "creationTimestamp")
+		if annotations, ok := val["annotations"].(map[string]any); ok {
+			for key := range annotations {
+				if managedAnnotation(key) {
+					delete(annotations, key)
+				}
+			}
+			if len(annotations) == 0 {
+				delete(val, "annotations")
+			}
+		}
+		for _, child := range val {
+			dropManaged(child)
+		}
+	case []any:
+		for _, child := range val {
+			dropManaged(child)
+		}
+	}
+}
+
+// diffValues appends the differences between a and b at path to changes.
+func diffValues(path string, a, b any, changes *[]string) {
+	am, aMap := a.(map[string]any)
+	bm, bMap := b.(map[string]any)
+	if aMap && bMap {
+		keys := sortedKeys(am)
+		for _, k := range sortedKeys(bm) {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			diffValues(joinValuePath(path, k), am[k], bm[k], changes)
+		}
+		return
+	}
+	al, aList := a.([]any)
+	bl, bList := b.([]any)
+	if aList && bList {
+		if names, ok := itemNames(al, bl); ok {
+			for _, name := range names {
+				diffValues(path+"["+name+"]", namedItem(al, name), namedItem(bl, name), changes)
+			}
+			return
+		}
+		for i := range max(len(al), len(bl)) {
+			var ai, bi any
+			if i < len(al) {
+				ai = al[i]
+			}
+			if i < len(bl) {
+				bi = bl[i]
+			}
+			diffValues(path+"["+strconv.Itoa(i)+"]", ai, bi, changes)
+		}
+		return
+	}
+	switch {
+	case reflect.DeepEqual(a, b):
+	case a == nil:
+		*changes = append(*changes, fmt.Sprintf("+ %s: %s", path, compactValue(b)))
+	case b == nil:
+		*changes = append(*changes, fmt.Sprintf("- %s: %s", path, compactValue(a)))
+	default:
+		*changes = append(*changes, fmt.Sprintf("~ %s: %s → %s", path, compactValue(a), compactValue(b)))
+	}
+}
+
+// itemNames returns the names of the items of two lists, the ones of a first,
+// when every item of both is an object with a name unique in its list.
+func itemNames(a, b []any) ([]string, bool) {
+	var names []string
+	for _, list := range [][]any{a, b} {
+		seen := map[string]bool{}
+		for _, item := range list {
+			m, _ := item.(map[string]any)
+			name, ok := m["name"].(string)
+			if !ok || seen[name] {
+				return nil, false
+			}
+			seen[name] = true
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, true
+}
+
+// namedItem returns the item of list with the name, or nil.
+func namedItem(list []any, name string) any {
+	for _, item := range list {
+		if item.(map[string]any)["name"] == name {
+			return item
+		}
+	}
+	return nil
+}
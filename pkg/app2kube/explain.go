@@ -0,0 +1,263 @@
+package app2kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	yamlv3 "go.yaml.in/yaml/v3"
+)
+
+// valueOrigin is one layer setting a value: a value file, with the line when
+// known, or a flag with its argument.
+type valueOrigin struct {
+	source string
+	line   int
+	value  any
+}
+
+func (o valueOrigin) String() string {
+	if o.line > 0 {
+		return o.source + ":" + strconv.Itoa(o.line)
+	}
+	return o.source
+}
+
+// valueOrigins maps the path of every value to the layers that set it, in
+// merge order: the last one is in effect, the earlier ones were overridden.
+type valueOrigins map[string][]valueOrigin
+
+// record marks value and everything under it as set by source, merged over
+// dest, the values of the earlier layers, so the entries of a list merged by
+// key are recorded at their merged position. lines maps a path of source to
+// its line, if known.
+func (o valueOrigins) record(value, dest any, source string, lines map[string]int) {
+	o.recordAt("", "", "", value, dest, source, lines)
+}
+
+// recordAt records value at path, read from sourcePath of source, under key
+// name. A null does not override an existing value (mergeValues), so it is not
+// recorded over one.
+func (o valueOrigins) recordAt(path, sourcePath, name string, value, dest any, source string, lines map[string]int) {
+	if path != "" {
+		if value == nil && len(o[path]) > 0 {
+			return
+		}
+		o[path] = append(o[path], valueOrigin{source: source, line: lines[sourcePath], value: value})
+	}
+	switch val := value.(type) {
+	case map[string]any:
+		destMap, _ := dest.(map[string]any)
+		for k, child := range val {
+			o.recordAt(joinValuePath(path, k), joinValuePath(sourcePath, k), k, child, destMap[k], source, lines)
+		}
+	case []any:
+		destList, _ := dest.([]any)
+		positions, keyed := listPositions(name, destList, val)
+		for i, child := range val {
+			p := positions[i]
+			if p < 0 {
+				continue
+			}
+			var destChild any
+			if keyed && p < len(destList) {
+				destChild = destList[p]
+			}
+			o.recordAt(path+"["+strconv.Itoa(p)+"]", sourcePath+"["+strconv.Itoa(i)+"]", "", child, destChild, source, lines)
+		}
+	}
+}
+
+// lookup returns the layer in effect for path or its closest recorded parent.
+func (o valueOrigins) lookup(path string) string {
+	for path != "" {
+		if layers := o[path]; len(layers) > 0 {
+			return layers[len(layers)-1].String()
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return ""
+}
+
+// explain returns the comment for the value at path: the layer in effect and
+// the earlier layers it overrode, with their values.
+func (o valueOrigins) explain(path string) string {
+	layers := o[path]
+	if len(layers) == 0 {
+		return ""
+	}
+	comment := layers[len(layers)-1].String()
+	if len(layers) > 1 {
+		overridden := make([]string, 0, len(layers)-1)
+		for i := len(layers) - 2; i >= 0; i-- {
+			overridden = append(overridden, layers[i].String()+" = "+compactValue(layers[i].value))
+		}
+		comment += " (overrides " + strings.Join(overridden, ", ") + ")"
+	}
+	return comment
+}
+
+// compactValue formats a value on one line for an explain comment.
+func compactValue(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if s := string(b); len(s) <= 40 {
+		return s
+	}
+	return string(b[:37]) + "..."
+}
+
+// valueLines maps the path of every value in a YAML document to its line.
+func valueLines(text []byte) map[string]int {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(text, &doc); err != nil {
+		return nil
+	}
+	lines := map[string]int{}
+	var walk func(node *yamlv3.Node, path string)
+	walk = func(node *yamlv3.Node, path string) {
+		switch node.Kind {
+		case yamlv3.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				child := joinValuePath(path, node.Content[i].Value)
+				lines[child] = node.Content[i].Line
+				walk(node.Content[i+1], child)
+			}
+		case yamlv3.SequenceNode:
+			for i, item := range node.Content {
+				child := path + "[" + strconv.Itoa(i) + "]"
+				lines[child] = item.Line
+				walk(item, child)
+			}
+		}
+	}
+	walk(&doc, "")
+	return lines
+}
+
+// lookupValue returns the value at a dotted path such as
+// "deployment.containers.app.ports[0]".
+func lookupValue(values map[string]any, path string) (any, bool) {
+	var current any = values
+	for path != "" {
+		var ok bool
+		switch val := current.(type) {
+		case map[string]any:
+			var key string
+			if key, path, ok = nextValueKey(val, path); ok {
+				current = val[key]
+			}
+		case []any:
+			var i int
+			if i, path, ok = nextValueIndex(val, path); ok {
+				current = val[i]
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// nextValueKey matches the key of m that path starts with and returns it with
+// the rest of the path. Keys may contain dots themselves (label names); the
+// longest matching key wins.
+func nextValueKey(m map[string]any, path string) (key, rest string, ok bool) {
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, k := range keys {
+		if path == k || strings.HasPrefix(path, k+".") || strings.HasPrefix(path, k+"[") {
+			return k, strings.TrimPrefix(strings.TrimPrefix(path, k), "."), true
+		}
+	}
+	return "", "", false
+}
+
+// nextValueIndex matches the "[i]" index of l that path starts with and
+// returns it with the rest of the path.
+func nextValueIndex(l []any, path string) (i int, rest string, ok bool) {
+	end := strings.Index(path, "]")
+	if !strings.HasPrefix(path, "[") || end < 0 {
+		return 0, "", false
+	}
+	i, err := strconv.Atoi(path[1:end])
+	if err != nil || i < 0 || i >= len(l) {
+		return 0, "", false
+	}
+	return i, strings.TrimPrefix(path[end+1:], "."), true
+}
+
+// ExplainValues merges the value sources like LoadValueSources and returns the
+// merged values, or those under path (e.g. "deployment.replicaCount"), as YAML
+// with a comment on every value naming the file and line or the flag that set
+// it and the earlier layers it overrode, after a header listing the
+// environment overlays merged. It neither validates the values nor loads them
+// into the App, so it also explains values LoadValueSources rejects.
+func (app *App) ExplainValues(src ValueSources, path string) (string, error) {
+	loaded, err := vals(src, app.templatePolicy)
+	if err != nil {
+		return "", err
+	}
+	if err := loaded.requireApp(src); err != nil {
+		return "", err
+	}
+	origins := loaded.origins
+	var root any = loaded.values
+	if path != "" {
+		var ok bool
+		if root, ok = lookupValue(loaded.values, path); !ok {
+			return "", fmt.Errorf("no value at %s", path)
+		}
+	}
+
+	var doc yamlv3.Node
+	if err := doc.Encode(root); err != nil {
+		return "", err
+	}
+	var annotate func(node *yamlv3.Node, path string)
+	annotate = func(node *yamlv3.Node, path string) {
+		switch {
+		case node.Kind == yamlv3.MappingNode && len(node.Content) > 0:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				annotate(node.Content[i+1], joinValuePath(path, node.Content[i].Value))
+			}
+		case node.Kind == yamlv3.SequenceNode && len(node.Content) > 0:
+			for i, item := range node.Content {
+				annotate(item, path+"["+strconv.Itoa(i)+"]")
+			}
+		default:
+			node.LineComment = origins.explain(path)
+		}
+	}
+	annotate(&doc, path)
+
+	var out strings.Builder
+	if len(loaded.overlays) > 0 {
+		out.WriteString("# overlays: " + strings.Join(loaded.overlays, ", ") + "\n")
+	}
+	if path != "" {
+		out.WriteString("# " + path + "\n")
+	}
+	enc := yamlv3.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,90 @@
+package app2kube
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainValues(t *testing.T) {
+	files := writeValueFiles(t,
+		[2]string{"base.yaml", `name: example
+deployment:
+  replicaCount: 2
+  containers:
+    app:
+      image: example/app:v1
+labels:
+  app.kubernetes.io/part-of: shop
+`},
+		[2]string{"prod.yaml", `deployment:
+  replicaCount: 4
+  containers:
+    app: null
+`},
+	)
+	app := NewApp()
+	out, err := app.ExplainValues(ValueSources{Files: files, Values: []string{"deployment.replicaCount=6"}}, "")
+	if err != nil {
+		t.Fatalf("ExplainValues: %v", err)
+	}
+	for _, want := range []string{
+		"name: example # " + files[0] + ":1\n",
+		"  replicaCount: 6 # --set deployment.replicaCount=6 (overrides " + files[1] + ":2 = 4, " + files[0] + ":3 = 2)\n",
+		// A null in a later file does not override the image.
+		"      image: example/app:v1 # " + files[0] + ":6\n",
+		"  app.kubernetes.io/part-of: shop # " + files[0] + ":8\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("explain output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = app.ExplainValues(ValueSources{Files: files}, "labels.app.kubernetes.io/part-of")
+	if err != nil {
+		t.Fatalf("ExplainValues: %v", err)
+	}
+	if want := "# labels.app.kubernetes.io/part-of\nshop # " + files[0] + ":8\n"; out != want {
+		t.Errorf("explain of a path:\ngot:\n%s\nwant:\n%s", out, want)
+	}
+
+	if _, err := app.ExplainValues(ValueSources{Files: files}, "deployment.missing"); err == nil {
+		t.Errorf("expected an error for a path without a value")
+	}
+}
+
+func TestExplainValuesOverlays(t *testing.T) {
+	files := writeValueFiles(t,
+		[2]string{"base.yml", "name: example\nstaging: qa\n"},
+		[2]string{"qa.yml", "namespace: qa\n"},
+	)
+	out, err := NewApp().ExplainValues(ValueSources{BaseFiles: files[:1], OverlayDir: filepath.Dir(files[1])}, "namespace")
+	if err != nil {
+		t.Fatalf("ExplainValues: %v", err)
+	}
+	if want := "# overlays: " + files[1] + "\n# namespace\nqa # " + files[1] + ":1\n"; out != want {
+		t.Errorf("explain with overlays:\ngot:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestLookupValue(t *testing.T) {
+	values := map[string]any{
+		"ingress": []any{map[string]any{"host": "example.com"}},
+		"labels":  map[string]any{"app": "a", "app.kubernetes.io/name": "b"},
+	}
+	cases := map[string]any{
+		"ingress[0].host":               "example.com",
+		"labels.app":                    "a",
+		"labels.app.kubernetes.io/name": "b",
+	}
+	for path, want := range cases {
+		if got, ok := lookupValue(values, path); !ok || got != want {
+			t.Errorf("lookupValue(%q) = %v, %v; want %v", path, got, ok, want)
+		}
+	}
+	for _, path := range []string{"ingress[1]", "ingress.host", "labels.missing"} {
+		if _, ok := lookupValue(values, path); ok {
+			t.Errorf("lookupValue(%q) must fail", path)
+		}
+	}
+}
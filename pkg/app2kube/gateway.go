@@ -0,0 +1,170 @@
+package app2kube
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// HTTPRoute is a minimal, dependency-free rendering of the Gateway API
+// gateway.networking.k8s.io/v1 HTTPRoute. Like the cert-manager Certificate
+// (certificate.go) the Gateway API module is not added to go.mod; the
+// pre-filled TypeMeta lets the shared printer serialize it without the scheme.
+type HTTPRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              HTTPRouteSpec `json:"spec"`
+}
+
+// HTTPRouteSpec is the subset of the Gateway API HTTPRouteSpec app2kube emits:
+// the parent Gateway, the hostnames and one rule per ingress path.
+type HTTPRouteSpec struct {
+	Hostnames  []string          `json:"hostnames,omitempty"`
+	ParentRefs []ParentReference `json:"parentRefs"`
+	Rules      []HTTPRouteRule   `json:"rules"`
+}
+
+// ParentReference names the Gateway (and optionally its listener) a route
+// attaches to. It is also the type of common.ingress.gateway.
+type ParentReference struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace,omitempty"`
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// HTTPRouteRule routes requests matching a path prefix to a Service.
+type HTTPRouteRule struct {
+	BackendRefs []HTTPBackendRef `json:"backendRefs"`
+	Matches     []HTTPRouteMatch `json:"matches"`
+}
+
+// HTTPRouteMatch matches requests by path.
+type HTTPRouteMatch struct {
+	Path HTTPPathMatch `json:"path"`
+}
+
+// HTTPPathMatch is a path match of the given type (always PathPrefix).
+type HTTPPathMatch struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// HTTPBackendRef is the Service (in the route's namespace) a rule forwards to.
+type HTTPBackendRef struct {
+	Name string `json:"name"`
+	Port int32  `json:"port"`
+}
+
+// DeepCopyObject implements runtime.Object so the HTTPRoute can flow through
+// the same printing pipeline as the built-in kinds.
+func (r *HTTPRoute) DeepCopyObject() runtime.Object {
+	if r == nil {
+		return nil
+	}
+	out := new(HTTPRoute)
+	out.TypeMeta = r.TypeMeta
+	r.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Hostnames = slices.Clone(r.Spec.Hostnames)
+	out.Spec.ParentRefs = slices.Clone(r.Spec.ParentRefs)
+	if r.Spec.Rules != nil {
+		out.Spec.Rules = make([]HTTPRouteRule, len(r.Spec.Rules))
+		for i, rule := range r.Spec.Rules {
+			out.Spec.Rules[i] = HTTPRouteRule{
+				BackendRefs: slices.Clone(rule.BackendRefs),
+				Matches:     slices.Clone(rule.Matches),
+			}
+		}
+	}
+	return out
+}
+
+// UsesGatewayAPI reports whether common.ingress.mode asks for Gateway API
+// HTTPRoutes instead of Ingress objects.
+func (app *App) UsesGatewayAPI() bool {
+	return strings.EqualFold(app.Common.Ingress.Mode, IngressModeGateway)
+}
+
+// ingressGateway resolves the parent Gateway of an ingress entry: the
+// per-entry gateway wins over common.ingress.gateway, like clusterIssuer.
+func (app *App) ingressGateway(ing Ingress) ParentReference {
+	if ing.Gateway.Name != "" {
+		return ing.Gateway
+	}
+	return app.Common.Ingress.Gateway
+}
+
+// GetHTTPRoutes returns the HTTPRoutes rendering the `ingress:` entries in
+// gateway mode: one route per host, named like the Ingress it replaces, with
+// the aliases (suppressed under staging, IngressAliases) as extra hostnames and
+// one PathPrefix rule per entry, routed to the backend ingressBackend resolves.
+// TLS is terminated by the Gateway listeners, so the routes carry none; the
+// TLS Secrets and cert-manager Certificates are still emitted for the listeners
+// to reference.
+func (app *App) GetHTTPRoutes() (routes []*HTTPRoute, err error) {
+	if len(app.Deployment.Containers) == 0 || len(app.Service) == 0 || !app.UsesGatewayAPI() {
+		return nil, nil
+	}
+	emitted := make(map[string]*HTTPRoute)
+	for _, ing := range app.Ingress {
+		name := app.ingressObjectName(ing.Host)
+		gateway := app.ingressGateway(ing)
+		if gateway.Name == "" {
+			return routes, fmt.Errorf("common.ingress.gateway.name is required for the ingress %s in gateway mode", ing.Host)
+		}
+
+		serviceName, servicePort, err := app.ingressBackend(ing)
+		if err != nil {
+			return routes, err
+		}
+		path := ing.Path
+		if path == "" {
+			path = "/"
+		}
+		rule := HTTPRouteRule{
+			BackendRefs: []HTTPBackendRef{{Name: serviceName, Port: servicePort}},
+			Matches:     []HTTPRouteMatch{{Path: HTTPPathMatch{Type: httpRoutePathType, Value: path}}},
+		}
+
+		route, ok := emitted[name]
+		if ok {
+			// A route has a single parent here: a repeated host asking for a
+			// different Gateway cannot be represented, like a conflicting
+			// ingress class.
+			if route.Spec.ParentRefs[0] != gateway {
+				return routes, fmt.Errorf("httproute %s: conflicting gateway %q and %q for the same host", name, route.Spec.ParentRefs[0].Name, gateway.Name)
+			}
+		} else {
+			route = &HTTPRoute{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: gatewayAPIVersion,
+					Kind:       httpRouteKind,
+				},
+				ObjectMeta: app.GetObjectMeta(name),
+				Spec: HTTPRouteSpec{
+					Hostnames:  []string{ing.Host},
+					ParentRefs: []ParentReference{gateway},
+				},
+			}
+			emitted[name] = route
+			routes = append(routes, route)
+		}
+
+		// Carry the ingress annotations over (e.g. for external-dns); like
+		// GetIngress, the map is only created when there is one to write.
+		if route.Annotations == nil && len(app.Common.Ingress.Annotations)+len(ing.Annotations) > 0 {
+			route.Annotations = make(map[string]string)
+		}
+		for key, value := range app.Common.Ingress.Annotations {
+			route.Annotations[key] = value
+		}
+		for key, value := range ing.Annotations {
+			route.Annotations[key] = value
+		}
+		route.Spec.Hostnames = appendUnique(route.Spec.Hostnames, app.IngressAliases(ing)...)
+		route.Spec.Rules = append(route.Spec.Rules, rule)
+	}
+	return routes, nil
+}
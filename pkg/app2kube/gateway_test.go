@@ -0,0 +1,125 @@
+package app2kube
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const gatewayValues = `
+name: shop
+common:
+  image:
+    repository: example/app
+    tag: v1
+  ingress:
+    mode: gateway
+    letsencrypt: true
+    gateway:
+      name: public
+      namespace: gateway-system
+      sectionName: https
+deployment:
+  containers:
+    web:
+      ports:
+        - name: http
+          containerPort: 8080
+service:
+  http:
+    port: 80
+ingress:
+  - host: shop.example.com
+    aliases: [www.shop.example.com]
+  - host: shop.example.com
+    path: /api
+`
+
+// Gateway mode renders the ingress entries as one HTTPRoute per host instead
+// of Ingress objects, routing to the same backend and keeping the cert-manager
+// Certificate for the Gateway listener.
+func TestGetHTTPRoutes(t *testing.T) {
+	app := mustUnmarshalApp(t, gatewayValues)
+
+	routes, err := app.GetHTTPRoutes()
+	if err != nil {
+		t.Fatalf("GetHTTPRoutes: %v", err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("expected one route per host, got %d", len(routes))
+	}
+	route := routes[0]
+	if route.Name != "shop-shop.example.com" || route.APIVersion != "gateway.networking.k8s.io/v1" {
+		t.Errorf("route: got %s %s", route.APIVersion, route.Name)
+	}
+	if want := (ParentReference{Name: "public", Namespace: "gateway-system", SectionName: "https"}); route.Spec.ParentRefs[0] != want {
+		t.Errorf("parentRef: got %+v", route.Spec.ParentRefs[0])
+	}
+	if !slices.Equal(route.Spec.Hostnames, []string{"shop.example.com", "www.shop.example.com"}) {
+		t.Errorf("hostnames: got %v", route.Spec.Hostnames)
+	}
+	if len(route.Spec.Rules) != 2 || route.Spec.Rules[1].Matches[0].Path.Value != "/api" {
+		t.Fatalf("rules: got %+v", route.Spec.Rules)
+	}
+	if b := route.Spec.Rules[0].BackendRefs[0]; b.Name != "shop-http" || b.Port != 80 {
+		t.Errorf("backend: got %+v", b)
+	}
+
+	if ingress, _ := app.GetIngress(); len(ingress) != 0 {
+		t.Error("no Ingress expected in gateway mode")
+	}
+	if certs, _ := app.GetCertificates(); len(certs) != 1 {
+		t.Error("the Certificate must still be emitted for the Gateway listener")
+	}
+
+	m, err := app.GetManifest("yaml", OutputIngress)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	if !strings.Contains(m, "kind: HTTPRoute") {
+		t.Errorf("--type ingress must render the HTTPRoutes in gateway mode:\n%s", m)
+	}
+	if !slices.Contains(app.PruneWhitelist(), "gateway.networking.k8s.io/v1/HTTPRoute") || !slices.Contains(app.PruneWhitelist(), "networking.k8s.io/v1/Ingress") {
+		t.Errorf("gateway mode must prune HTTPRoutes and the replaced Ingresses: %v", app.PruneWhitelist())
+	}
+	if slices.Contains(NewApp().PruneWhitelist(), "gateway.networking.k8s.io/v1/HTTPRoute") {
+		t.Error("the HTTPRoute CRD must not be referenced outside gateway mode")
+	}
+}
+
+// Staging rewrites the hosts before either mode renders them, so the routes
+// follow the staging host and drop the production aliases.
+func TestGetHTTPRoutesStaging(t *testing.T) {
+	app := mustUnmarshalApp(t, gatewayValues)
+	app.Staging = Staging{Active: true, Name: "qa"}
+	if err := app.applyStaging(); err != nil {
+		t.Fatalf("applyStaging: %v", err)
+	}
+	routes, err := app.GetHTTPRoutes()
+	if err != nil {
+		t.Fatalf("GetHTTPRoutes: %v", err)
+	}
+	if !slices.Equal(routes[0].Spec.Hostnames, []string{app.Ingress[0].Host}) || app.Ingress[0].Host == "shop.example.com" {
+		t.Errorf("staging hostnames: got %v", routes[0].Spec.Hostnames)
+	}
+}
+
+func TestGetHTTPRoutesErrors(t *testing.T) {
+	app := mustUnmarshalApp(t, gatewayValues)
+	app.Common.Ingress.Gateway = ParentReference{}
+	if _, err := app.GetHTTPRoutes(); err == nil || !strings.Contains(err.Error(), "gateway.name") {
+		t.Errorf("a missing gateway must fail, got %v", err)
+	}
+
+	app = mustUnmarshalApp(t, gatewayValues)
+	app.Ingress[1].Gateway = ParentReference{Name: "internal"}
+	if _, err := app.GetHTTPRoutes(); err == nil || !strings.Contains(err.Error(), "conflicting gateway") {
+		t.Errorf("two gateways for one host must fail, got %v", err)
+	}
+
+	app = mustUnmarshalApp(t, gatewayValues)
+	app.Common.Ingress.Mode = "istio"
+	if err := app.validate(); err == nil || !strings.Contains(err.Error(), "common.ingress.mode") {
+		t.Errorf("an unknown mode must fail validation, got %v", err)
+	}
+}
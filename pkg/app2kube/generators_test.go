@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/utils/ptr"
@@ -1170,3 +1171,111 @@ func TestCronJobContainerOrderDeterministic(t *testing.T) {
 		t.Errorf("cron container order: got %v, want %v (sorted by name)", got, want)
 	}
 }
+
+// deployment.autoscaling emits an HPA targeting the Deployment and leaves
+// spec.replicas unset so an apply does not reset the HPA's scale.
+func TestGetHorizontalPodAutoscaler(t *testing.T) {
+	app := deployApp(t)
+	app.Deployment.ReplicaCount = ptr.To(int32(5))
+	app.Deployment.Autoscaling = AutoscalingSpec{
+		Enabled:                        true,
+		MinReplicas:                    ptr.To(int32(2)),
+		MaxReplicas:                    10,
+		TargetCPUUtilizationPercentage: ptr.To(int32(70)),
+		Metrics: []autoscalingv2.MetricSpec{{
+			Type: autoscalingv2.ExternalMetricSourceType,
+			External: &autoscalingv2.ExternalMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: "queue_depth"},
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType},
+			},
+		}},
+	}
+
+	hpa, err := app.GetHorizontalPodAutoscaler()
+	if err != nil {
+		t.Fatalf("GetHorizontalPodAutoscaler: %v", err)
+	}
+	if hpa == nil {
+		t.Fatal("expected an HPA")
+	}
+	ref := hpa.Spec.ScaleTargetRef
+	if ref.Kind != KindDeployment || ref.Name != app.GetDeploymentName() || ref.APIVersion != "apps/v1" {
+		t.Errorf("scaleTargetRef: got %+v", ref)
+	}
+	if *hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 10 {
+		t.Errorf("min/max: got %d/%d", *hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas)
+	}
+	if len(hpa.Spec.Metrics) != 2 {
+		t.Fatalf("expected CPU + external metrics, got %+v", hpa.Spec.Metrics)
+	}
+	if r := hpa.Spec.Metrics[0].Resource; r == nil || r.Name != apiv1.ResourceCPU || *r.Target.AverageUtilization != 70 {
+		t.Errorf("first metric must be the CPU utilization target: %+v", hpa.Spec.Metrics[0])
+	}
+	if hpa.Spec.Metrics[1].Type != autoscalingv2.ExternalMetricSourceType {
+		t.Errorf("custom metrics must follow the shorthands: %+v", hpa.Spec.Metrics[1])
+	}
+
+	dep, err := app.GetDeployment()
+	if err != nil {
+		t.Fatalf("GetDeployment: %v", err)
+	}
+	if dep.Spec.Replicas != nil {
+		t.Errorf("autoscaled Deployment must not pin spec.replicas, got %d", *dep.Spec.Replicas)
+	}
+
+	// The PDB is sized from minReplicas, not the ignored replicaCount.
+	pdb, err := app.GetPodDisruptionBudget()
+	if err != nil || pdb == nil {
+		t.Fatalf("minReplicas 2 must yield a PDB: %v (err %v)", pdb, err)
+	}
+	app.Deployment.Autoscaling.MinReplicas = nil
+	if pdb, _ := app.GetPodDisruptionBudget(); pdb != nil {
+		t.Error("minReplicas defaulting to 1 must not yield a PDB")
+	}
+}
+
+// The HPA follows the blue/green color and the workload kind.
+func TestGetHorizontalPodAutoscalerColorAndKind(t *testing.T) {
+	app := deployApp(t)
+	app.Deployment.BlueGreenColor = "green"
+	app.Deployment.Autoscaling = AutoscalingSpec{Enabled: true, MaxReplicas: 3}
+	hpa, err := app.GetHorizontalPodAutoscaler()
+	if err != nil {
+		t.Fatalf("GetHorizontalPodAutoscaler: %v", err)
+	}
+	if hpa.Name != "example-green" || hpa.Spec.ScaleTargetRef.Name != "example-green" {
+		t.Errorf("HPA must carry the per-color name: %q -> %q", hpa.Name, hpa.Spec.ScaleTargetRef.Name)
+	}
+
+	app.Deployment.BlueGreenColor = ""
+	app.Deployment.Kind = KindStatefulSet
+	hpa, _ = app.GetHorizontalPodAutoscaler()
+	if hpa.Spec.ScaleTargetRef.Kind != KindStatefulSet {
+		t.Errorf("HPA must target the StatefulSet, got %q", hpa.Spec.ScaleTargetRef.Kind)
+	}
+
+	app.Deployment.Autoscaling.Enabled = false
+	if hpa, _ := app.GetHorizontalPodAutoscaler(); hpa != nil {
+		t.Error("no HPA expected with autoscaling disabled")
+	}
+}
+
+func TestLoadValuesAutoscalingBounds(t *testing.T) {
+	for _, set := range [][]string{
+		{"name=example", "deployment.autoscaling.enabled=true"},
+		{"name=example", "deployment.autoscaling.enabled=true", "deployment.autoscaling.maxReplicas=2", "deployment.autoscaling.minReplicas=3"},
+	} {
+		if _, err := NewApp().LoadValues(nil, set, nil, nil); err == nil || !strings.Contains(err.Error(), "deployment.autoscaling") {
+			t.Errorf("%v: expected an autoscaling bounds error, got %v", set, err)
+		}
+	}
+
+	// Staging pins the replica count, so autoscaling is switched off.
+	app := NewApp()
+	if _, err := app.LoadValues(nil, []string{"name=example", "staging=stg", "deployment.autoscaling.enabled=true", "deployment.autoscaling.maxReplicas=4"}, nil, nil); err != nil {
+		t.Fatalf("LoadValues: %v", err)
+	}
+	if app.Deployment.Autoscaling.Enabled {
+		t.Error("staging must disable autoscaling")
+	}
+}
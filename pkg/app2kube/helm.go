@@ -0,0 +1,328 @@
+package app2kube
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"sigs.k8s.io/yaml"
+)
+
+// helmChartVersion is the version of an exported chart, to bump on release.
+const helmChartVersion = "0.1.0"
+
+// helmToken is the placeholder of the n-th chart value in the probe render of
+// HelmChart: plain lowercase, so it renders unquoted wherever it lands.
+func helmToken(n int) string {
+	return "a2kparam" + strconv.Itoa(n) + "z"
+}
+
+// helmTokens matches the placeholders of a probe render.
+var helmTokens = regexp.MustCompile(`a2kparam\d+z`)
+
+// helmScalarLine splits a line of a rendered object into its indentation,
+// list markers and key, and the scalar following them.
+var helmScalarLine = regexp.MustCompile(`^(\s*(?:- )*(?:(?:"(?:[^"\\]|\\.)*"|'[^']*'|[^\s"'][^:]*?): )?)(.+)$`)
+
+// helmChecksumLine matches the checksum annotations of a pod template.
+var helmChecksumLine = regexp.MustCompile(`^(\s*)(checksum/configmap|checksum/secret): [0-9a-f]+$`)
+
+// helmParam is a chart value a template reads: its expression and whether
+// the probe render holds its token base64-encoded, as Secret data does.
+type helmParam struct {
+	expr   string
+	base64 bool
+}
+
+// helmFuncs returns the template functions of Helm the chart templates use,
+// over sprig's: toYaml and required.
+func helmFuncs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	funcs["toYaml"] = func(v any) string {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(data), "\n")
+	}
+	funcs["required"] = func(warn string, v any) (any, error) {
+		if s, ok := v.(string); v == nil || ok && s == "" {
+			return v, errors.New(warn)
+		}
+		return v, nil
+	}
+	return funcs
+}
+
+// HelmChart returns the files of a Helm chart rendering the application, by
+// path in the chart: Chart.yaml, values.yaml and a template per object of
+// GetManifest. values.yaml holds the merged values, rawValues, with the image
+// tag, the replicas, the ingress hosts, env, configmap and secrets as loaded;
+// those are the chart values the templates read, the rest of each object is
+// as rendered. An encrypted secret is left empty in values.yaml and required
+// by the templates, so it is given at install instead of baked in. Rendered
+// with the default values, each template is the object GetManifest renders,
+// byte for byte, under its "# Kind: name" comment; helm template adds its
+// "# Source:" comment and orders the objects by kind.
+func (app *App) HelmChart(rawValues []byte) (map[string][]byte, error) {
+	values := map[string]any{}
+	if err := yaml.Unmarshal(rawValues, &values); err != nil {
+		return nil, err
+	}
+	setValue := func(path string, value any) {
+		im := &importer{values: values}
+		parent, key := im.parent(path)
+		parent[key] = value
+	}
+	setValue("common.image.tag", app.Common.Image.Tag)
+	if replicas := app.specReplicas(); replicas != nil {
+		setValue("deployment.replicaCount", *replicas)
+	}
+	entries, _ := values["ingress"].([]any)
+	if len(entries) == len(app.Ingress) {
+		for i, entry := range entries {
+			if m, ok := entry.(map[string]any); ok {
+				m["host"] = app.Ingress[i].Host
+			}
+		}
+	}
+	secrets := map[string]string{}
+	for key, value := range app.Secrets {
+		if IsEncrypted(value) {
+			value = ""
+		}
+		secrets[key] = value
+	}
+	for key, vars := range map[string]map[string]string{"env": app.Env, "configmap": app.ConfigMap, "secrets": secrets} {
+		if len(vars) > 0 {
+			setValue(key, stringValues(vars))
+		} else {
+			delete(values, key)
+		}
+	}
+	valuesYAML, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	// The templates are checked against the values as Helm reads them.
+	chartValues := map[string]any{}
+	if err := yaml.Unmarshal(valuesYAML, &chartValues); err != nil {
+		return nil, err
+	}
+
+	defaults, probe, params, err := app.helmRenders(secrets)
+	if err != nil {
+		return nil, err
+	}
+	defaultDocs, probeDocs := splitManifest(defaults), splitManifest(probe)
+	if len(defaultDocs) != len(probeDocs) {
+		return nil, errors.New("the chart values change the rendered objects")
+	}
+
+	checksums := map[string]string{
+		annotationChecksumConfigMap: helmChecksum("configmap", app.ConfigMap, chartValues),
+		annotationChecksumSecret:    helmChecksum("secrets", app.Secrets, chartValues),
+	}
+	files := map[string][]byte{
+		"values.yaml": valuesYAML,
+		"Chart.yaml": fmt.Appendf(nil, "apiVersion: v2\nname: %s\ndescription: %s exported by app2kube\ntype: application\nversion: %s\nappVersion: %s\n",
+			app.Name, app.Name, helmChartVersion, strconv.Quote(app.Common.Image.Tag)),
+	}
+	for i, doc := range defaultDocs {
+		kind, name := docObject(doc)
+		lines := strings.Split(doc, "\n")
+		probeLines := strings.Split(probeDocs[i], "\n")
+		workload := (kind == "Deployment" || kind == "StatefulSet") && name == app.GetReleaseName()
+		for j, line := range lines {
+			lines[j] = helmLine(line, probeLines, j, params, checksums, workload, chartValues)
+		}
+		// Helm splits the templates into objects at "---": the one after the
+		// comment would leave it an object of its own.
+		header, body := manifestDoc(strings.Join(lines, "\n"))
+		path := fmt.Sprintf("templates/%02d-%s-%s.yaml", i, strings.ToLower(kind), name)
+		files[path] = []byte(header + "\n" + body)
+	}
+	return files, nil
+}
+
+// helmRenders renders the manifest with the loaded values, the encrypted
+// secrets left empty, and again with a token for each chart value, which it
+// returns with their parameters. The App is restored afterwards.
+func (app *App) helmRenders(secrets map[string]string) (string, string, map[string]helmParam, error) {
+	tag, ingress := app.Common.Image.Tag, app.Ingress
+	env, configMap, stored := app.Env, app.ConfigMap, app.Secrets
+	defer func() {
+		app.Common.Image.Tag, app.Ingress = tag, ingress
+		app.Env, app.ConfigMap, app.Secrets = env, configMap, stored
+	}()
+
+	app.Secrets = secrets
+	defaults, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	params := map[string]helmParam{}
+	token := func(expr string, base64 bool) string {
+		t := helmToken(len(params))
+		params[t] = helmParam{expr: expr, base64: base64}
+		return t
+	}
+	app.Common.Image.Tag = token(".Values.common.image.tag", false)
+	app.Ingress = append([]Ingress(nil), ingress...)
+	for i := range app.Ingress {
+		app.Ingress[i].Host = token(fmt.Sprintf("(index .Values.ingress %d).host", i), false)
+	}
+	tokens := func(key string, vars map[string]string, base64 bool) map[string]string {
+		out := maps.Clone(vars)
+		for _, name := range sortedKeys(vars) {
+			// A value of several lines renders as a block: left as it is.
+			if !strings.Contains(vars[name], "\n") {
+				expr := fmt.Sprintf("(index .Values.%s %s)", key, strconv.Quote(name))
+				if key == "secrets" && IsEncrypted(stored[name]) {
+					expr = fmt.Sprintf("(required %s %s)", strconv.Quote("secrets."+name+" is encrypted in the app2kube values: set it"), expr)
+				}
+				out[name] = token(expr, base64)
+			}
+		}
+		return out
+	}
+	app.Env, app.ConfigMap = tokens("env", env, false), tokens("configmap", configMap, false)
+	app.Secrets = tokens("secrets", secrets, true)
+	probe, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return defaults, probe, params, nil
+}
+
+// splitManifest returns the objects of a manifest, each without the "---"
+// line before its "# Kind: name" comment.
+func splitManifest(manifest string) []string {
+	var docs []string
+	for manifest != "" {
+		manifest = strings.TrimPrefix(manifest, "---\n")
+		end := strings.Index(manifest, "\n---\n# ")
+		if end < 0 {
+			docs = append(docs, manifest)
+			break
+		}
+		docs = append(docs, manifest[:end+1])
+		manifest = manifest[end+1:]
+	}
+	return docs
+}
+
+// docObject returns the kind and the name of the "# Kind: name" comment
+// heading an object of a manifest.
+func docObject(doc string) (string, string) {
+	header, _, _ := strings.Cut(doc, "\n")
+	kind, name, _ := strings.Cut(strings.TrimPrefix(header, "# "), ": ")
+	return kind, name
+}
+
+// helmChecksum returns the template of a checksum annotation: the checksum
+// app2kube renders while the chart value holds its default, the checksum of
+// the value otherwise.
+func helmChecksum(key string, data map[string]string, chartValues map[string]any) string {
+	defaultJSON, _ := json.Marshal(chartValues[key])
+	return fmt.Sprintf(`{{ if eq (toJson .Values.%s) %s }}%s{{ else }}{{ toJson .Values.%s | sha256sum }}{{ end }}`,
+		key, strconv.Quote(string(defaultJSON)), dataChecksum(stringMapToBytes(data)), key)
+}
+
+// helmLine returns the template of line j of an object: the line with the
+// chart values as template expressions where the probe render holds their
+// tokens and the expressions render the line from the default values, or
+// else the line as rendered.
+func helmLine(line string, probeLines []string, j int, params map[string]helmParam, checksums map[string]string, workload bool, chartValues map[string]any) string {
+	escaped := strings.ReplaceAll(line, "{{", `{{ "{{" }}`)
+	if m := helmChecksumLine.FindStringSubmatch(line); m != nil {
+		return m[1] + m[2] + ": " + checksums[m[2]]
+	}
+	if workload && strings.HasPrefix(line, "  replicas: ") {
+		tmpl := "  replicas: {{ .Values.deployment.replicaCount | toYaml }}"
+		if renderHelmLine(tmpl, chartValues) == line {
+			return tmpl
+		}
+		return escaped
+	}
+	if j >= len(probeLines) || probeLines[j] == line {
+		return escaped
+	}
+
+	m := helmScalarLine.FindStringSubmatch(probeLines[j])
+	if m == nil || helmTokens.MatchString(m[1]) || strings.Contains(m[1], "{{") {
+		return escaped
+	}
+	var scalar string
+	if err := yaml.Unmarshal([]byte(m[2]), &scalar); err != nil {
+		return escaped
+	}
+	var expr string
+	required := false
+	if param, ok := params[helmBase64Token(scalar, params)]; ok {
+		expr = param.expr + " | toString | b64enc"
+		required = strings.HasPrefix(param.expr, "(required ")
+	} else if helmTokens.MatchString(scalar) {
+		var parts []string
+		last := 0
+		for _, loc := range helmTokens.FindAllStringIndex(scalar, -1) {
+			param, ok := params[scalar[loc[0]:loc[1]]]
+			if !ok || param.base64 {
+				return escaped
+			}
+			if loc[0] > last {
+				parts = append(parts, strconv.Quote(scalar[last:loc[0]]))
+			}
+			parts = append(parts, param.expr)
+			last = loc[1]
+		}
+		if last < len(scalar) {
+			parts = append(parts, strconv.Quote(scalar[last:]))
+		}
+		expr = "print " + strings.Join(parts, " ")
+	} else {
+		return escaped
+	}
+	tmpl := m[1] + "{{ " + expr + " | toYaml }}"
+	// An encrypted secret has no default to render.
+	if required || renderHelmLine(tmpl, chartValues) == line {
+		return tmpl
+	}
+	return escaped
+}
+
+// helmBase64Token returns the token of which scalar is the base64 encoding,
+// or "".
+func helmBase64Token(scalar string, params map[string]helmParam) string {
+	decoded, err := base64.StdEncoding.DecodeString(scalar)
+	if err != nil {
+		return ""
+	}
+	if param, ok := params[string(decoded)]; ok && param.base64 {
+		return string(decoded)
+	}
+	return ""
+}
+
+// renderHelmLine renders a template line like Helm with the chart values, or
+// returns "" when it fails.
+func renderHelmLine(tmpl string, chartValues map[string]any) string {
+	t, err := template.New("line").Funcs(helmFuncs()).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return ""
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, map[string]any{"Values": chartValues}); err != nil {
+		return ""
+	}
+	return out.String()
+}
@@ -0,0 +1,246 @@
+package app2kube
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// renderChart renders the templates of a chart like helm template, with the
+// values of the chart merged with overrides, each object after a "---" line.
+func renderChart(t *testing.T, files map[string][]byte, overrides map[string]any) (string, error) {
+	t.Helper()
+	values := map[string]any{}
+	if err := yaml.Unmarshal(files["values.yaml"], &values); err != nil {
+		t.Fatal(err)
+	}
+	helmMerge(values, overrides)
+	var out strings.Builder
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		if !strings.HasPrefix(path, "templates/") {
+			continue
+		}
+		tmpl, err := template.New(path).Funcs(helmFuncs()).Option("missingkey=zero").Parse(string(files[path]))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, map[string]any{"Values": values}); err != nil {
+			return "", err
+		}
+		out.WriteString("---\n" + buf.String())
+	}
+	return out.String(), nil
+}
+
+// helmMerge merges src into dest like Helm merges values: maps key by key,
+// anything else replaced.
+func helmMerge(dest, src map[string]any) {
+	for key, value := range src {
+		d, dOK := dest[key].(map[string]any)
+		s, sOK := value.(map[string]any)
+		if dOK && sOK {
+			helmMerge(d, s)
+		} else {
+			dest[key] = value
+		}
+	}
+}
+
+// chartObjects returns the objects of a chart rendered like helm template, by
+// their "# Kind: name" comment: each document without its leading comments,
+// among them the "# Source:" of Helm.
+func chartObjects(out string) map[string]string {
+	objs := map[string]string{}
+	for _, doc := range strings.Split("\n"+out, "\n---\n") {
+		var header string
+		lines := strings.Split(doc, "\n")
+		for len(lines) > 0 && (lines[0] == "" || strings.HasPrefix(lines[0], "#")) {
+			if !strings.HasPrefix(lines[0], "# Source: ") {
+				header = lines[0]
+			}
+			lines = lines[1:]
+		}
+		if header != "" {
+			objs[header] = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+	}
+	return objs
+}
+
+// manifestChartObjects returns the objects of a manifest rendered by
+// GetManifest like chartObjects.
+func manifestChartObjects(manifest string) map[string]string {
+	objs := map[string]string{}
+	for _, doc := range splitManifest(manifest) {
+		header, body := manifestDoc(doc)
+		objs[header] = strings.TrimSpace(body)
+	}
+	return objs
+}
+
+const helmValues = `name: shop
+common:
+  image:
+    repository: example/shop
+    tag: "1.2"
+deployment:
+  replicaCount: 3
+  containers:
+    app:
+      ports:
+        - name: http
+          containerPort: 8080
+env:
+  MODE: prod
+  PORT: "8080"
+configmap:
+  GREETING: "{{ hello }}"
+secrets:
+  API_TOKEN: abc
+ingress:
+  - host: shop.example.com
+    tlsSecretName: shop-tls
+`
+
+// helmChart returns the manifest of helmValues and the chart exporting it.
+func helmChart(t *testing.T) (string, map[string][]byte) {
+	t.Helper()
+	app := NewApp()
+	raw, err := app.LoadValueSources(ValueSources{Files: writeValueFiles(t, [2]string{"shop.yml", helmValues})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := app.HelmChart(raw)
+	if err != nil {
+		t.Fatalf("HelmChart: %v", err)
+	}
+	return manifest, files
+}
+
+func TestHelmChart(t *testing.T) {
+	manifest, files := helmChart(t)
+	if !strings.Contains(string(files["Chart.yaml"]), "name: shop\n") || !strings.Contains(string(files["Chart.yaml"]), "appVersion: \"1.2\"\n") {
+		t.Errorf("Chart.yaml:\n%s", files["Chart.yaml"])
+	}
+
+	got, err := renderChart(t, files, nil)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if objs, want := chartObjects(got), manifestChartObjects(manifest); !reflect.DeepEqual(objs, want) {
+		t.Errorf("the chart renders\n%s\nwant\n%s", got, manifest)
+	}
+	for path, content := range files {
+		if strings.HasPrefix(path, "templates/") && strings.Contains(string(content), "\n---\n") {
+			t.Errorf("%s: Helm would split the object\n%s", path, content)
+		}
+	}
+
+	deployment := string(files["templates/02-deployment-shop.yaml"])
+	for _, want := range []string{
+		"replicas: {{ .Values.deployment.replicaCount | toYaml }}",
+		`image: {{ print "example/shop:" .Values.common.image.tag | toYaml }}`,
+		`value: {{ print (index .Values.env "MODE") | toYaml }}`,
+		`checksum/secret: {{ if eq (toJson .Values.secrets) "{\"API_TOKEN\":\"abc\"}" }}`,
+	} {
+		if !strings.Contains(deployment, want) {
+			t.Errorf("no %q in the Deployment template\n%s", want, deployment)
+		}
+	}
+
+	got, err = renderChart(t, files, map[string]any{
+		"common":     map[string]any{"image": map[string]any{"tag": "1.3"}},
+		"deployment": map[string]any{"replicaCount": 5},
+		"ingress":    []any{map[string]any{"host": "shop.example.org", "tlsSecretName": "shop-tls"}},
+		"configmap":  map[string]any{"GREETING": "hi"},
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{"image: example/shop:1.3", "replicas: 5", "host: shop.example.org", "GREETING: hi"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("no %q in the chart rendered with other values\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "shop.example.com") {
+		t.Errorf("the chart renders the default host with another one\n%s", got)
+	}
+}
+
+// Rendered by helm template, when Helm is installed, the chart renders the
+// objects of the manifest.
+func TestHelmChartTemplate(t *testing.T) {
+	helm, err := exec.LookPath("helm")
+	if err != nil {
+		t.Skip("helm is not installed")
+	}
+	manifest, files := helmChart(t)
+	dir := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := exec.Command(helm, "template", "shop", dir).Output()
+	if err != nil {
+		t.Fatalf("helm template: %v", err)
+	}
+	if objs, want := chartObjects(string(out)), manifestChartObjects(manifest); !reflect.DeepEqual(objs, want) {
+		t.Errorf("helm template renders\n%s\nwant\n%s", out, manifest)
+	}
+}
+
+// An encrypted secret is a required value of the chart.
+func TestHelmChartEncryptedSecret(t *testing.T) {
+	t.Setenv(EnvPassword, "helm")
+	encrypted, err := NewApp().EncryptSecret("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp()
+	raw, err := app.LoadValueSources(ValueSources{Files: writeValueFiles(t, [2]string{"shop.yml", `name: shop
+common:
+  image:
+    repository: example/shop
+secrets:
+  API_TOKEN: ` + encrypted + `
+`})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := app.HelmChart(raw)
+	if err != nil {
+		t.Fatalf("HelmChart: %v", err)
+	}
+	if values := string(files["values.yaml"]); strings.Contains(values, encrypted) || !strings.Contains(values, `API_TOKEN: ""`) {
+		t.Errorf("values.yaml:\n%s", values)
+	}
+	if _, err := renderChart(t, files, nil); err == nil || !strings.Contains(err.Error(), "secrets.API_TOKEN is encrypted in the app2kube values: set it") {
+		t.Errorf("expected the secret to be required, got %v", err)
+	}
+	got, err := renderChart(t, files, map[string]any{"secrets": map[string]any{"API_TOKEN": "abc"}})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(got, "API_TOKEN: YWJj") {
+		t.Errorf("the secret is not rendered\n%s", got)
+	}
+}
@@ -0,0 +1,123 @@
+package app2kube
+
+import (
+	"fmt"
+	"strings"
+
+	batch "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// HookPhase is the point of `apply`/`delete` at which a hook Job runs.
+type HookPhase string
+
+// Hook phases, in the order a full apply-then-delete lifecycle reaches them.
+const (
+	HookPreApply  HookPhase = "pre-apply"
+	HookPostApply HookPhase = "post-apply"
+	HookPreDelete HookPhase = "pre-delete"
+)
+
+// HookPhases lists every hook phase in lifecycle order.
+var HookPhases = []HookPhase{HookPreApply, HookPostApply, HookPreDelete}
+
+// hooks returns the hook definitions configured for phase.
+func (app *App) hooks(phase HookPhase) map[string]HookSpec {
+	switch phase {
+	case HookPreApply:
+		return app.Hooks.PreApply
+	case HookPostApply:
+		return app.Hooks.PostApply
+	case HookPreDelete:
+		return app.Hooks.PreDelete
+	}
+	return nil
+}
+
+// HasHooks reports whether any hook is configured for phase.
+func (app *App) HasHooks(phase HookPhase) bool {
+	return len(app.hooks(phase)) > 0
+}
+
+// GetHookJobs returns the hook Jobs of one phase in sorted hook-name order.
+// They are not part of the applied manifest: the CLI creates each Job itself,
+// waits for it and deletes it once it succeeded. A hook runs the app image with
+// the same injected config as the Deployment (processContainer), so the Job
+// names are fixed ("<release>-<hook>") and a rerun replaces the previous Job —
+// its pod template is immutable.
+func (app *App) GetHookJobs(phase HookPhase) (jobs []*batch.Job, err error) {
+	hooks := app.hooks(phase)
+	// Same collision rule as the CronJobs: two hook keys collapsing to one name
+	// would make the second hook replace the first.
+	usedNames := make(map[string]string, len(hooks))
+	for _, hookName := range sortedKeys(hooks) {
+		hook := hooks[hookName]
+		lowerName := strings.ToLower(hookName)
+		// The Job name is also the value of the job-name label its pods get,
+		// so it is capped at the label limit rather than the subdomain one.
+		jobName := truncateName(app.releaseName() + "-" + lowerName)
+
+		if other, ok := usedNames[jobName]; ok {
+			return jobs, fmt.Errorf("%s hook name collision: %q and %q both map to %q (shorten one of the hook names)", phase, other, hookName, jobName)
+		}
+		usedNames[jobName] = hookName
+
+		if len(hook.Container.Command) == 0 {
+			return jobs, fmt.Errorf("command required for the container of %s hook %q", phase, hookName)
+		}
+
+		// A migration is not blindly retried: a failed hook aborts the deploy
+		// and is left for inspection instead of being rerun up to six times.
+		if hook.BackoffLimit == nil {
+			hook.BackoffLimit = ptr.To(int32(0))
+		}
+
+		container := hook.Container
+		if err := app.processContainer(&container, false); err != nil {
+			return jobs, err
+		}
+		if container.Name == "" {
+			container.Name = lowerName
+		}
+		containers := []apiv1.Container{container}
+		app.dropClaimTemplateMounts(containers)
+
+		affinity, err := app.getAffinity()
+		if err != nil {
+			return nil, err
+		}
+
+		podSpec := app.commonPodSpec(affinity)
+		podSpec.Containers = containers
+		podSpec.RestartPolicy = apiv1.RestartPolicyNever
+		podSpec.Volumes = app.podVolumes(1, containers)
+
+		labels := make(map[string]string, len(app.Labels)+1)
+		for k, v := range app.Labels {
+			labels[k] = v
+		}
+		labels[LabelHook] = string(phase)
+
+		meta := app.GetObjectMeta(jobName)
+		meta.Labels = labels
+
+		jobs = append(jobs, &batch.Job{
+			ObjectMeta: meta,
+			Spec: batch.JobSpec{
+				ActiveDeadlineSeconds: hook.ActiveDeadlineSeconds,
+				BackoffLimit:          hook.BackoffLimit,
+				Template: apiv1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						// Label hook pods like the cronjob pods so status and
+						// the pod listing show them (#12).
+						Labels: labels,
+					},
+					Spec: podSpec,
+				},
+			},
+		})
+	}
+	return jobs, nil
+}
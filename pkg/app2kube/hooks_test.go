@@ -0,0 +1,91 @@
+package app2kube
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func hookApp(t *testing.T) *App {
+	t.Helper()
+	app := deployApp(t)
+	app.Common.Image.Repository = "example/app"
+	app.Common.Image.Tag = "v2"
+	app.ConfigMap = map[string]string{"DB_HOST": "db"}
+	app.Hooks.PreApply = map[string]HookSpec{
+		"Migrate": {Container: apiv1.Container{Command: []string{"rails", "db:migrate"}}},
+		"seed":    {Container: apiv1.Container{Command: []string{"rails", "db:seed"}}},
+	}
+	return app
+}
+
+// A hook runs the app image with the Deployment's injected config, never
+// retries by default and is labeled with its phase.
+func TestGetHookJobs(t *testing.T) {
+	app := hookApp(t)
+
+	jobs, err := app.GetHookJobs(HookPreApply)
+	if err != nil {
+		t.Fatalf("GetHookJobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Name != "example-migrate" || jobs[1].Name != "example-seed" {
+		t.Fatalf("expected example-migrate, example-seed in sorted order, got %d jobs", len(jobs))
+	}
+	job := jobs[0]
+	if job.Labels[LabelHook] != string(HookPreApply) || job.Labels[LabelManagedBy] != ManagedByValue {
+		t.Errorf("job labels: got %v", job.Labels)
+	}
+	if _, ok := app.Labels[LabelHook]; ok {
+		t.Error("the hook label must not leak into the shared app labels")
+	}
+	if *job.Spec.BackoffLimit != 0 {
+		t.Errorf("default backoffLimit: got %d, want 0", *job.Spec.BackoffLimit)
+	}
+	pod := job.Spec.Template.Spec
+	if pod.RestartPolicy != apiv1.RestartPolicyNever {
+		t.Errorf("restartPolicy: got %q", pod.RestartPolicy)
+	}
+	c := pod.Containers[0]
+	if c.Name != "migrate" || c.Image != "example/app:v2" {
+		t.Errorf("container: got name=%q image=%q", c.Name, c.Image)
+	}
+	if len(c.EnvFrom) == 0 || c.EnvFrom[0].ConfigMapRef == nil {
+		t.Errorf("hook must get the app's envFrom like the Deployment: %+v", c.EnvFrom)
+	}
+
+	if jobs, err := app.GetHookJobs(HookPreDelete); err != nil || len(jobs) != 0 {
+		t.Errorf("no pre-delete hooks configured: got %d (err %v)", len(jobs), err)
+	}
+	if !app.HasHooks(HookPreApply) || app.HasHooks(HookPostApply) {
+		t.Error("HasHooks must reflect the configured phases")
+	}
+}
+
+func TestGetHookJobsRequiresCommand(t *testing.T) {
+	app := hookApp(t)
+	app.Hooks.PostApply = map[string]HookSpec{"notify": {Container: apiv1.Container{Image: "curl"}}}
+	if _, err := app.GetHookJobs(HookPostApply); err == nil || !strings.Contains(err.Error(), "command required") {
+		t.Errorf("expected command required error, got %v", err)
+	}
+}
+
+// Hook Jobs are run by the CLI; they must stay out of the applied manifest
+// and render only under --type hook.
+func TestHookJobsOnlyUnderTypeHook(t *testing.T) {
+	app := hookApp(t)
+	all, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	if strings.Contains(all, "kind: Job") {
+		t.Error("hook Jobs must not be part of the applied manifest")
+	}
+	hooks, err := app.GetManifest("yaml", OutputHook)
+	if err != nil {
+		t.Fatalf("GetManifest hook: %v", err)
+	}
+	if strings.Count(hooks, "kind: Job") != 2 {
+		t.Errorf("expected both hook Jobs under --type hook:\n%s", hooks)
+	}
+}
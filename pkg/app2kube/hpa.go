@@ -0,0 +1,74 @@
+package app2kube
+
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// autoscalingMinReplicas returns the HPA lower bound: minReplicas when set,
+// otherwise the apiserver default of 1.
+func (app *App) autoscalingMinReplicas() int32 {
+	if app.Deployment.Autoscaling.MinReplicas != nil {
+		return *app.Deployment.Autoscaling.MinReplicas
+	}
+	return 1
+}
+
+// GetHorizontalPodAutoscaler returns an autoscaling/v2 HPA for the app's
+// workload when deployment.autoscaling.enabled is set. It shares the workload's
+// per-color name (GetDeploymentName) like the PDB, so each blue/green color
+// scales its own Deployment and `blue-green prune` removes the pair together.
+// The utilization shorthands are rendered as Resource metrics ahead of the
+// user's own metrics; with none at all the apiserver applies its 80% CPU
+// default.
+func (app *App) GetHorizontalPodAutoscaler() (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	if len(app.Deployment.Containers) == 0 || !app.Deployment.Autoscaling.Enabled {
+		return nil, nil
+	}
+	as := app.Deployment.Autoscaling
+
+	kind := KindDeployment
+	if app.IsStatefulSet() {
+		kind = KindStatefulSet
+	}
+
+	var metrics []autoscalingv2.MetricSpec
+	for _, target := range []struct {
+		resource    apiv1.ResourceName
+		utilization *int32
+	}{
+		{apiv1.ResourceCPU, as.TargetCPUUtilizationPercentage},
+		{apiv1.ResourceMemory, as.TargetMemoryUtilizationPercentage},
+	} {
+		if target.utilization == nil {
+			continue
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: target.resource,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: ptr.To(*target.utilization),
+				},
+			},
+		})
+	}
+	metrics = append(metrics, as.Metrics...)
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: app.GetObjectMeta(app.GetDeploymentName()),
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       kind,
+				Name:       app.GetDeploymentName(),
+			},
+			MinReplicas: ptr.To(app.autoscalingMinReplicas()),
+			MaxReplicas: as.MaxReplicas,
+			Metrics:     metrics,
+			Behavior:    as.Behavior,
+		},
+	}, nil
+}
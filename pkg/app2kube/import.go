@@ -0,0 +1,953 @@
+package app2kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Import is what ImportManifests makes of a set of Kubernetes objects: the
+// values rendering them and what those values cannot represent.
+type Import struct {
+	// Values are the imported values, as a value file holds them.
+	Values map[string]any
+	// Problems lists, per object, whatever the values do not carry over.
+	Problems []string
+	objects  []map[string]any
+}
+
+// importAPIVersions are the API versions of the kinds ImportManifests reads:
+// the ones app2kube renders.
+var importAPIVersions = map[string]string{
+	KindDeployment:          "apps/v1",
+	KindStatefulSet:         "apps/v1",
+	"Service":               "v1",
+	"Ingress":               "networking.k8s.io/v1",
+	"CronJob":               "batch/v1",
+	"PersistentVolumeClaim": "v1",
+}
+
+// importer builds the values of an Import from the objects, workload first,
+// so the other objects can refer to its name, ports and volumes.
+type importer struct {
+	values   map[string]any
+	problems []string
+	name     string
+	// ports maps the named container ports of the workload to their number,
+	// for Services targeting a port by name.
+	ports map[string]int32
+	// claims maps the name of a PersistentVolumeClaim the workload mounts to
+	// the volume (key) and where it is mounted.
+	claims map[string]importedClaim
+	// backends maps "<service>:<port>" of the imported Services, by port number
+	// and by name, to their service key, for the Ingress backends.
+	backends map[string]string
+	// containers are the imported containers of every pod, for env lifting.
+	containers []map[string]any
+}
+
+type importedClaim struct {
+	key, mountPath string
+	imported       bool
+}
+
+// ImportManifests reads Kubernetes objects, YAML or JSON documents or Lists,
+// and returns the values rendering them: the Deployment or StatefulSet with its
+// containers, probes and pod settings, the env shared by every container, the
+// Services, the Ingress hosts with their TLS, the CronJobs and the
+// PersistentVolumeClaims. Anything the values cannot represent is listed in
+// Problems, so the import never drops a setting silently; Check renders the
+// values and compares them with the objects.
+func ImportManifests(manifests []byte) (*Import, error) {
+	objects, err := decodeObjects(manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, errors.New("no objects to import")
+	}
+
+	im := &importer{
+		values:   map[string]any{},
+		ports:    map[string]int32{},
+		claims:   map[string]importedClaim{},
+		backends: map[string]string{},
+	}
+	im.importMeta(objects)
+
+	var workload map[string]any
+	byKind := map[string][]map[string]any{}
+	for _, obj := range objects {
+		kind := objectKind(obj)
+		if want, ok := importAPIVersions[kind]; ok && obj["apiVersion"] != want {
+			im.report(obj, "apiVersion %v is not supported (use %s)", obj["apiVersion"], want)
+			continue
+		}
+		switch kind {
+		case KindDeployment, KindStatefulSet:
+			if workload != nil {
+				im.report(obj, "only one Deployment or StatefulSet is imported, %s/%s is", objectKind(workload), objectName(workload))
+				continue
+			}
+			workload = obj
+		case "Namespace":
+			if ns, _ := im.values["namespace"].(string); ns != objectName(obj) {
+				im.report(obj, "not the namespace of the application")
+			}
+		case "Service", "Ingress", "CronJob", "PersistentVolumeClaim":
+			byKind[kind] = append(byKind[kind], obj)
+		default:
+			im.report(obj, "kind %s is not imported", kind)
+		}
+	}
+
+	if workload != nil {
+		if err := im.importWorkload(workload); err != nil {
+			return nil, err
+		}
+	}
+	for _, step := range []struct {
+		kind string
+		run  func(obj map[string]any) error
+	}{
+		{"PersistentVolumeClaim", im.importClaim},
+		{"Service", im.importService},
+		{"Ingress", im.importIngress},
+		{"CronJob", im.importCronJob},
+	} {
+		for _, obj := range byKind[step.kind] {
+			if err := step.run(obj); err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", step.kind, objectName(obj), err)
+			}
+		}
+	}
+	for _, claim := range sortedKeys(im.claims) {
+		if !im.claims[claim].imported {
+			im.problems = append(im.problems, fmt.Sprintf("%s: mounts the PersistentVolumeClaim %s, which is not among the objects", workloadRef(workload), claim))
+		}
+	}
+	im.liftEnv()
+	// The only Service is the backend of every ingress entry anyway.
+	if services, _ := im.values["service"].(map[string]any); len(services) == 1 {
+		entries, _ := im.values["ingress"].([]any)
+		for _, entry := range entries {
+			delete(entry.(map[string]any), "serviceName")
+		}
+	}
+
+	return &Import{Values: im.values, Problems: im.problems, objects: objects}, nil
+}
+
+// YAML returns the values as a value file.
+func (imp *Import) YAML() ([]byte, error) {
+	return yaml.Marshal(imp.Values)
+}
+
+// decodeObjects splits YAML or JSON documents into objects, flattening Lists.
+func decodeObjects(manifests []byte) ([]map[string]any, error) {
+	dec := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 4096)
+	var objects []map[string]any
+	// n counts the documents that are not empty, for the errors.
+	n := 0
+	for {
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("document %d: %w", n+1, err)
+		}
+		if obj == nil {
+			continue
+		}
+		n++
+		if items, ok := obj["items"].([]any); ok && strings.HasSuffix(objectKind(obj), "List") {
+			for _, item := range items {
+				if m, ok := item.(map[string]any); ok {
+					objects = append(objects, m)
+				}
+			}
+			continue
+		}
+		if objectKind(obj) == "" {
+			return nil, fmt.Errorf("document %d: an object needs a kind", n)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func objectKind(obj map[string]any) string {
+	kind, _ := obj["kind"].(string)
+	return kind
+}
+
+func objectMeta(obj map[string]any) map[string]any {
+	meta, _ := obj["metadata"].(map[string]any)
+	return meta
+}
+
+func objectName(obj map[string]any) string {
+	name, _ := objectMeta(obj)["name"].(string)
+	return name
+}
+
+func workloadRef(obj map[string]any) string {
+	if obj == nil {
+		return "the workload"
+	}
+	return objectKind(obj) + "/" + objectName(obj)
+}
+
+// report records a problem with obj.
+func (im *importer) report(obj map[string]any, format string, args ...any) {
+	im.problems = append(im.problems, objectKind(obj)+"/"+objectName(obj)+": "+fmt.Sprintf(format, args...))
+}
+
+// convert decodes a generic object into its typed form.
+func convert(obj map[string]any, typed any) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, typed)
+}
+
+// toValue returns v as values: JSON-decoded, without the empty values the
+// omitempty-less Kubernetes structs still encode.
+func toValue(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil
+	}
+	return pruneEmpty(value)
+}
+
+// pruneEmpty drops nulls, empty maps and empty lists, recursively.
+func pruneEmpty(value any) any {
+	switch val := value.(type) {
+	case map[string]any:
+		for k, child := range val {
+			if child = pruneEmpty(child); child == nil {
+				delete(val, k)
+			} else {
+				val[k] = child
+			}
+		}
+		if len(val) == 0 {
+			return nil
+		}
+	case []any:
+		if len(val) == 0 {
+			return nil
+		}
+		for i, child := range val {
+			val[i] = pruneEmpty(child)
+		}
+	}
+	return value
+}
+
+// setValue sets the value at a dotted path of keys, unless it is empty.
+func (im *importer) setValue(path string, value any) {
+	im.setKeys(strings.Split(path, "."), value)
+}
+
+// setKeys sets the value at a path of keys, unless it is empty. Unlike with
+// setValue a key may hold dots, as the names of the objects may.
+func (im *importer) setKeys(keys []string, value any) {
+	if value = pruneEmpty(value); value == nil {
+		return
+	}
+	parent, key := im.parentKeys(keys)
+	parent[key] = value
+}
+
+// parent returns the map holding the last key of a dotted path, creating the
+// maps on the way, and that key.
+func (im *importer) parent(path string) (map[string]any, string) {
+	return im.parentKeys(strings.Split(path, "."))
+}
+
+// parentKeys is parent for a path of keys.
+func (im *importer) parentKeys(keys []string) (map[string]any, string) {
+	m := im.values
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			m[key] = child
+		}
+		m = child
+	}
+	return m, keys[len(keys)-1]
+}
+
+// setContainer sets a container under the map at the path of keys, keyed by
+// its name. Unlike other values an empty container is kept: it is still a
+// container.
+func (im *importer) setContainer(keys []string, c apiv1.Container) {
+	value, _ := toValue(c).(map[string]any)
+	if value == nil {
+		value = map[string]any{}
+	}
+	delete(value, "name")
+	// Leave out what the values render by default.
+	if reflect.DeepEqual(value["securityContext"], map[string]any{"allowPrivilegeEscalation": false}) {
+		delete(value, "securityContext")
+	}
+	if value["imagePullPolicy"] == string(defaultPullPolicy(c.Image)) {
+		delete(value, "imagePullPolicy")
+	}
+	if len(c.Ports) == 1 && reflect.DeepEqual(value["livenessProbe"], toValue(&apiv1.Probe{
+		ProbeHandler:        apiv1.ProbeHandler{TCPSocket: &apiv1.TCPSocketAction{Port: intstr.FromInt32(c.Ports[0].ContainerPort)}},
+		InitialDelaySeconds: 5,
+	})) {
+		delete(value, "livenessProbe")
+	}
+	im.containers = append(im.containers, value)
+	parent, _ := im.parentKeys(append(slices.Clip(keys), c.Name))
+	parent[c.Name] = value
+}
+
+// key returns the value key of an object named after the application:
+// its name without the "<name>-" prefix.
+func (im *importer) key(objName string) string {
+	if im.name != "" {
+		if key, ok := strings.CutPrefix(objName, im.name+"-"); ok && key != "" {
+			return key
+		}
+	}
+	return objName
+}
+
+// importMeta takes the application name from the workload, or the first
+// object, and the namespace and labels the objects share.
+func (im *importer) importMeta(objects []map[string]any) {
+	first := objects[0]
+	for _, obj := range objects {
+		if kind := objectKind(obj); kind == KindDeployment || kind == KindStatefulSet {
+			first = obj
+			break
+		}
+	}
+	im.name = objectName(first)
+	im.setValue("name", im.name)
+
+	namespaces := map[string]bool{}
+	for _, obj := range objects {
+		if ns, _ := objectMeta(obj)["namespace"].(string); ns != "" {
+			namespaces[ns] = true
+		} else if objectKind(obj) == "Namespace" && len(objects) > 1 {
+			namespaces[objectName(obj)] = true
+		}
+	}
+	switch names := slices.Sorted(maps.Keys(namespaces)); len(names) {
+	case 0:
+	case 1:
+		im.setValue("namespace", names[0])
+	default:
+		im.setValue("namespace", names[0])
+		im.problems = append(im.problems, fmt.Sprintf("the objects are in several namespaces (%s): all go to %s", strings.Join(names, ", "), names[0]))
+	}
+
+	// The labels of the workload and of its pods become the labels of every
+	// object and pod, but for the ones app2kube sets itself.
+	labels := map[string]any{}
+	template, _ := first["spec"].(map[string]any)["template"].(map[string]any)
+	for _, meta := range []map[string]any{objectMeta(first), objectMeta(template)} {
+		l, _ := meta["labels"].(map[string]any)
+		for k, v := range l {
+			if k != LabelName && k != LabelManagedBy && (k != LabelInstance || v != "production") {
+				labels[k] = v
+			}
+		}
+	}
+	im.setValue("labels", labels)
+}
+
+// appLabels returns the labels of the imported values as app2kube renders
+// them, the selector of the workload and the Services.
+func (im *importer) appLabels() map[string]string {
+	labels := map[string]string{
+		LabelName:      truncateName(sanitizeDNSName(im.name)),
+		LabelInstance:  "production",
+		LabelManagedBy: ManagedByValue,
+	}
+	l, _ := im.values["labels"].(map[string]any)
+	for k, v := range l {
+		labels[k] = fmt.Sprint(v)
+	}
+	return labels
+}
+
+// importWorkload maps a Deployment or StatefulSet to deployment and common.
+func (im *importer) importWorkload(obj map[string]any) error {
+	var (
+		template apiv1.PodTemplateSpec
+		selector *metav1.LabelSelector
+	)
+	handled := []string{"selector", "template", "replicas", "revisionHistoryLimit"}
+	if objectKind(obj) == KindStatefulSet {
+		var sts appsv1.StatefulSet
+		if err := convert(obj, &sts); err != nil {
+			return err
+		}
+		template, selector = sts.Spec.Template, sts.Spec.Selector
+		im.setValue("deployment.kind", KindStatefulSet)
+		im.setValue("deployment.replicaCount", toValue(sts.Spec.Replicas))
+		im.setRevisionHistoryLimit(sts.Spec.RevisionHistoryLimit)
+		im.setValue("deployment.statefulSet.updateStrategy", toValue(sts.Spec.UpdateStrategy))
+		if sts.Spec.PodManagementPolicy != appsv1.OrderedReadyPodManagement {
+			im.setValue("deployment.statefulSet.podManagementPolicy", string(sts.Spec.PodManagementPolicy))
+		}
+		if sts.Spec.ServiceName != im.name+headlessServiceSuffix {
+			im.report(obj, "spec.serviceName %q becomes %q", sts.Spec.ServiceName, im.name+headlessServiceSuffix)
+		}
+		for _, claim := range sts.Spec.VolumeClaimTemplates {
+			im.claims[claim.Name] = importedClaim{key: claim.Name, imported: true}
+			im.setKeys([]string{"volumes", claim.Name, "spec"}, toValue(claim.Spec))
+		}
+		handled = append(handled, "serviceName", "updateStrategy", "podManagementPolicy", "volumeClaimTemplates")
+	} else {
+		var deployment appsv1.Deployment
+		if err := convert(obj, &deployment); err != nil {
+			return err
+		}
+		template, selector = deployment.Spec.Template, deployment.Spec.Selector
+		im.setValue("deployment.replicaCount", toValue(deployment.Spec.Replicas))
+		im.setRevisionHistoryLimit(deployment.Spec.RevisionHistoryLimit)
+		im.setValue("deployment.strategy", toValue(deployment.Spec.Strategy))
+		if p := deployment.Spec.ProgressDeadlineSeconds; p != nil && *p != 15*60 {
+			im.setValue("deployment.progressDeadlineSeconds", *p)
+		}
+		handled = append(handled, "strategy", "progressDeadlineSeconds")
+	}
+	// A selector is immutable: the workload must be replaced to take the
+	// selector of the app labels.
+	if selector == nil || len(selector.MatchExpressions) > 0 || !maps.Equal(selector.MatchLabels, im.appLabels()) {
+		im.report(obj, "the selector becomes the labels of the values; it is immutable, so delete the %s before the first apply", objectKind(obj))
+	}
+	spec, _ := obj["spec"].(map[string]any)
+	im.reportUnhandled(obj, "spec", spec, handled, nil)
+	if ann, ok := objectMeta(obj)["annotations"].(map[string]any); ok {
+		im.reportAnnotations(obj, "metadata", ann)
+	}
+	if len(template.Annotations) > 0 {
+		im.reportAnnotations(obj, "spec.template.metadata", toValue(template.Annotations).(map[string]any))
+	}
+
+	// Claims the pod mounts; a StatefulSet mounts its claim templates by name.
+	for _, vol := range template.Spec.Volumes {
+		if vol.PersistentVolumeClaim != nil {
+			im.claims[vol.PersistentVolumeClaim.ClaimName] = importedClaim{key: im.key(vol.PersistentVolumeClaim.ClaimName)}
+		}
+	}
+	im.importContainers(obj, "deployment.containers", template.Spec.Containers, template.Spec.Volumes)
+	im.importContainers(obj, "deployment.initContainers", template.Spec.InitContainers, template.Spec.Volumes)
+	im.importPodSpec(obj, template.Spec)
+	for _, c := range template.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name != "" {
+				im.ports[p.Name] = p.ContainerPort
+			}
+		}
+	}
+	return nil
+}
+
+// setRevisionHistoryLimit sets deployment.revisionHistoryLimit, unless it is
+// the default of the values.
+func (im *importer) setRevisionHistoryLimit(limit *int32) {
+	if limit != nil && *limit != NewApp().Deployment.RevisionHistoryLimit {
+		im.setValue("deployment.revisionHistoryLimit", *limit)
+	}
+}
+
+// importContainers sets the containers at path, keyed by name. The mounts of
+// the PersistentVolumeClaims and of the shared emptyDir are taken out: the
+// values mount those into every container.
+func (im *importer) importContainers(obj map[string]any, path string, containers []apiv1.Container, volumes []apiv1.Volume) {
+	claimOf := map[string]string{}
+	for _, vol := range volumes {
+		if vol.PersistentVolumeClaim != nil {
+			claimOf[vol.Name] = vol.PersistentVolumeClaim.ClaimName
+		}
+	}
+	shared := sharedVolume(volumes)
+	for _, c := range containers {
+		c.VolumeMounts = slices.DeleteFunc(c.VolumeMounts, func(vm apiv1.VolumeMount) bool {
+			claim, ok := claimOf[vm.Name]
+			if !ok {
+				// A StatefulSet mounts its claim templates by their name.
+				if ci, isTemplate := im.claims[vm.Name]; isTemplate && ci.imported {
+					claim, ok = vm.Name, true
+				}
+			}
+			if !ok {
+				if shared == "" || vm.Name != shared {
+					return false
+				}
+				im.sharedMount(obj, vm)
+				return true
+			}
+			ci := im.claims[claim]
+			switch {
+			case vm.SubPath != "" || vm.ReadOnly:
+				im.report(obj, "container %s mounts the volume %s with subPath or readOnly, which are not imported", c.Name, vm.Name)
+			case ci.mountPath == "":
+				ci.mountPath = vm.MountPath
+				im.claims[claim] = ci
+				im.setKeys([]string{"volumes", ci.key, "mountPath"}, vm.MountPath)
+			case ci.mountPath != vm.MountPath:
+				im.report(obj, "container %s mounts the volume %s at %s, the values mount it at %s in every container", c.Name, vm.Name, vm.MountPath, ci.mountPath)
+			}
+			return true
+		})
+		im.setContainer(strings.Split(path, "."), c)
+	}
+}
+
+// sharedVolume returns the name of the pod's only emptyDir volume, which the
+// values render as common.sharedData.
+func sharedVolume(volumes []apiv1.Volume) string {
+	var name string
+	for _, vol := range volumes {
+		if vol.EmptyDir != nil {
+			if name != "" {
+				return ""
+			}
+			name = vol.Name
+		}
+	}
+	return name
+}
+
+// sharedMount records the mount of the shared emptyDir as common.sharedData.
+func (im *importer) sharedMount(obj map[string]any, vm apiv1.VolumeMount) {
+	common, _ := im.values["common"].(map[string]any)
+	if existing, ok := common["sharedData"].(string); ok && existing != vm.MountPath {
+		im.report(obj, "the emptyDir volume %s is mounted at %s and %s, the values mount it at %s in every container", vm.Name, existing, vm.MountPath, existing)
+		return
+	}
+	im.setValue("common.sharedData", vm.MountPath)
+}
+
+// podDefaults are pod spec fields at their Kubernetes default: not worth a
+// problem, though the values do not set them.
+var podDefaults = map[string]any{
+	"restartPolicy":                 string(apiv1.RestartPolicyAlways),
+	"schedulerName":                 apiv1.DefaultSchedulerName,
+	"terminationGracePeriodSeconds": float64(apiv1.DefaultTerminationGracePeriodSeconds),
+}
+
+// importPodSpec maps the pod settings of the workload to common.
+func (im *importer) importPodSpec(obj map[string]any, spec apiv1.PodSpec) {
+	im.setValue("common.nodeSelector", toValue(spec.NodeSelector))
+	im.setValue("common.tolerations", toValue(spec.Tolerations))
+	if !reflect.DeepEqual(spec.SecurityContext, (&App{}).podSecurityContext()) {
+		im.setValue("common.securityContext", toValue(spec.SecurityContext))
+	}
+	if spec.TerminationGracePeriodSeconds != nil && *spec.TerminationGracePeriodSeconds != apiv1.DefaultTerminationGracePeriodSeconds {
+		im.setValue("common.gracePeriod", *spec.TerminationGracePeriodSeconds)
+	}
+	if spec.DNSPolicy != "" && spec.DNSPolicy != apiv1.DNSClusterFirst {
+		im.setValue("common.dnsPolicy", string(spec.DNSPolicy))
+	}
+	if spec.EnableServiceLinks == nil || *spec.EnableServiceLinks {
+		im.setValue("common.enableServiceLinks", true)
+	}
+	if spec.AutomountServiceAccountToken == nil || *spec.AutomountServiceAccountToken {
+		im.setValue("common.mountServiceAccountToken", true)
+	}
+	if name := spec.ServiceAccountName; name != "" && name != "default" {
+		im.setValue("common.serviceAccountName", name)
+	}
+	switch len(spec.ImagePullSecrets) {
+	case 0:
+	case 1:
+		im.setValue("common.image.pullSecrets", spec.ImagePullSecrets[0].Name)
+	default:
+		im.setValue("common.image.pullSecrets", spec.ImagePullSecrets[0].Name)
+		im.report(obj, "only the first of the imagePullSecrets is imported")
+	}
+
+	shared := sharedVolume(spec.Volumes)
+	for _, vol := range spec.Volumes {
+		switch {
+		case vol.PersistentVolumeClaim != nil:
+		case vol.EmptyDir != nil && vol.Name == shared:
+			if vol.EmptyDir.Medium != "" || vol.EmptyDir.SizeLimit != nil {
+				im.report(obj, "the medium and sizeLimit of the emptyDir volume %s are not imported", vol.Name)
+			}
+		default:
+			im.report(obj, "the volume %s is not imported: only PersistentVolumeClaims and one emptyDir are", vol.Name)
+		}
+	}
+
+	podSpec, _ := toValue(spec).(map[string]any)
+	im.reportUnhandled(obj, "spec.template.spec", podSpec, []string{
+		"containers", "initContainers", "volumes", "nodeSelector", "tolerations",
+		"securityContext", "terminationGracePeriodSeconds", "dnsPolicy", "enableServiceLinks",
+		"automountServiceAccountToken", "serviceAccountName", "serviceAccount", "imagePullSecrets",
+	}, podDefaults)
+}
+
+// reportUnhandled reports the fields of value at path the import does not
+// map, unless they are at their default.
+func (im *importer) reportUnhandled(obj map[string]any, path string, value map[string]any, handled []string, defaults map[string]any) {
+	for _, key := range sortedKeys(value) {
+		if slices.Contains(handled, key) {
+			continue
+		}
+		if def, ok := defaults[key]; ok && reflect.DeepEqual(def, value[key]) {
+			continue
+		}
+		im.report(obj, "%s.%s is not imported", path, key)
+	}
+}
+
+// reportAnnotations reports annotations the values cannot set, skipping the
+// ones kubectl and the controllers manage.
+func (im *importer) reportAnnotations(obj map[string]any, path string, annotations map[string]any) {
+	for _, key := range sortedKeys(annotations) {
+		if !managedAnnotation(key) {
+			im.report(obj, "the annotation %s on %s is not imported", key, path)
+		}
+	}
+}
+
+// managedAnnotation reports whether an annotation is set by kubectl, a
+// controller or app2kube itself rather than by the manifests.
+func managedAnnotation(key string) bool {
+	return key == "kubectl.kubernetes.io/last-applied-configuration" ||
+		key == "kubectl.kubernetes.io/restartedAt" ||
+		key == "deployment.kubernetes.io/revision" ||
+		strings.HasPrefix(key, "pv.kubernetes.io/") ||
+		strings.HasPrefix(key, "volume.kubernetes.io/") ||
+		strings.HasPrefix(key, "volume.beta.kubernetes.io/") ||
+		strings.HasPrefix(key, "checksum/")
+}
+
+// importClaim maps a PersistentVolumeClaim to volumes.
+func (im *importer) importClaim(obj map[string]any) error {
+	var claim apiv1.PersistentVolumeClaim
+	if err := convert(obj, &claim); err != nil {
+		return err
+	}
+	ci, ok := im.claims[claim.Name]
+	if !ok {
+		im.report(obj, "not mounted by the workload: set volumes.%s.mountPath", im.key(claim.Name))
+		ci = importedClaim{key: im.key(claim.Name)}
+	}
+	ci.imported = true
+	im.claims[claim.Name] = ci
+	im.setKeys([]string{"volumes", ci.key, "spec"}, toValue(claim.Spec))
+	return nil
+}
+
+// importService maps every port of a Service to a service entry: the values
+// render one Service per entry.
+func (im *importer) importService(obj map[string]any) error {
+	var svc apiv1.Service
+	if err := convert(obj, &svc); err != nil {
+		return err
+	}
+	if svc.Spec.ClusterIP == apiv1.ClusterIPNone {
+		if svc.Name != im.name+headlessServiceSuffix {
+			im.report(obj, "a headless Service is only rendered for a StatefulSet, as %s", im.name+headlessServiceSuffix)
+		}
+		return nil
+	}
+	if len(svc.Spec.Ports) > 1 {
+		im.report(obj, "the values render a Service per port: its %d ports become %d Services", len(svc.Spec.Ports), len(svc.Spec.Ports))
+	}
+	if len(svc.Annotations) > 0 {
+		im.reportAnnotations(obj, "metadata", toValue(svc.Annotations).(map[string]any))
+	}
+	spec, _ := obj["spec"].(map[string]any)
+	im.reportUnhandled(obj, "spec", spec, []string{
+		"ports", "type", "selector", "clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy",
+	}, map[string]any{
+		"sessionAffinity":       string(apiv1.ServiceAffinityNone),
+		"internalTrafficPolicy": string(apiv1.ServiceInternalTrafficPolicyCluster),
+		"externalTrafficPolicy": string(apiv1.ServiceExternalTrafficPolicyCluster),
+	})
+
+	services, _ := im.values["service"].(map[string]any)
+	for _, port := range svc.Spec.Ports {
+		key := im.key(svc.Name)
+		if len(svc.Spec.Ports) > 1 || key == svc.Name {
+			key = port.Name
+			if key == "" {
+				key = "port-" + strconv.Itoa(int(port.Port))
+			}
+		}
+		for n := 2; services[key] != nil; n++ {
+			key = im.key(svc.Name) + "-" + strconv.Itoa(n)
+		}
+
+		entry := map[string]any{}
+		internal := port.TargetPort.IntVal
+		if port.TargetPort.Type == intstr.String {
+			var ok bool
+			if internal, ok = im.ports[port.TargetPort.StrVal]; !ok {
+				im.report(obj, "the target port %s of port %d is not a named port of the workload", port.TargetPort.StrVal, port.Port)
+			}
+		}
+		if internal == 0 || internal == port.Port {
+			entry["port"] = port.Port
+		} else {
+			entry["externalPort"] = port.Port
+			entry["internalPort"] = internal
+		}
+		if port.Protocol != "" && port.Protocol != apiv1.ProtocolTCP {
+			entry["protocol"] = string(port.Protocol)
+		}
+		if svc.Spec.Type != "" && svc.Spec.Type != apiv1.ServiceTypeClusterIP {
+			entry["type"] = string(svc.Spec.Type)
+		}
+		if svc.Spec.Type == apiv1.ServiceTypeNodePort && port.NodePort != 0 && port.NodePort != port.Port {
+			im.report(obj, "the node port %d of port %d is not imported: the values pin the node port to the port", port.NodePort, port.Port)
+		}
+		if services == nil {
+			services = map[string]any{}
+			im.values["service"] = services
+		}
+		services[key] = entry
+		im.backends[svc.Name+":"+strconv.Itoa(int(port.Port))] = key
+		if port.Name != "" {
+			im.backends[svc.Name+":"+port.Name] = key
+		}
+	}
+	return nil
+}
+
+// importIngress maps the rules of an Ingress to ingress entries. The hosts
+// sharing the paths of the first one become its aliases.
+func (im *importer) importIngress(obj map[string]any) error {
+	var ing networkingv1.Ingress
+	if err := convert(obj, &ing); err != nil {
+		return err
+	}
+	if ing.Spec.DefaultBackend != nil {
+		im.report(obj, "spec.defaultBackend is not imported")
+	}
+
+	class := ""
+	if ing.Spec.IngressClassName != nil {
+		class = *ing.Spec.IngressClassName
+	}
+	annotations := map[string]any{}
+	sslRedirect := false
+	for key, value := range ing.Annotations {
+		switch {
+		case managedAnnotation(key):
+		case key == "kubernetes.io/ingress.class" && class == "":
+			class = value
+		case key == "nginx.ingress.kubernetes.io/ssl-redirect" && len(ing.Spec.TLS) > 0:
+			sslRedirect = value == "true"
+		default:
+			annotations[key] = value
+		}
+	}
+
+	tlsSecret := map[string]string{}
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			tlsSecret[host] = tls.SecretName
+		}
+		if len(tls.Hosts) == 0 {
+			im.report(obj, "the TLS secret %s lists no hosts and is not imported", tls.SecretName)
+		}
+	}
+
+	entries, _ := im.values["ingress"].([]any)
+	var primary *networkingv1.IngressRule
+	for i, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			im.report(obj, "the rule for %q has no paths and is not imported", rule.Host)
+			continue
+		}
+		if primary != nil && reflect.DeepEqual(rule.HTTP, primary.HTTP) && tlsSecret[rule.Host] == tlsSecret[primary.Host] {
+			for _, entry := range entries[len(entries)-len(primary.HTTP.Paths):] {
+				m := entry.(map[string]any)
+				aliases, _ := m["aliases"].([]any)
+				m["aliases"] = append(aliases, rule.Host)
+			}
+			continue
+		}
+		if primary != nil {
+			im.report(obj, "the host %s becomes an Ingress of its own", rule.Host)
+		}
+		primary = &ing.Spec.Rules[i]
+		for _, path := range rule.HTTP.Paths {
+			entry := map[string]any{"host": rule.Host}
+			if path.Path != "" && path.Path != "/" {
+				entry["path"] = path.Path
+			}
+			if path.PathType != nil && *path.PathType != networkingv1.PathTypeImplementationSpecific {
+				im.report(obj, "the pathType %s of %s%s becomes ImplementationSpecific", *path.PathType, rule.Host, path.Path)
+			}
+			if backend := path.Backend.Service; backend != nil {
+				port := backend.Port.Name
+				if port == "" {
+					port = strconv.Itoa(int(backend.Port.Number))
+				}
+				if key, ok := im.backends[backend.Name+":"+port]; ok {
+					entry["serviceName"] = key
+				} else {
+					im.report(obj, "the backend %s:%s of %s%s is not an imported Service port", backend.Name, port, rule.Host, path.Path)
+				}
+			} else {
+				im.report(obj, "the resource backend of %s%s is not imported", rule.Host, path.Path)
+			}
+			if class != "" && class != "nginx" {
+				entry["class"] = class
+			}
+			if len(annotations) > 0 {
+				entry["annotations"] = maps.Clone(annotations)
+			}
+			if secret, ok := tlsSecret[rule.Host]; ok {
+				entry["tlsSecretName"] = secret
+				if sslRedirect {
+					entry["sslRedirect"] = true
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > 0 {
+		im.values["ingress"] = entries
+	}
+	return nil
+}
+
+// importCronJob maps a CronJob to a cronjob entry.
+func (im *importer) importCronJob(obj map[string]any) error {
+	var cron batch.CronJob
+	if err := convert(obj, &cron); err != nil {
+		return err
+	}
+	key := im.key(cron.Name)
+	set := func(field string, value any) {
+		im.setKeys([]string{"cronjob", key, field}, value)
+	}
+	spec := cron.Spec
+	job := spec.JobTemplate.Spec
+	set("schedule", spec.Schedule)
+	if spec.ConcurrencyPolicy != "" && spec.ConcurrencyPolicy != batch.AllowConcurrent {
+		set("concurrencyPolicy", string(spec.ConcurrencyPolicy))
+	}
+	if spec.Suspend != nil && *spec.Suspend {
+		set("suspend", true)
+	}
+	if spec.TimeZone != nil {
+		set("timeZone", *spec.TimeZone)
+	}
+	if l := spec.SuccessfulJobsHistoryLimit; l != nil && *l != 2 {
+		set("successfulJobsHistoryLimit", *l)
+	}
+	if l := spec.FailedJobsHistoryLimit; l != nil && *l != 2 {
+		set("failedJobsHistoryLimit", *l)
+	}
+	if d := job.ActiveDeadlineSeconds; d != nil && *d != 86400 {
+		set("activeDeadlineSeconds", *d)
+	}
+	if b := job.BackoffLimit; b != nil && *b != 6 {
+		set("backoffLimit", *b)
+	}
+	pod := job.Template.Spec
+	if pod.RestartPolicy != "" && pod.RestartPolicy != apiv1.RestartPolicyNever {
+		set("restartPolicy", string(pod.RestartPolicy))
+	}
+	for _, c := range pod.Containers {
+		c.VolumeMounts = slices.DeleteFunc(c.VolumeMounts, func(vm apiv1.VolumeMount) bool {
+			for _, vol := range pod.Volumes {
+				if vol.Name == vm.Name && vol.PersistentVolumeClaim != nil {
+					return true
+				}
+			}
+			return false
+		})
+		im.setContainer([]string{"cronjob", key, "containers"}, c)
+	}
+	if len(pod.InitContainers) > 0 {
+		im.report(obj, "the init containers are not imported")
+	}
+	podSpec, _ := toValue(pod).(map[string]any)
+	im.reportUnhandled(obj, "spec.jobTemplate.spec.template.spec", podSpec, []string{
+		"containers", "initContainers", "restartPolicy", "volumes", "nodeSelector", "tolerations",
+		"securityContext", "terminationGracePeriodSeconds", "dnsPolicy", "enableServiceLinks",
+		"automountServiceAccountToken", "serviceAccountName", "serviceAccount", "imagePullSecrets",
+	}, podDefaults)
+	jobSpec, _ := toValue(job).(map[string]any)
+	im.reportUnhandled(obj, "spec.jobTemplate.spec", jobSpec, []string{"template", "activeDeadlineSeconds", "backoffLimit"}, nil)
+	return nil
+}
+
+// liftEnv moves the plain env variables every container sets to the same
+// value to env, which the values inject into all of them.
+func (im *importer) liftEnv() {
+	if len(im.containers) == 0 {
+		return
+	}
+	shared := map[string]any{}
+	for i, c := range im.containers {
+		vars := map[string]any{}
+		env, _ := c["env"].([]any)
+		for _, e := range env {
+			m, _ := e.(map[string]any)
+			name, _ := m["name"].(string)
+			value, plain := m["value"].(string)
+			if plain && len(m) == 2 {
+				vars[name] = value
+			}
+		}
+		if i == 0 {
+			shared = vars
+			continue
+		}
+		for name, value := range shared {
+			if vars[name] != value {
+				delete(shared, name)
+			}
+		}
+	}
+	if len(shared) == 0 {
+		return
+	}
+	for _, c := range im.containers {
+		env, _ := c["env"].([]any)
+		env = slices.DeleteFunc(env, func(e any) bool {
+			_, ok := shared[e.(map[string]any)["name"].(string)]
+			return ok
+		})
+		if len(env) == 0 {
+			delete(c, "env")
+		} else {
+			c["env"] = env
+		}
+	}
+	im.setValue("env", shared)
+}
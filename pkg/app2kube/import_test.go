@@ -0,0 +1,401 @@
+package app2kube
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const importManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: shop
+  namespace: web
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: shop
+  template:
+    metadata:
+      labels:
+        app: shop
+    spec:
+      nodeSelector:
+        pool: web
+      containers:
+        - name: app
+          image: example/shop:1.2
+          env:
+            - name: MODE
+              value: prod
+            - name: PORT
+              value: "8080"
+          ports:
+            - name: http
+              containerPort: 8080
+          readinessProbe:
+            httpGet:
+              path: /healthz
+              port: http
+          volumeMounts:
+            - name: data
+              mountPath: /data
+      volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: shop-data
+        - name: cfg
+          configMap:
+            name: shop-cfg
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: shop-web
+  namespace: web
+spec:
+  selector:
+    app: shop
+  ports:
+    - name: http
+      port: 80
+      targetPort: http
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: shop
+  namespace: web
+spec:
+  tls:
+    - hosts: [shop.example.com, www.shop.example.com]
+      secretName: shop-tls
+  rules:
+    - host: shop.example.com
+      http:
+        paths:
+          - path: /
+            pathType: Prefix
+            backend:
+              service:
+                name: shop-web
+                port:
+                  number: 80
+    - host: www.shop.example.com
+      http:
+        paths:
+          - path: /
+            pathType: Prefix
+            backend:
+              service:
+                name: shop-web
+                port:
+                  number: 80
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: shop-cleanup
+  namespace: web
+spec:
+  schedule: "0 3 * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+            - name: cleanup
+              image: example/shop:1.2
+              command: [./cleanup]
+              env:
+                - name: MODE
+                  value: prod
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: shop-data
+  namespace: web
+spec:
+  accessModes: [ReadWriteMany]
+  resources:
+    requests:
+      storage: 1Gi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: shop-cfg
+data:
+  a: b
+`
+
+func TestImportManifests(t *testing.T) {
+	imported, err := ImportManifests([]byte(importManifests))
+	if err != nil {
+		t.Fatalf("ImportManifests: %v", err)
+	}
+	got, err := imported.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `common:
+  enableServiceLinks: true
+  mountServiceAccountToken: true
+  nodeSelector:
+    pool: web
+cronjob:
+  cleanup:
+    containers:
+      cleanup:
+        command:
+        - ./cleanup
+        image: example/shop:1.2
+    restartPolicy: OnFailure
+    schedule: 0 3 * * *
+deployment:
+  containers:
+    app:
+      env:
+      - name: PORT
+        value: "8080"
+      image: example/shop:1.2
+      ports:
+      - containerPort: 8080
+        name: http
+      readinessProbe:
+        httpGet:
+          path: /healthz
+          port: http
+  replicaCount: 3
+env:
+  MODE: prod
+ingress:
+- aliases:
+  - www.shop.example.com
+  host: shop.example.com
+  tlsSecretName: shop-tls
+labels:
+  app: shop
+name: shop
+namespace: web
+service:
+  web:
+    externalPort: 80
+    internalPort: 8080
+volumes:
+  data:
+    mountPath: /data
+    spec:
+      accessModes:
+      - ReadWriteMany
+      resources:
+        requests:
+          storage: 1Gi
+`
+	if string(got) != want {
+		t.Errorf("values:\n%s\nwant:\n%s", got, want)
+	}
+
+	wantProblems := []string{
+		"ConfigMap/shop-cfg: kind ConfigMap is not imported",
+		"Deployment/shop: the selector becomes the labels of the values; it is immutable, so delete the Deployment before the first apply",
+		"Deployment/shop: the volume cfg is not imported: only PersistentVolumeClaims and one emptyDir are",
+		"Ingress/shop: the pathType Prefix of shop.example.com/ becomes ImplementationSpecific",
+	}
+	if !reflect.DeepEqual(imported.Problems, wantProblems) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(imported.Problems, "\n"), strings.Join(wantProblems, "\n"))
+	}
+
+	diff, err := imported.Check()
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	for _, want := range []string{
+		"Service/shop-web:",
+		"  ~ spec.rules[0].http.paths[0].pathType: \"Prefix\" → \"ImplementationSpecific\"",
+		"  - spec.template.spec.volumes[cfg]: {\"configMap\":{\"name\":\"shop-cfg\"},\"nam...",
+		"  + spec.template.spec.containers[app].livenessProbe: {\"initialDelaySeconds\":5,\"tcpSocket\":...",
+		"Ingress/shop → Ingress/shop-shop.example.com:",
+		"- ConfigMap/shop-cfg: not rendered",
+		"+ PodDisruptionBudget/shop: not in the manifests",
+	} {
+		if !slices.Contains(diff, want) {
+			t.Errorf("check: no line %q in\n%s", want, strings.Join(diff, "\n"))
+		}
+	}
+}
+
+// The manifests app2kube renders import back to values rendering them alike.
+func TestImportRoundTrip(t *testing.T) {
+	app := NewApp()
+	src := ValueSources{Files: writeValueFiles(t, [2]string{"shop.yml", `name: shop
+namespace: web
+common:
+  image:
+    repository: example/shop
+    tag: "1.2"
+env:
+  MODE: prod
+deployment:
+  replicaCount: 2
+  containers:
+    app:
+      ports:
+        - name: http
+          containerPort: 8080
+service:
+  http:
+    port: 80
+    internalPort: 8080
+ingress:
+  - host: shop.example.com
+    tlsSecretName: shop-tls
+cronjob:
+  cleanup:
+    schedule: "0 3 * * *"
+    containers:
+      job:
+        command: [./cleanup]
+volumes:
+  data:
+    mountPath: /data
+    spec:
+      accessModes: [ReadWriteMany]
+      resources:
+        requests:
+          storage: 1Gi
+`})}
+	if _, err := app.LoadValueSources(src); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportManifests([]byte(manifest))
+	if err != nil {
+		t.Fatalf("ImportManifests: %v", err)
+	}
+	if want := []string{"PodDisruptionBudget/shop: kind PodDisruptionBudget is not imported"}; !reflect.DeepEqual(imported.Problems, want) {
+		t.Errorf("problems: got %q, want %q", imported.Problems, want)
+	}
+	diff, err := imported.Check()
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(diff) > 0 {
+		t.Errorf("check: expected no differences, got\n%s", strings.Join(diff, "\n"))
+	}
+}
+
+func TestImportManifestsErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                             "no objects to import",
+		"---\napiVersion: v1\n":        "document 1: an object needs a kind",
+		"kind: List\nitems: []\n":      "no objects to import",
+		"kind: Service\nspec: [1, 2\n": "document 1:",
+	}
+	for manifests, want := range cases {
+		if _, err := ImportManifests([]byte(manifests)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", manifests, err, want)
+		}
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	var want, got []map[string]any
+	for doc, out := range map[string]*[]map[string]any{
+		`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "a", "uid": "1"}, "spec": {"ports": [{"name": "http", "port": 80}], "clusterIP": "10.0.0.1"}, "status": {}}`: &want,
+		`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "a"}, "spec": {"ports": [{"name": "http", "port": 8080}, {"name": "tls", "port": 443}]}}`:                    &got,
+	} {
+		var obj map[string]any
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			t.Fatal(err)
+		}
+		*out = append(*out, obj)
+	}
+	diff := diffObjects(want, got)
+	expected := []string{
+		"Service/a:",
+		"  ~ spec.ports[http].port: 80 → 8080",
+		`  + spec.ports[tls]: {"name":"tls","port":443,"targetPort":0}`,
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(diff, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+// A PersistentVolumeClaim or CronJob name may hold dots: they stay in the key.
+func TestImportDottedNames(t *testing.T) {
+	imported, err := ImportManifests([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: shop
+spec:
+  selector:
+    matchLabels:
+      app: shop
+  template:
+    metadata:
+      labels:
+        app: shop
+    spec:
+      containers:
+        - name: app
+          image: example/shop:1.2
+          volumeMounts:
+            - name: data
+              mountPath: /data
+      volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: shop-data.v1
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: shop-data.v1
+spec:
+  accessModes: [ReadWriteOnce]
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: shop-clean.up
+spec:
+  schedule: "0 3 * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: cleanup
+              image: example/shop:1.2
+`))
+	if err != nil {
+		t.Fatalf("ImportManifests: %v", err)
+	}
+	got, err := imported.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values struct {
+		Cronjob map[string]any
+		Volumes map[string]any
+	}
+	if err := yaml.Unmarshal(got, &values); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := values.Cronjob["clean.up"]; !ok || len(values.Cronjob) != 1 {
+		t.Errorf("cronjob: got %v, want the key clean.up", values.Cronjob)
+	}
+	if _, ok := values.Volumes["data.v1"]; !ok || len(values.Volumes) != 1 {
+		t.Errorf("volumes: got %v, want the key data.v1", values.Volumes)
+	}
+}
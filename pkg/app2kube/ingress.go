@@ -62,15 +62,68 @@ func (app *App) IngressAliases(ing Ingress) []string {
 	return ing.Aliases
 }
 
-// GetIngress resource
+// ingressBackend resolves the backend Service name and port of an ingress
+// entry in precedence order: an explicit per-entry serviceName; otherwise the
+// common default backend (common.ingress.serviceName); otherwise the sole
+// Service when exactly one is declared. A per-entry or common-default name must
+// resolve to a declared Service. Both the Ingress and the HTTPRoute generator
+// use it, so the two modes always route to the same backend.
+func (app *App) ingressBackend(ing Ingress) (serviceName string, servicePort int32, err error) {
+	servicePort = app.Common.Ingress.ServicePort
+	switch {
+	case ing.ServiceName != "":
+		svc, ok := app.Service[ing.ServiceName]
+		if !ok {
+			return "", 0, fmt.Errorf("service with name %s for the ingress %s not found", ing.ServiceName, ing.Host)
+		}
+		serviceName = app.GetServiceName(ing.ServiceName)
+		servicePort = svc.effectiveServicePort()
+	case app.Common.Ingress.ServiceName != "":
+		svc, ok := app.Service[app.Common.Ingress.ServiceName]
+		if !ok {
+			return "", 0, fmt.Errorf("default ingress service %s for the ingress %s not found", app.Common.Ingress.ServiceName, ing.Host)
+		}
+		serviceName = app.GetServiceName(app.Common.Ingress.ServiceName)
+		// Prefer an explicit common.ingress.servicePort; otherwise derive
+		// the port from the resolved Service.
+		if servicePort == 0 {
+			servicePort = svc.effectiveServicePort()
+		}
+	case len(app.Service) == 1:
+		for name, svc := range app.Service {
+			serviceName = app.GetServiceName(name)
+			servicePort = svc.effectiveServicePort()
+		}
+	default:
+		return "", 0, fmt.Errorf("you must specify a serviceName for the ingress %s", ing.Host)
+	}
+
+	if servicePort == 0 {
+		return "", 0, fmt.Errorf("you must specify a servicePort for the ingress %s", ing.Host)
+	}
+	return strings.ToLower(serviceName), servicePort, nil
+}
+
+// ingressObjectName returns the name of the Ingress (or HTTPRoute) serving
+// host. An Ingress object name is a DNS-1123 subdomain (253 chars), not a label
+// (63): cap it at the subdomain limit so a longer name is kept byte-identical to
+// what earlier releases emitted instead of being shortened to 63 (which would
+// orphan the live Ingress on apply). The canary track's Ingress carries the
+// canary suffix after the app name.
+func (app *App) ingressObjectName(host string) string {
+	name := app.Name
+	if app.canary {
+		name += canarySuffix
+	}
+	return truncateNameTo(strings.ToLower(name+"-"+wildcardHost(host)), MaxSubdomainNameLength)
+}
+
+// GetIngress resource. Nothing is emitted in gateway mode, where the same
+// entries render HTTPRoutes instead (GetHTTPRoutes).
 func (app *App) GetIngress() (ingress []*v1.Ingress, err error) {
-	if len(app.Deployment.Containers) > 0 && len(app.Service) > 0 {
+	if len(app.Deployment.Containers) > 0 && len(app.Service) > 0 && !app.UsesGatewayAPI() {
 		for _, ing := range app.Ingress {
-			// An Ingress object name is a DNS-1123 subdomain (253 chars), not a
-			// label (63): cap it at the subdomain limit so a longer name is kept
-			// byte-identical to what earlier releases emitted instead of being
-			// shortened to 63 (which would orphan the live Ingress on apply).
-			ingressName := truncateNameTo(strings.ToLower(app.Name+"-"+wildcardHost(ing.Host)), MaxSubdomainNameLength)
+			ingressName := app.ingressObjectName(ing.Host)
 
 			newIngress := &v1.Ingress{
 				ObjectMeta: app.GetObjectMeta(ingressName),
@@ -118,44 +171,13 @@ func (app *App) GetIngress() (ingress []*v1.Ingress, err error) {
 			for key, value := range ing.Annotations {
 				newIngress.Annotations[key] = value
 			}
-
-			// Resolve the backend Service for this ingress entry in precedence
-			// order: an explicit per-entry serviceName; otherwise the common
-			// default backend (common.ingress.serviceName); otherwise the sole
-			// Service when exactly one is declared. A per-entry or common-default
-			// name must resolve to a declared Service.
-			var serviceName string
-			servicePort := app.Common.Ingress.ServicePort
-			switch {
-			case ing.ServiceName != "":
-				svc, ok := app.Service[ing.ServiceName]
-				if !ok {
-					return ingress, fmt.Errorf("service with name %s for the ingress %s not found", ing.ServiceName, ing.Host)
-				}
-				serviceName = app.GetServiceName(ing.ServiceName)
-				servicePort = svc.effectiveServicePort()
-			case app.Common.Ingress.ServiceName != "":
-				svc, ok := app.Service[app.Common.Ingress.ServiceName]
-				if !ok {
-					return ingress, fmt.Errorf("default ingress service %s for the ingress %s not found", app.Common.Ingress.ServiceName, ing.Host)
-				}
-				serviceName = app.GetServiceName(app.Common.Ingress.ServiceName)
-				// Prefer an explicit common.ingress.servicePort; otherwise derive
-				// the port from the resolved Service.
-				if servicePort == 0 {
-					servicePort = svc.effectiveServicePort()
-				}
-			case len(app.Service) == 1:
-				for name, svc := range app.Service {
-					serviceName = app.GetServiceName(name)
-					servicePort = svc.effectiveServicePort()
-				}
-			default:
-				return ingress, fmt.Errorf("you must specify a serviceName for the ingress %s", ing.Host)
+			for key, value := range app.canaryIngressAnnotations() {
+				newIngress.Annotations[key] = value
 			}
 
-			if servicePort == 0 {
-				return ingress, fmt.Errorf("you must specify a servicePort for the ingress %s", ing.Host)
+			serviceName, servicePort, err := app.ingressBackend(ing)
+			if err != nil {
+				return ingress, err
 			}
 
 			if ing.Path == "" {
@@ -168,7 +190,7 @@ func (app *App) GetIngress() (ingress []*v1.Ingress, err error) {
 				PathType: &pathTypeImplementationSpecific,
 				Backend: v1.IngressBackend{
 					Service: &v1.IngressServiceBackend{
-						Name: strings.ToLower(serviceName),
+						Name: serviceName,
 						Port: v1.ServiceBackendPort{Number: servicePort},
 					},
 				},
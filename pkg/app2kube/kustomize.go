@@ -0,0 +1,339 @@
+package app2kube
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+	"sigs.k8s.io/yaml"
+)
+
+// KustomizeSecretsDir is the directory, in the base and in each overlay, of
+// the files the secretGenerators read: one per key, under the Secret name.
+// The export ignores it in git.
+const KustomizeSecretsDir = "secrets"
+
+// manifestObject is an object of a rendered manifest.
+type manifestObject struct {
+	kind, name string
+	// content is the object as rendered, under its "# Kind: name" comment.
+	content string
+	obj     map[string]any
+}
+
+// namespace returns the namespace of the object, if any.
+func (o manifestObject) namespace() string {
+	metadata, _ := o.obj["metadata"].(map[string]any)
+	namespace, _ := metadata["namespace"].(string)
+	return namespace
+}
+
+// file returns the name of the file of the object in a kustomization.
+func (o manifestObject) file() string {
+	return strings.ToLower(o.kind) + "-" + o.name + ".yaml"
+}
+
+// manifestObjects returns the objects of a manifest rendered by GetManifest.
+func manifestObjects(manifest string) ([]manifestObject, error) {
+	var objs []manifestObject
+	for _, doc := range splitManifest(manifest) {
+		kind, name := docObject(doc)
+		header, body := manifestDoc(doc)
+		obj := map[string]any{}
+		if err := yaml.Unmarshal([]byte(body), &obj); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		objs = append(objs, manifestObject{kind: kind, name: name, content: header + "\n" + body, obj: obj})
+	}
+	return objs, nil
+}
+
+// Kustomization returns the files of a Kustomize base rendering the
+// application and of an overlay rendering each of environments, by path in
+// the export: base/ holds an object per file and its kustomization.yaml,
+// overlays/<environment>/ the patches turning the base into the render of the
+// environment, computed by diffing the two. An object of an environment is
+// matched to the object of the base of the same kind and name, the release
+// name of the environment taken for that of the base, and else to the next
+// unmatched one of the same kind; the others are deleted or added. Secrets
+// are secretGenerators instead, reading a file per key from
+// KustomizeSecretsDir, which .gitignore ignores.
+func (app *App) Kustomization(environments map[string]*App) (map[string][]byte, error) {
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		return nil, err
+	}
+	base, err := manifestObjects(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if len(base) == 0 {
+		return nil, errors.New("the values render no objects to export")
+	}
+
+	files := map[string][]byte{
+		".gitignore": []byte("# The secret values of the secretGenerators\n" + KustomizeSecretsDir + "/\n"),
+	}
+	k := newKustomization()
+	for _, obj := range base {
+		if obj.kind == "Secret" {
+			gen, err := secretGenerator(obj, "base", files)
+			if err != nil {
+				return nil, err
+			}
+			k.SecretGenerator = append(k.SecretGenerator, gen)
+			continue
+		}
+		files["base/"+obj.file()] = []byte(obj.content)
+		k.Resources = append(k.Resources, obj.file())
+	}
+	if files["base/kustomization.yaml"], err = yaml.Marshal(k); err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedKeys(environments) {
+		env := environments[name]
+		manifest, err := env.GetManifest("yaml", OutputAll)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		objs, err := manifestObjects(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := kustomizeOverlay("overlays/"+sanitizeDNSName(name), base, objs,
+			app.GetReleaseName(), env.GetReleaseName(), files); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return files, nil
+}
+
+// newKustomization returns an empty kustomization.yaml.
+func newKustomization() *types.Kustomization {
+	return &types.Kustomization{TypeMeta: types.TypeMeta{
+		APIVersion: types.KustomizationVersion,
+		Kind:       types.KustomizationKind,
+	}}
+}
+
+// kustomizeOverlay adds to files the overlay in dir turning the objects of
+// the base, of release baseRelease, into objs, of release envRelease.
+func kustomizeOverlay(dir string, base, objs []manifestObject, baseRelease, envRelease string, files map[string][]byte) error {
+	k := newKustomization()
+	k.Resources = []string{"../../base"}
+
+	// kept are the objects of objs made of those of the base.
+	kept := make([]bool, len(objs))
+	pairs := pairObjects(base, objs, baseRelease, envRelease)
+	for i, from := range base {
+		j, matched := pairs[i]
+		// A Secret of another name replaces that of the base.
+		if !matched || from.kind == "Secret" && objs[j].name != from.name {
+			patch := map[string]any{
+				"$patch":     "delete",
+				"apiVersion": from.obj["apiVersion"],
+				"kind":       from.kind,
+				"metadata":   objectMetadata(from),
+			}
+			data, err := yaml.Marshal(patch)
+			if err != nil {
+				return err
+			}
+			files[dir+"/patches/"+from.file()] = data
+			k.Patches = append(k.Patches, types.Patch{Path: "patches/" + from.file()})
+			continue
+		}
+		to := objs[j]
+		kept[j] = true
+		if from.kind == "Secret" {
+			if reflect.DeepEqual(from.obj, to.obj) {
+				continue
+			}
+			gen, err := secretGenerator(to, dir, files)
+			if err != nil {
+				return err
+			}
+			gen.Behavior = types.BehaviorReplace.String()
+			k.SecretGenerator = append(k.SecretGenerator, gen)
+			continue
+		}
+		ops := jsonPatch("", from.obj, to.obj)
+		if len(ops) == 0 {
+			continue
+		}
+		data, err := yaml.Marshal(ops)
+		if err != nil {
+			return err
+		}
+		files[dir+"/patches/"+from.file()] = data
+		patch := types.Patch{
+			Path: "patches/" + from.file(),
+			Target: &types.Selector{ResId: resid.ResId{
+				Gvk:       resid.Gvk{Kind: from.kind},
+				Name:      from.name,
+				Namespace: from.namespace(),
+			}},
+		}
+		if to.name != from.name {
+			patch.Options = map[string]bool{"allowNameChange": true}
+		}
+		k.Patches = append(k.Patches, patch)
+	}
+
+	for j, obj := range objs {
+		switch {
+		case kept[j]:
+		case obj.kind == "Secret":
+			gen, err := secretGenerator(obj, dir, files)
+			if err != nil {
+				return err
+			}
+			k.SecretGenerator = append(k.SecretGenerator, gen)
+		default:
+			files[dir+"/"+obj.file()] = []byte(obj.content)
+			k.Resources = append(k.Resources, obj.file())
+		}
+	}
+
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return err
+	}
+	files[dir+"/kustomization.yaml"] = data
+	return nil
+}
+
+// pairObjects returns, by index in base, the index of the object of objs each
+// becomes: of the same kind and name, envRelease taken for baseRelease, or
+// else the next of the same kind neither matches.
+func pairObjects(base, objs []manifestObject, baseRelease, envRelease string) map[int]int {
+	baseName := func(name string) string {
+		if name == envRelease || strings.HasPrefix(name, envRelease+"-") {
+			return baseRelease + strings.TrimPrefix(name, envRelease)
+		}
+		return name
+	}
+	pairs := map[int]int{}
+	used := make([]bool, len(objs))
+	for i, from := range base {
+		for j, to := range objs {
+			if !used[j] && to.kind == from.kind && (to.name == from.name || baseName(to.name) == from.name) {
+				pairs[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	for i, from := range base {
+		if _, ok := pairs[i]; ok {
+			continue
+		}
+		for j, to := range objs {
+			if !used[j] && to.kind == from.kind {
+				pairs[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// objectMetadata returns the name and the namespace, if any, of an object.
+func objectMetadata(o manifestObject) map[string]any {
+	metadata := map[string]any{"name": o.name}
+	if namespace := o.namespace(); namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return metadata
+}
+
+// secretGenerator returns the secretGenerator of a rendered Secret, adding to
+// files a file per key under the KustomizeSecretsDir of dir. It keeps the
+// name, the labels and the annotations of the Secret.
+func secretGenerator(o manifestObject, dir string, files map[string][]byte) (types.SecretArgs, error) {
+	gen := types.SecretArgs{GeneratorArgs: types.GeneratorArgs{
+		Namespace: o.namespace(),
+		Name:      o.name,
+		Options:   &types.GeneratorOptions{DisableNameSuffixHash: true},
+	}}
+	gen.Type, _ = o.obj["type"].(string)
+	metadata, _ := o.obj["metadata"].(map[string]any)
+	gen.Options.Labels = stringMap(metadata["labels"])
+	gen.Options.Annotations = stringMap(metadata["annotations"])
+	data, _ := o.obj["data"].(map[string]any)
+	for _, key := range sortedKeys(data) {
+		encoded, _ := data[key].(string)
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return gen, fmt.Errorf("Secret %s: %s: %w", o.name, key, err)
+		}
+		file := path.Join(KustomizeSecretsDir, o.name, key)
+		files[dir+"/"+file] = value
+		gen.FileSources = append(gen.FileSources, key+"="+file)
+	}
+	return gen, nil
+}
+
+// stringMap returns the labels or annotations of parsed metadata.
+func stringMap(value any) map[string]string {
+	m, _ := value.(map[string]any)
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for key, v := range m {
+		out[key] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// jsonPatch returns the JSON patch (RFC 6902) operations turning from into to
+// at path: maps are patched key by key, lists of the same length entry by
+// entry, anything else replaced.
+func jsonPatch(path string, from, to any) []map[string]any {
+	switch from := from.(type) {
+	case map[string]any:
+		to, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		var ops []map[string]any
+		for _, key := range sortedKeys(from) {
+			if _, ok := to[key]; !ok {
+				ops = append(ops, map[string]any{"op": "remove", "path": path + "/" + jsonPointerKey(key)})
+			}
+		}
+		for _, key := range sortedKeys(to) {
+			if value, ok := from[key]; ok {
+				ops = append(ops, jsonPatch(path+"/"+jsonPointerKey(key), value, to[key])...)
+			} else {
+				ops = append(ops, map[string]any{"op": "add", "path": path + "/" + jsonPointerKey(key), "value": to[key]})
+			}
+		}
+		return ops
+	case []any:
+		to, ok := to.([]any)
+		if !ok || len(to) != len(from) {
+			break
+		}
+		var ops []map[string]any
+		for i := range from {
+			ops = append(ops, jsonPatch(fmt.Sprintf("%s/%d", path, i), from[i], to[i])...)
+		}
+		return ops
+	}
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return []map[string]any{{"op": "replace", "path": path, "value": to}}
+}
+
+// jsonPointerKey escapes a key for a JSON pointer (RFC 6901).
+func jsonPointerKey(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,156 @@
+package app2kube
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const kustomizeValues = `name: shop
+common:
+  image:
+    repository: example/shop
+    tag: "1.2"
+deployment:
+  replicaCount: 3
+  replicaCountStaging: 1
+  containers:
+    app:
+      ports:
+        - name: http
+          containerPort: 8080
+env:
+  MODE: prod
+configmap:
+  GREETING: hello
+secrets:
+  API_TOKEN: abc
+ingress:
+  - host: shop.example.com
+cronjob:
+  clean:
+    schedule: "0 3 * * *"
+    containers:
+      job:
+        command: [./clean]
+`
+
+// kustomizeBuild builds the kustomization in dir of files like kustomize
+// build and returns its objects as YAML by kind and name.
+func kustomizeBuild(t *testing.T, files map[string][]byte, dir string) map[string]string {
+	t.Helper()
+	fs := filesys.MakeFsInMemory()
+	for path, content := range files {
+		if err := fs.WriteFile("/export/"+path, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fs, "/export/"+dir)
+	if err != nil {
+		t.Fatalf("kustomize build %s: %v", dir, err)
+	}
+	objs := map[string]string{}
+	for _, res := range resources.Resources() {
+		m, err := res.Map()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A generated Secret is typed, as the API server defaults it.
+		if res.GetKind() == "Secret" && m["type"] == "Opaque" {
+			delete(m, "type")
+		}
+		data, err := yaml.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs[res.GetKind()+"/"+res.GetName()] = string(data)
+	}
+	return objs
+}
+
+// renderedObjects returns the objects app renders as YAML by kind and name.
+func renderedObjects(t *testing.T, app *App) map[string]string {
+	t.Helper()
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := manifestObjects(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]string{}
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj.obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[obj.kind+"/"+obj.name] = string(data)
+	}
+	return out
+}
+
+func TestKustomization(t *testing.T) {
+	files := writeValueFiles(t, [2]string{"shop.yml", kustomizeValues})
+	app := NewApp()
+	if _, err := app.LoadValueSources(ValueSources{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	qa := NewApp()
+	if _, err := qa.LoadValueSources(ValueSources{Files: files, Values: []string{"staging=qa"}, StringValues: []string{"secrets.API_TOKEN=qa"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := app.Kustomization(map[string]*App{"qa": qa})
+	if err != nil {
+		t.Fatalf("Kustomization: %v", err)
+	}
+	for path, want := range map[string]string{
+		".gitignore":                               "secrets/",
+		"base/deployment-shop.yaml":                "# Deployment: shop\napiVersion: apps/v1\n",
+		"base/secrets/shop/API_TOKEN":              "abc",
+		"overlays/qa/secrets/shop-qa/API_TOKEN":    "qa",
+		"overlays/qa/patches/secret-shop.yaml":     "$patch: delete",
+		"overlays/qa/patches/deployment-shop.yaml": "- op: replace\n  path: /metadata/name\n  value: shop-qa\n",
+	} {
+		if !strings.Contains(string(export[path]), want) {
+			t.Errorf("%s: no %q in\n%s", path, want, export[path])
+		}
+	}
+	if strings.Contains(string(export["base/kustomization.yaml"]), "secret-shop.yaml") {
+		t.Errorf("the Secret is a resource of the base:\n%s", export["base/kustomization.yaml"])
+	}
+
+	for dir, app := range map[string]*App{"base": app, "overlays/qa": qa} {
+		got, want := kustomizeBuild(t, export, dir), renderedObjects(t, app)
+		for id, obj := range want {
+			if got[id] != obj {
+				t.Errorf("%s: %s:\n%s\nwant:\n%s", dir, id, got[id], obj)
+			}
+		}
+		for id := range got {
+			if _, ok := want[id]; !ok {
+				t.Errorf("%s: %s is not rendered", dir, id)
+			}
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	from := map[string]any{"a": "1", "b/c": []any{"x", "y"}, "d": map[string]any{"e": 1.0}, "f": []any{"z"}}
+	to := map[string]any{"b/c": []any{"x", "w"}, "d": map[string]any{"e": 2.0, "g~": true}, "f": []any{"z", "z"}}
+	want := []map[string]any{
+		{"op": "remove", "path": "/a"},
+		{"op": "replace", "path": "/b~1c/1", "value": "w"},
+		{"op": "replace", "path": "/d/e", "value": 2.0},
+		{"op": "add", "path": "/d/g~0", "value": true},
+		{"op": "replace", "path": "/f", "value": []any{"z", "z"}},
+	}
+	if got := jsonPatch("", from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,261 @@
+package app2kube
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+// ServiceMonitor is a minimal, dependency-free rendering of the Prometheus
+// Operator monitoring.coreos.com/v1 ServiceMonitor. Like the cert-manager
+// Certificate (certificate.go) the operator is not added to go.mod; the
+// pre-filled TypeMeta lets the shared printer serialize it without the scheme.
+type ServiceMonitor struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ServiceMonitorSpec `json:"spec"`
+}
+
+// ServiceMonitorSpec is the subset of the operator's ServiceMonitorSpec
+// app2kube emits: the app's Services and the endpoint to scrape on them.
+type ServiceMonitorSpec struct {
+	Endpoints []MonitorEndpoint    `json:"endpoints"`
+	Selector  metav1.LabelSelector `json:"selector"`
+}
+
+// PodMonitor is the PodMonitor counterpart of ServiceMonitor, scraping the
+// app's pods directly instead of going through a Service.
+type PodMonitor struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PodMonitorSpec `json:"spec"`
+}
+
+// PodMonitorSpec is the subset of the operator's PodMonitorSpec app2kube emits.
+type PodMonitorSpec struct {
+	PodMetricsEndpoints []MonitorEndpoint    `json:"podMetricsEndpoints"`
+	Selector            metav1.LabelSelector `json:"selector"`
+}
+
+// MonitorEndpoint is the scrape endpoint shared by ServiceMonitor endpoints and
+// PodMonitor podMetricsEndpoints: a named port (a Service port name or a
+// container port name respectively) plus the scrape settings.
+type MonitorEndpoint struct {
+	Interval      string          `json:"interval,omitempty"`
+	Path          string          `json:"path,omitempty"`
+	Port          string          `json:"port"`
+	Relabelings   []RelabelConfig `json:"relabelings,omitempty"`
+	ScrapeTimeout string          `json:"scrapeTimeout,omitempty"`
+}
+
+// RelabelConfig is a Prometheus relabeling rule applied to the scraped target
+// before ingestion.
+type RelabelConfig struct {
+	Action       string   `json:"action,omitempty"`
+	Modulus      uint64   `json:"modulus,omitempty"`
+	Regex        string   `json:"regex,omitempty"`
+	Replacement  *string  `json:"replacement,omitempty"`
+	Separator    *string  `json:"separator,omitempty"`
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	TargetLabel  string   `json:"targetLabel,omitempty"`
+}
+
+// DeepCopyObject implements runtime.Object so the ServiceMonitor can flow
+// through the same printing pipeline as the built-in kinds.
+func (m *ServiceMonitor) DeepCopyObject() runtime.Object {
+	if m == nil {
+		return nil
+	}
+	out := new(ServiceMonitor)
+	out.TypeMeta = m.TypeMeta
+	m.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	m.Spec.Selector.DeepCopyInto(&out.Spec.Selector)
+	out.Spec.Endpoints = deepCopyEndpoints(m.Spec.Endpoints)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object for the PodMonitor.
+func (m *PodMonitor) DeepCopyObject() runtime.Object {
+	if m == nil {
+		return nil
+	}
+	out := new(PodMonitor)
+	out.TypeMeta = m.TypeMeta
+	m.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	m.Spec.Selector.DeepCopyInto(&out.Spec.Selector)
+	out.Spec.PodMetricsEndpoints = deepCopyEndpoints(m.Spec.PodMetricsEndpoints)
+	return out
+}
+
+func deepCopyEndpoints(in []MonitorEndpoint) []MonitorEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := make([]MonitorEndpoint, len(in))
+	for i, ep := range in {
+		out[i] = ep
+		if ep.Relabelings == nil {
+			continue
+		}
+		out[i].Relabelings = make([]RelabelConfig, len(ep.Relabelings))
+		for j, rc := range ep.Relabelings {
+			out[i].Relabelings[j] = rc
+			if rc.Replacement != nil {
+				out[i].Relabelings[j].Replacement = ptr.To(*rc.Replacement)
+			}
+			if rc.Separator != nil {
+				out[i].Relabelings[j].Separator = ptr.To(*rc.Separator)
+			}
+			if rc.SourceLabels != nil {
+				out[i].Relabelings[j].SourceLabels = append([]string(nil), rc.SourceLabels...)
+			}
+		}
+	}
+	return out
+}
+
+// prometheusDuration matches the operator's Duration format ("30s", "1m30s");
+// the CRD rejects anything else at apply time.
+var prometheusDuration = regexp.MustCompile(`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`)
+
+// usesPrometheusOperator reports whether the app emits a ServiceMonitor or
+// PodMonitor.
+func (app *App) usesPrometheusOperator() bool {
+	return app.Metrics.Enabled
+}
+
+// metricsKind returns the monitor kind metrics.kind selects, ServiceMonitor by
+// default.
+func (app *App) metricsKind() string {
+	if strings.EqualFold(app.Metrics.Kind, KindPodMonitor) {
+		return KindPodMonitor
+	}
+	return KindServiceMonitor
+}
+
+// validateMetrics rejects a metrics section the operator CRDs would refuse.
+func (app *App) validateMetrics() error {
+	m := app.Metrics
+	if !m.Enabled {
+		return nil
+	}
+	switch {
+	case m.Kind == "",
+		strings.EqualFold(m.Kind, KindServiceMonitor),
+		strings.EqualFold(m.Kind, KindPodMonitor):
+	default:
+		return fmt.Errorf("unknown metrics.kind %q (must be %s or %s)", m.Kind, KindServiceMonitor, KindPodMonitor)
+	}
+	if m.Port == "" {
+		return fmt.Errorf("metrics.port is required")
+	}
+	for _, d := range []struct{ key, value string }{{"interval", m.Interval}, {"scrapeTimeout", m.ScrapeTimeout}} {
+		if d.value != "" && !prometheusDuration.MatchString(d.value) {
+			return fmt.Errorf("invalid metrics.%s %q (expected a duration like 30s or 1m)", d.key, d.value)
+		}
+	}
+	return nil
+}
+
+// metricsSelector selects the app's objects by name and instance, not the
+// color: both blue/green colors are scraped while they run.
+func (app *App) metricsSelector() metav1.LabelSelector {
+	return metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelName:     app.Labels[LabelName],
+			LabelInstance: app.Labels[LabelInstance],
+		},
+	}
+}
+
+// metricsObjectMeta returns the monitor's metadata: named after the release,
+// with metrics.labels merged over the app labels so the Prometheus instance's
+// serviceMonitorSelector/podMonitorSelector can pick it up.
+func (app *App) metricsObjectMeta() metav1.ObjectMeta {
+	meta := app.GetObjectMeta(app.GetReleaseName())
+	labels := make(map[string]string, len(app.Labels)+len(app.Metrics.Labels))
+	for k, v := range app.Labels {
+		labels[k] = v
+	}
+	for k, v := range app.Metrics.Labels {
+		labels[k] = v
+	}
+	meta.Labels = labels
+	return meta
+}
+
+func (app *App) metricsEndpoint() MonitorEndpoint {
+	return MonitorEndpoint{
+		Interval:      app.Metrics.Interval,
+		Path:          app.Metrics.Path,
+		Port:          app.Metrics.Port,
+		Relabelings:   app.Metrics.Relabelings,
+		ScrapeTimeout: app.Metrics.ScrapeTimeout,
+	}
+}
+
+// GetServiceMonitor returns the ServiceMonitor scraping metrics.port on the
+// app's Services when metrics are enabled with kind ServiceMonitor. The port is
+// a Service port name — the key of the `service:` entry — so an unknown name
+// is an error rather than a monitor that silently scrapes nothing.
+func (app *App) GetServiceMonitor() (*ServiceMonitor, error) {
+	if len(app.Deployment.Containers) == 0 || !app.usesPrometheusOperator() || app.metricsKind() != KindServiceMonitor {
+		return nil, nil
+	}
+	found := false
+	for name := range app.Service {
+		if servicePortName(name) == app.Metrics.Port {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("metrics.port %q does not name a service port (one of: %s)", app.Metrics.Port, strings.Join(app.servicePortNames(), ", "))
+	}
+	return &ServiceMonitor{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: prometheusOperatorAPIVersion,
+			Kind:       KindServiceMonitor,
+		},
+		ObjectMeta: app.metricsObjectMeta(),
+		Spec: ServiceMonitorSpec{
+			Endpoints: []MonitorEndpoint{app.metricsEndpoint()},
+			Selector:  app.metricsSelector(),
+		},
+	}, nil
+}
+
+// GetPodMonitor returns the PodMonitor scraping metrics.port on the app's pods
+// when metrics are enabled with kind PodMonitor. The port is a container port
+// name of the Deployment's containers.
+func (app *App) GetPodMonitor() (*PodMonitor, error) {
+	if len(app.Deployment.Containers) == 0 || !app.usesPrometheusOperator() || app.metricsKind() != KindPodMonitor {
+		return nil, nil
+	}
+	found := false
+	for _, c := range app.Deployment.Containers {
+		for _, p := range c.Ports {
+			if p.Name == app.Metrics.Port {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("metrics.port %q does not name a container port of the deployment", app.Metrics.Port)
+	}
+	return &PodMonitor{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: prometheusOperatorAPIVersion,
+			Kind:       KindPodMonitor,
+		},
+		ObjectMeta: app.metricsObjectMeta(),
+		Spec: PodMonitorSpec{
+			PodMetricsEndpoints: []MonitorEndpoint{app.metricsEndpoint()},
+			Selector:            app.metricsSelector(),
+		},
+	}, nil
+}
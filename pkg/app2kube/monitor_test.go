@@ -0,0 +1,144 @@
+package app2kube
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// metricsApp returns an app exposing a "metrics" port both as a container port
+// and as a Service.
+func metricsApp(t *testing.T) *App {
+	t.Helper()
+	app := deployApp(t)
+	app.Deployment.Containers = map[string]apiv1.Container{
+		"app": {Image: "example/app:v1", Ports: []apiv1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}},
+	}
+	app.Service = map[string]Service{"Metrics": {Port: 9090}}
+	app.Metrics = MetricsSpec{Enabled: true, Port: "metrics", Path: "/stats", Interval: "15s"}
+	return app
+}
+
+func TestGetServiceMonitor(t *testing.T) {
+	app := metricsApp(t)
+	app.Metrics.Labels = map[string]string{"release": "prometheus"}
+
+	sm, err := app.GetServiceMonitor()
+	if err != nil {
+		t.Fatalf("GetServiceMonitor: %v", err)
+	}
+	if sm == nil || sm.Kind != KindServiceMonitor || sm.APIVersion != "monitoring.coreos.com/v1" || sm.Name != "example" {
+		t.Fatalf("unexpected ServiceMonitor: %+v", sm)
+	}
+	ep := sm.Spec.Endpoints[0]
+	if ep.Port != "metrics" || ep.Path != "/stats" || ep.Interval != "15s" {
+		t.Errorf("endpoint: got %+v", ep)
+	}
+	if sm.Labels["release"] != "prometheus" || sm.Labels[LabelManagedBy] != ManagedByValue {
+		t.Errorf("metrics.labels must be merged over the app labels: %v", sm.Labels)
+	}
+	if _, ok := app.Labels["release"]; ok {
+		t.Error("metrics.labels must not leak into the shared app labels")
+	}
+	if _, ok := sm.Spec.Selector.MatchLabels[LabelColor]; ok {
+		t.Error("the selector must not pin a blue/green color")
+	}
+
+	// The ServiceMonitor port refers to the Service port name, the
+	// sanitized `service:` key.
+	svcs, err := app.GetServices()
+	if err != nil {
+		t.Fatalf("GetServices: %v", err)
+	}
+	if name := svcs[0].Spec.Ports[0].Name; name != "metrics" {
+		t.Errorf("service port name: got %q, want metrics", name)
+	}
+	// Without a ServiceMonitor the ports stay unnamed, as they always were.
+	app.Metrics.Kind = KindPodMonitor
+	if svcs, err = app.GetServices(); err != nil {
+		t.Fatalf("GetServices: %v", err)
+	}
+	if name := svcs[0].Spec.Ports[0].Name; name != "" {
+		t.Errorf("service port name without a ServiceMonitor: got %q", name)
+	}
+	app.Metrics.Kind = ""
+	if pm, _ := app.GetPodMonitor(); pm != nil {
+		t.Error("no PodMonitor expected with the default kind")
+	}
+
+	app.Metrics.Port = "http"
+	if _, err := app.GetServiceMonitor(); err == nil || !strings.Contains(err.Error(), "metrics") {
+		t.Errorf("an unknown service port must fail, got %v", err)
+	}
+}
+
+func TestGetPodMonitor(t *testing.T) {
+	app := metricsApp(t)
+	app.Metrics.Kind = "podmonitor"
+	if err := app.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	pm, err := app.GetPodMonitor()
+	if err != nil {
+		t.Fatalf("GetPodMonitor: %v", err)
+	}
+	if pm == nil || pm.Kind != KindPodMonitor || pm.Spec.PodMetricsEndpoints[0].Port != "metrics" {
+		t.Fatalf("unexpected PodMonitor: %+v", pm)
+	}
+	if sm, _ := app.GetServiceMonitor(); sm != nil {
+		t.Error("no ServiceMonitor expected with kind PodMonitor")
+	}
+
+	app.Metrics.Port = "http"
+	if _, err := app.GetPodMonitor(); err == nil {
+		t.Error("an unknown container port must fail")
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	cases := map[string]MetricsSpec{
+		"unknown metrics.kind":          {Enabled: true, Kind: "Probe", Port: "metrics"},
+		"metrics.port":                  {Enabled: true},
+		"invalid metrics.interval":      {Enabled: true, Port: "metrics", Interval: "15"},
+		"invalid metrics.scrapeTimeout": {Enabled: true, Port: "metrics", ScrapeTimeout: "ten"},
+	}
+	for want, metrics := range cases {
+		app := deployApp(t)
+		app.Metrics = metrics
+		if err := app.validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%+v: got %v, want an error containing %q", metrics, err, want)
+		}
+	}
+
+	app := deployApp(t)
+	app.Metrics = MetricsSpec{Port: "bogus", Kind: "bogus"}
+	if err := app.validate(); err != nil {
+		t.Errorf("disabled metrics must not be validated: %v", err)
+	}
+}
+
+// Like the cert-manager Certificate, the monitor CRDs are only referenced by
+// the prune/delete sets when the app enables metrics — a cluster without the
+// Prometheus Operator would otherwise fail every prune and delete.
+func TestPruneAndDeletePrometheusOperatorConditional(t *testing.T) {
+	const smGVK = "monitoring.coreos.com/v1/ServiceMonitor"
+	const pmRes = "podmonitors.monitoring.coreos.com"
+
+	plain := NewApp()
+	if slices.Contains(plain.PruneWhitelist(), smGVK) || strings.Contains(plain.DeleteResourceTypes(), pmRes) {
+		t.Errorf("monitor kinds must not be listed without metrics: %v", plain.PruneWhitelist())
+	}
+
+	app := NewApp()
+	app.Metrics.Enabled = true
+	if !slices.Contains(app.PruneWhitelist(), smGVK) {
+		t.Errorf("prune whitelist must list %q with metrics: %v", smGVK, app.PruneWhitelist())
+	}
+	// Both kinds, so switching metrics.kind prunes the other monitor.
+	if !strings.Contains(app.DeleteResourceTypes(), pmRes) {
+		t.Errorf("delete list must list %q with metrics: %q", pmRes, app.DeleteResourceTypes())
+	}
+}
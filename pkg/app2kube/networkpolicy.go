@@ -0,0 +1,144 @@
+package app2kube
+
+import (
+	"fmt"
+	"net"
+
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+// labelNamespaceName is the immutable label the apiserver sets on every
+// Namespace (Kubernetes 1.21+), so a namespaceSelector can pick one by name.
+const labelNamespaceName = "kubernetes.io/metadata.name"
+
+// networkPolicyServicePorts returns the container ports the app's Services
+// target, in sorted Service order without duplicates. They become the ports the
+// allow shorthands admit; an app without Services gets nil, i.e. every port.
+func (app *App) networkPolicyServicePorts() []networkingv1.NetworkPolicyPort {
+	var ports []networkingv1.NetworkPolicyPort
+	seen := make(map[string]bool)
+	for _, name := range sortedKeys(app.Service) {
+		svc := app.Service[name]
+		internal, _, ok := svc.resolvePorts()
+		if !ok {
+			continue
+		}
+		protocol := svc.Protocol
+		if protocol == "" {
+			protocol = apiv1.ProtocolTCP
+		}
+		key := fmt.Sprintf("%s/%d", protocol, internal)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, networkingv1.NetworkPolicyPort{
+			Protocol: ptr.To(protocol),
+			Port:     ptr.To(intstr.FromInt32(internal)),
+		})
+	}
+	return ports
+}
+
+// GetNetworkPolicy returns the NetworkPolicy isolating the app's pods when
+// networkPolicy.enabled is set. It selects the pods with GetColorLabels and
+// carries the per-color name like the PDB, so each blue/green color is
+// admitted by its own policy. Ingress is always isolated — with no allow
+// shorthand set nothing may connect — while egress is only restricted once
+// allowDNS or an egress rule is configured.
+func (app *App) GetNetworkPolicy() (*networkingv1.NetworkPolicy, error) {
+	np := app.NetworkPolicy
+	if len(app.Deployment.Containers) == 0 || !np.Enabled {
+		return nil, nil
+	}
+
+	ports := app.networkPolicyServicePorts()
+	var peers []networkingv1.NetworkPolicyPeer
+	if np.IngressControllerNamespace != "" {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{labelNamespaceName: np.IngressControllerNamespace},
+			},
+		})
+	}
+	if np.AllowSameApp {
+		// Name and instance, not the color: blue and green pods of the same
+		// release may talk to each other during a rotation. With named
+		// workloads their pods' instances are admitted too.
+		selector := &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				LabelName:     app.Labels[LabelName],
+				LabelInstance: app.Labels[LabelInstance],
+			},
+		}
+		if len(app.Workloads) > 0 {
+			delete(selector.MatchLabels, LabelInstance)
+			selector.MatchExpressions = []metav1.LabelSelectorRequirement{{
+				Key:      LabelInstance,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   app.sameAppInstances(),
+			}}
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{PodSelector: selector})
+	}
+	for _, name := range np.AllowFromApps {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					LabelName:      truncateName(sanitizeDNSName(name)),
+					LabelManagedBy: ManagedByValue,
+				},
+			},
+		})
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: app.GetObjectMeta(app.GetDeploymentName()),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: app.GetColorLabels()},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	if len(peers) > 0 {
+		policy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{From: peers, Ports: ports}}
+	}
+
+	var egress []networkingv1.NetworkPolicyEgressRule
+	if np.AllowDNS {
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{labelNamespaceName: "kube-system"},
+				},
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"k8s-app": "kube-dns"},
+				},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: ptr.To(apiv1.ProtocolUDP), Port: ptr.To(intstr.FromInt32(53))},
+				{Protocol: ptr.To(apiv1.ProtocolTCP), Port: ptr.To(intstr.FromInt32(53))},
+			},
+		})
+	}
+	for i, rule := range np.Egress {
+		var to []networkingv1.NetworkPolicyPeer
+		for _, cidr := range rule.CIDRs {
+			// The apiserver rejects a malformed ipBlock; fail before apply.
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return nil, fmt.Errorf("invalid networkPolicy.egress[%d] cidr %q: %w", i, cidr, err)
+			}
+			to = append(to, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{To: to, Ports: rule.Ports})
+	}
+	if len(egress) > 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		policy.Spec.Egress = egress
+	}
+
+	return policy, nil
+}
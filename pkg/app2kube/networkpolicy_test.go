@@ -0,0 +1,106 @@
+package app2kube
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetNetworkPolicyShorthands(t *testing.T) {
+	app := deployApp(t)
+	app.Labels[LabelName] = "example"
+	app.Service = map[string]Service{
+		"web":     {Port: 8080},
+		"metrics": {InternalPort: 9090, ExternalPort: 80},
+		"alias":   {Port: 8080}, // same target port: admitted once
+	}
+	app.NetworkPolicy = NetworkPolicySpec{
+		Enabled:                    true,
+		IngressControllerNamespace: "ingress-nginx",
+		AllowSameApp:               true,
+		AllowFromApps:              []string{"Frontend"},
+		AllowDNS:                   true,
+		Egress: []NetworkPolicyEgressRule{{
+			CIDRs: []string{"10.0.0.0/8"},
+			Ports: []networkingv1.NetworkPolicyPort{{Port: ptr.To(intstr.FromInt32(5432))}},
+		}},
+	}
+
+	policy, err := app.GetNetworkPolicy()
+	if err != nil {
+		t.Fatalf("GetNetworkPolicy: %v", err)
+	}
+	if policy == nil {
+		t.Fatal("expected a NetworkPolicy")
+	}
+	if policy.Spec.PodSelector.MatchLabels[LabelName] != "example" {
+		t.Errorf("podSelector must match the app pods: %v", policy.Spec.PodSelector.MatchLabels)
+	}
+	if len(policy.Spec.PolicyTypes) != 2 {
+		t.Errorf("expected Ingress and Egress policy types, got %v", policy.Spec.PolicyTypes)
+	}
+
+	rule := policy.Spec.Ingress[0]
+	if len(rule.From) != 3 {
+		t.Fatalf("expected ingress-controller, same-app and frontend peers, got %+v", rule.From)
+	}
+	if ns := rule.From[0].NamespaceSelector; ns == nil || ns.MatchLabels[labelNamespaceName] != "ingress-nginx" {
+		t.Errorf("ingress controller peer: %+v", rule.From[0])
+	}
+	if sel := rule.From[2].PodSelector; sel == nil || sel.MatchLabels[LabelName] != "frontend" || sel.MatchLabels[LabelManagedBy] != ManagedByValue {
+		t.Errorf("other app peer must select the app2kube app by name: %+v", rule.From[2])
+	}
+	var ports []int32
+	for _, p := range rule.Ports {
+		ports = append(ports, p.Port.IntVal)
+	}
+	if len(ports) != 2 || ports[0] != 8080 || ports[1] != 9090 {
+		t.Errorf("ingress ports must be the Services' target ports, got %v", ports)
+	}
+
+	if len(policy.Spec.Egress) != 2 {
+		t.Fatalf("expected DNS + CIDR egress rules, got %+v", policy.Spec.Egress)
+	}
+	if p := policy.Spec.Egress[0].Ports[0]; *p.Protocol != apiv1.ProtocolUDP || p.Port.IntVal != 53 {
+		t.Errorf("DNS egress must allow UDP/53: %+v", p)
+	}
+	if b := policy.Spec.Egress[1].To[0].IPBlock; b == nil || b.CIDR != "10.0.0.0/8" {
+		t.Errorf("egress CIDR: %+v", policy.Spec.Egress[1].To)
+	}
+}
+
+// With no allow shorthand the pods are fully isolated for ingress while
+// egress stays unrestricted.
+func TestGetNetworkPolicyDefaultDeny(t *testing.T) {
+	app := deployApp(t)
+	app.Deployment.BlueGreenColor = "blue"
+	app.NetworkPolicy.Enabled = true
+
+	policy, err := app.GetNetworkPolicy()
+	if err != nil {
+		t.Fatalf("GetNetworkPolicy: %v", err)
+	}
+	if policy.Name != "example-blue" || policy.Spec.PodSelector.MatchLabels[LabelColor] != "blue" {
+		t.Errorf("policy must follow the blue/green color: %q %v", policy.Name, policy.Spec.PodSelector.MatchLabels)
+	}
+	if len(policy.Spec.Ingress) != 0 || len(policy.Spec.PolicyTypes) != 1 || policy.Spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress {
+		t.Errorf("expected ingress-only default deny, got %+v", policy.Spec)
+	}
+
+	app.NetworkPolicy.Enabled = false
+	if policy, _ := app.GetNetworkPolicy(); policy != nil {
+		t.Error("no NetworkPolicy expected when disabled")
+	}
+}
+
+func TestGetNetworkPolicyInvalidCIDR(t *testing.T) {
+	app := deployApp(t)
+	app.NetworkPolicy = NetworkPolicySpec{Enabled: true, Egress: []NetworkPolicyEgressRule{{CIDRs: []string{"10.0.0.0/33"}}}}
+	if _, err := app.GetNetworkPolicy(); err == nil || !strings.Contains(err.Error(), "cidr") {
+		t.Errorf("expected invalid cidr error, got %v", err)
+	}
+}
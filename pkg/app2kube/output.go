@@ -12,6 +12,7 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/kubectl/pkg/scheme"
+	"sigs.k8s.io/yaml"
 )
 
 // OutputResource type
@@ -32,18 +33,34 @@ const (
 	OutputCronJob
 	// OutputDeployment only
 	OutputDeployment
-	// OutputIngress only
+	// OutputHook only (hook Jobs of every phase; never part of OutputAll)
+	OutputHook
+	// OutputHorizontalPodAutoscaler only
+	OutputHorizontalPodAutoscaler
+	// OutputHTTPRoute only (Gateway API HTTPRoutes in gateway mode)
+	OutputHTTPRoute
+	// OutputIngress only (the HTTPRoutes in gateway mode)
 	OutputIngress
+	// OutputMonitor only (Prometheus Operator ServiceMonitor or PodMonitor)
+	OutputMonitor
 	// OutputNamespace only
 	OutputNamespace
+	// OutputNetworkPolicy only
+	OutputNetworkPolicy
 	// OutputPersistentVolumeClaim only
 	OutputPersistentVolumeClaim
 	// OutputPodDisruptionBudget only
 	OutputPodDisruptionBudget
+	// OutputRBAC only (Role/RoleBinding and ClusterRole/ClusterRoleBinding)
+	OutputRBAC
 	// OutputSecret only
 	OutputSecret
 	// OutputService only
 	OutputService
+	// OutputServiceAccount only
+	OutputServiceAccount
+	// OutputStatefulSet only
+	OutputStatefulSet
 )
 
 // generator describes how to render one kind of resource and which requested
@@ -76,6 +93,8 @@ func toObjects[T runtime.Object](items []T) []runtime.Object {
 // manifestGenerators is the ordered registry of resource generators. The order
 // defines the order of resources in the rendered manifest and matches the
 // previous hand-written sequence in GetManifest.
+// Per-workload generators are wrapped in perWorkload so the named workloads
+// render right after the app's own.
 var manifestGenerators = []generator{
 	{
 		selects: []OutputResource{OutputNamespace},
@@ -113,6 +132,31 @@ var manifestGenerators = []generator{
 			return toObjects(claims), nil
 		},
 	},
+	{
+		// The ServiceAccount and its permissions must exist before the pods
+		// that run as it, so they deploy with the Deployment.
+		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputServiceAccount},
+		render: func(app *App) ([]runtime.Object, error) {
+			sa := app.GetServiceAccount()
+			if sa == nil {
+				return nil, nil
+			}
+			return []runtime.Object{sa}, nil
+		},
+	},
+	{
+		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputRBAC},
+		render: func(app *App) ([]runtime.Object, error) {
+			var objs []runtime.Object
+			if role, binding := app.GetRole(); role != nil {
+				objs = append(objs, role, binding)
+			}
+			if role, binding := app.GetClusterRole(); role != nil {
+				objs = append(objs, role, binding)
+			}
+			return objs, nil
+		},
+	},
 	{
 		selects: []OutputResource{OutputAll, OutputAllOther, OutputCronJob},
 		render: func(app *App) ([]runtime.Object, error) {
@@ -125,12 +169,27 @@ var manifestGenerators = []generator{
 	},
 	{
 		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputDeployment},
-		render: func(app *App) ([]runtime.Object, error) {
+		render: perWorkload(func(app *App) ([]runtime.Object, error) {
 			deployment, err := app.GetDeployment()
 			if err != nil {
 				return nil, err
 			}
 			return []runtime.Object{deployment}, nil
+		}),
+	},
+	{
+		// deployment.kind: StatefulSet renders the pods here instead of the
+		// Deployment above; exactly one of the two emits an object.
+		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputStatefulSet},
+		render: func(app *App) ([]runtime.Object, error) {
+			sts, err := app.GetStatefulSet()
+			if err != nil {
+				return nil, err
+			}
+			if sts == nil {
+				return nil, nil
+			}
+			return []runtime.Object{sts}, nil
 		},
 	},
 	{
@@ -138,7 +197,7 @@ var manifestGenerators = []generator{
 		// protects the pods as soon as they exist; render returns nothing for a
 		// single-replica deploy (#47).
 		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputPodDisruptionBudget},
-		render: func(app *App) ([]runtime.Object, error) {
+		render: perWorkload(func(app *App) ([]runtime.Object, error) {
 			pdb, err := app.GetPodDisruptionBudget()
 			if err != nil {
 				return nil, err
@@ -147,17 +206,47 @@ var manifestGenerators = []generator{
 				return nil, nil
 			}
 			return []runtime.Object{pdb}, nil
-		},
+		}),
+	},
+	{
+		// The HPA deploys with its workload and, like the PDB, carries the
+		// per-color name; render returns nothing unless autoscaling is enabled.
+		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputHorizontalPodAutoscaler},
+		render: perWorkload(func(app *App) ([]runtime.Object, error) {
+			hpa, err := app.GetHorizontalPodAutoscaler()
+			if err != nil {
+				return nil, err
+			}
+			if hpa == nil {
+				return nil, nil
+			}
+			return []runtime.Object{hpa}, nil
+		}),
+	},
+	{
+		// The NetworkPolicy deploys with the pods it admits traffic to, and is
+		// per-color like the PDB.
+		selects: []OutputResource{OutputAll, OutputAllForDeployment, OutputNetworkPolicy},
+		render: perWorkload(func(app *App) ([]runtime.Object, error) {
+			policy, err := app.GetNetworkPolicy()
+			if err != nil {
+				return nil, err
+			}
+			if policy == nil {
+				return nil, nil
+			}
+			return []runtime.Object{policy}, nil
+		}),
 	},
 	{
 		selects: []OutputResource{OutputAll, OutputAllOther, OutputService},
-		render: func(app *App) ([]runtime.Object, error) {
+		render: perWorkload(func(app *App) ([]runtime.Object, error) {
 			services, err := app.GetServices()
 			if err != nil {
 				return nil, err
 			}
 			return toObjects(services), nil
-		},
+		}),
 	},
 	{
 		// TLS secrets for ingress are emitted together with other resources and
@@ -181,6 +270,19 @@ var manifestGenerators = []generator{
 			return toObjects(ingress), nil
 		},
 	},
+	{
+		// common.ingress.mode: gateway renders the ingress entries here
+		// instead of the Ingress above, from a local typed struct (gateway.go)
+		// like the Certificate below; --type ingress selects either.
+		selects: []OutputResource{OutputAll, OutputAllOther, OutputIngress, OutputHTTPRoute},
+		render: func(app *App) ([]runtime.Object, error) {
+			routes, err := app.GetHTTPRoutes()
+			if err != nil {
+				return nil, err
+			}
+			return toObjects(routes), nil
+		},
+	},
 	{
 		// cert-manager Certificate objects for letsencrypt ingresses. Rendered
 		// from a local typed struct (certificate.go) so cert-manager is not a
@@ -195,6 +297,46 @@ var manifestGenerators = []generator{
 			return toObjects(certs), nil
 		},
 	},
+	{
+		// Prometheus Operator monitors, rendered from local typed structs
+		// (monitor.go) like the Certificate above. They follow the Services
+		// a ServiceMonitor selects.
+		selects: []OutputResource{OutputAll, OutputAllOther, OutputMonitor},
+		render: func(app *App) ([]runtime.Object, error) {
+			sm, err := app.GetServiceMonitor()
+			if err != nil {
+				return nil, err
+			}
+			if sm != nil {
+				return []runtime.Object{sm}, nil
+			}
+			pm, err := app.GetPodMonitor()
+			if err != nil {
+				return nil, err
+			}
+			if pm != nil {
+				return []runtime.Object{pm}, nil
+			}
+			return nil, nil
+		},
+	},
+	{
+		// Hook Jobs are run by the CLI around apply/delete rather than applied
+		// with the manifest, so only an explicit --type hook renders them (and
+		// they stay out of emittedKinds).
+		selects: []OutputResource{OutputHook},
+		render: func(app *App) ([]runtime.Object, error) {
+			var objs []runtime.Object
+			for _, phase := range HookPhases {
+				jobs, err := app.GetHookJobs(phase)
+				if err != nil {
+					return nil, err
+				}
+				objs = append(objs, toObjects(jobs)...)
+			}
+			return objs, nil
+		},
+	},
 }
 
 // EmittedKind pairs the two identifiers the destructive CLI operations need for
@@ -215,10 +357,28 @@ var emittedKinds = []EmittedKind{
 	{"/v1/PersistentVolumeClaim", "persistentvolumeclaims"},
 	{"/v1/Secret", "secrets"},
 	{"/v1/Service", "services"},
+	{"/v1/ServiceAccount", "serviceaccounts"},
 	{"apps/v1/Deployment", "deployments"},
+	{"apps/v1/StatefulSet", "statefulsets"},
+	{"autoscaling/v2/HorizontalPodAutoscaler", "horizontalpodautoscalers"},
 	{"batch/v1/CronJob", "cronjobs"},
 	{"networking.k8s.io/v1/Ingress", "ingresses"},
+	{"networking.k8s.io/v1/NetworkPolicy", "networkpolicies"},
 	{"policy/v1/PodDisruptionBudget", "poddisruptionbudgets"},
+	{"rbac.authorization.k8s.io/v1/Role", "roles"},
+	{"rbac.authorization.k8s.io/v1/RoleBinding", "rolebindings"},
+}
+
+// clusterScopedKinds are the cluster-scoped kinds app2kube renders (for
+// rbac.clusterRules). They are kept out of emittedKinds on purpose: kubectl
+// prunes and label-deletes cluster-scoped kinds across the whole cluster, and
+// the app selector (name/instance/managed-by) does not include the namespace,
+// so the same app deployed to another namespace would have its ClusterRole
+// pruned. The CLI removes them by their exact name (GetClusterRBACName)
+// instead.
+var clusterScopedKinds = []EmittedKind{
+	{"rbac.authorization.k8s.io/v1/ClusterRole", "clusterroles"},
+	{"rbac.authorization.k8s.io/v1/ClusterRoleBinding", "clusterrolebindings"},
 }
 
 // certManagerEmittedKind is the cert-manager Certificate kind, kept out of the
@@ -230,6 +390,21 @@ var emittedKinds = []EmittedKind{
 // `certificates` CRDs from other operators.
 var certManagerEmittedKind = EmittedKind{"cert-manager.io/v1/Certificate", "certificates.cert-manager.io"}
 
+// gatewayAPIEmittedKind is the Gateway API HTTPRoute kind, added to the
+// prune/delete sets only in gateway mode (UsesGatewayAPI) for the same reason as
+// certManagerEmittedKind. The Ingress kind stays listed, so the first gateway
+// mode `apply --prune` removes the Ingresses the routes replace.
+var gatewayAPIEmittedKind = EmittedKind{"gateway.networking.k8s.io/v1/HTTPRoute", "httproutes.gateway.networking.k8s.io"}
+
+// prometheusOperatorEmittedKinds are the Prometheus Operator monitor kinds,
+// added to the prune/delete sets only when the app enables metrics
+// (usesPrometheusOperator) for the same reason as certManagerEmittedKind. Both
+// are listed so switching metrics.kind prunes the monitor of the other kind.
+var prometheusOperatorEmittedKinds = []EmittedKind{
+	{"monitoring.coreos.com/v1/PodMonitor", "podmonitors.monitoring.coreos.com"},
+	{"monitoring.coreos.com/v1/ServiceMonitor", "servicemonitors.monitoring.coreos.com"},
+}
+
 // usesCertManager reports whether the app emits any cert-manager Certificate,
 // i.e. letsencrypt is enabled globally or on at least one ingress entry.
 func (app *App) usesCertManager() bool {
@@ -245,16 +420,25 @@ func (app *App) usesCertManager() bool {
 }
 
 // pruneAndDeleteKinds returns the resource kinds app2kube can emit for this
-// specific app, conditionally including the cert-manager Certificate so the
-// prune/delete tooling only references its CRD when letsencrypt is actually in
-// use.
+// specific app, conditionally including the cert-manager Certificate, the
+// Gateway API HTTPRoute and the Prometheus Operator monitors so the
+// prune/delete tooling only references their CRDs when letsencrypt, gateway
+// mode or metrics are actually in use.
 func (app *App) pruneAndDeleteKinds() []EmittedKind {
-	if !app.usesCertManager() {
+	if !app.usesCertManager() && !app.UsesGatewayAPI() && !app.usesPrometheusOperator() {
 		return emittedKinds
 	}
-	kinds := make([]EmittedKind, 0, len(emittedKinds)+1)
+	kinds := make([]EmittedKind, 0, len(emittedKinds)+2+len(prometheusOperatorEmittedKinds))
 	kinds = append(kinds, emittedKinds...)
-	kinds = append(kinds, certManagerEmittedKind)
+	if app.usesCertManager() {
+		kinds = append(kinds, certManagerEmittedKind)
+	}
+	if app.UsesGatewayAPI() {
+		kinds = append(kinds, gatewayAPIEmittedKind)
+	}
+	if app.usesPrometheusOperator() {
+		kinds = append(kinds, prometheusOperatorEmittedKinds...)
+	}
 	return kinds
 }
 
@@ -263,7 +447,8 @@ func (app *App) pruneAndDeleteKinds() []EmittedKind {
 // that drops out of the manifest (e.g. a PDB when replicas scale back to 1) is
 // orphaned. The cert-manager Certificate is included only when this app uses
 // letsencrypt, so a cert-manager-less cluster is not asked to prune a missing
-// CRD.
+// CRD; likewise the HTTPRoute only in gateway mode and the Prometheus Operator
+// monitors only when metrics are enabled.
 func (app *App) PruneWhitelist() []string {
 	kinds := app.pruneAndDeleteKinds()
 	out := make([]string, 0, len(kinds))
@@ -277,7 +462,9 @@ func (app *App) PruneWhitelist() []string {
 // `delete all`. kubectl's own "all" category omits the namespaced extras
 // app2kube emits (configmaps, secrets, pvc, ingress, PDB), so every kind is
 // named explicitly; pods/replicasets/jobs are cascade-deleted with their owners.
-// The cert-manager Certificate is included only when this app uses letsencrypt.
+// The cert-manager Certificate is included only when this app uses letsencrypt,
+// the HTTPRoute only in gateway mode and the Prometheus Operator monitors only
+// when it enables metrics.
 func (app *App) DeleteResourceTypes() string {
 	kinds := app.pruneAndDeleteKinds()
 	names := make([]string, 0, len(kinds))
@@ -332,16 +519,24 @@ func (app *App) GetManifest(outputFormat string, typeOutput ...OutputResource) (
 // outputTypeNames maps the user-facing --type strings to OutputResource values.
 // This is the single source of truth for resource type names.
 var outputTypeNames = map[string]OutputResource{
-	"all":         OutputAll,
-	"certificate": OutputCertificate,
-	"configmap":   OutputConfigMap,
-	"cronjob":     OutputCronJob,
-	"deployment":  OutputDeployment,
-	"ingress":     OutputIngress,
-	"pdb":         OutputPodDisruptionBudget,
-	"pvc":         OutputPersistentVolumeClaim,
-	"secret":      OutputSecret,
-	"service":     OutputService,
+	"all":            OutputAll,
+	"certificate":    OutputCertificate,
+	"configmap":      OutputConfigMap,
+	"cronjob":        OutputCronJob,
+	"deployment":     OutputDeployment,
+	"hook":           OutputHook,
+	"hpa":            OutputHorizontalPodAutoscaler,
+	"httproute":      OutputHTTPRoute,
+	"ingress":        OutputIngress,
+	"monitor":        OutputMonitor,
+	"networkpolicy":  OutputNetworkPolicy,
+	"pdb":            OutputPodDisruptionBudget,
+	"pvc":            OutputPersistentVolumeClaim,
+	"rbac":           OutputRBAC,
+	"secret":         OutputSecret,
+	"service":        OutputService,
+	"serviceaccount": OutputServiceAccount,
+	"statefulset":    OutputStatefulSet,
 }
 
 // ParseOutputType maps a user-facing --type name to an OutputResource. The
@@ -413,6 +608,89 @@ func printObj(obj runtime.Object, printer printers.ResourcePrinter) (string, err
 	), nil
 }
 
+// ManifestIndex names the index ManifestFiles can be listed in.
+type ManifestIndex string
+
+const (
+	// IndexKustomization is a kustomization.yaml listing the files as resources.
+	IndexKustomization ManifestIndex = "kustomization"
+	// IndexList is a list.yaml holding the objects of the files as a v1 List.
+	IndexList ManifestIndex = "list"
+	// IndexNone is no index.
+	IndexNone ManifestIndex = "none"
+)
+
+// ManifestFile is an object of a manifest as a file of its own.
+type ManifestFile struct {
+	// Name is <kind>-<name>.yaml, or .json in the json format, kind lowercase.
+	Name    string
+	Content []byte
+}
+
+// ManifestFiles returns the objects of a manifest GetManifest rendered in
+// outputFormat, yaml or json, as files, in render order. A YAML file starts
+// with the "# Kind: name" comment of its object.
+func ManifestFiles(manifest, outputFormat string) ([]ManifestFile, error) {
+	ext := ".yaml"
+	switch outputFormat {
+	case "yaml":
+	case "json":
+		ext = ".json"
+	default:
+		return nil, fmt.Errorf("a file per object is written in yaml or json, not %s", outputFormat)
+	}
+	var files []ManifestFile
+	for _, doc := range splitManifest(manifest) {
+		header, body := manifestDoc(doc)
+		if outputFormat == "yaml" {
+			body = header + "\n" + body
+		}
+		kind, name := docObject(doc)
+		files = append(files, ManifestFile{Name: strings.ToLower(kind) + "-" + name + ext, Content: []byte(body)})
+	}
+	return files, nil
+}
+
+// manifestDoc returns the "# Kind: name" comment heading an object of a
+// manifest, and the object as rendered.
+func manifestDoc(doc string) (string, string) {
+	header, body, _ := strings.Cut(doc, "\n")
+	return header, strings.TrimSuffix(strings.TrimPrefix(body, "---\n"), "\n\n") + "\n"
+}
+
+// Index returns the file of the index of files, or nil for IndexNone.
+func (index ManifestIndex) Index(files []ManifestFile) (*ManifestFile, error) {
+	switch index {
+	case IndexNone:
+		return nil, nil
+	case IndexKustomization:
+		k := newKustomization()
+		for _, f := range files {
+			k.Resources = append(k.Resources, f.Name)
+		}
+		content, err := yaml.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		return &ManifestFile{Name: "kustomization.yaml", Content: content}, nil
+	case IndexList:
+		items := make([]any, 0, len(files))
+		for _, f := range files {
+			var obj map[string]any
+			if err := yaml.Unmarshal(f.Content, &obj); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			items = append(items, obj)
+		}
+		content, err := yaml.Marshal(map[string]any{"apiVersion": "v1", "kind": "List", "items": items})
+		if err != nil {
+			return nil, err
+		}
+		return &ManifestFile{Name: "list.yaml", Content: content}, nil
+	}
+	return nil, fmt.Errorf("unknown index %q (valid indexes: %s, %s, %s)", index, IndexKustomization, IndexList, IndexNone)
+}
+
 // stripCreationTimestamp removes the metadata `creationTimestamp: null` line
 // that the serializer always emits, at any indentation. It matches the whole
 // trimmed line rather than the bare substring: a substring match would also
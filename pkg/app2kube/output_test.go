@@ -6,13 +6,15 @@ import (
 	"testing"
 
 	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/utils/ptr"
 )
 
 // The prune whitelist and the delete resource list derive from the same single
 // source (emittedKinds), so they must stay in lockstep: equal length, both
-// covering the PodDisruptionBudget, and free of kinds app2kube never emits (the
-// old hand-written whitelist had stale ServiceAccount/DaemonSet entries).
+// covering the PodDisruptionBudget and ServiceAccount, and free of kinds
+// app2kube never emits (the old hand-written whitelist had a stale DaemonSet
+// entry).
 func TestPruneAndDeleteListsDeriveFromRegistry(t *testing.T) {
 	app := NewApp() // no letsencrypt → plain emittedKinds, no cert-manager
 	prune := app.PruneWhitelist()
@@ -23,8 +25,11 @@ func TestPruneAndDeleteListsDeriveFromRegistry(t *testing.T) {
 	if !strings.Contains(app.DeleteResourceTypes(), "poddisruptionbudgets") {
 		t.Errorf("delete list must include poddisruptionbudgets: %q", app.DeleteResourceTypes())
 	}
+	if !strings.Contains(app.DeleteResourceTypes(), "serviceaccounts") {
+		t.Errorf("delete list must include serviceaccounts: %q", app.DeleteResourceTypes())
+	}
 	for _, gvk := range prune {
-		if strings.HasSuffix(gvk, "/ServiceAccount") || strings.HasSuffix(gvk, "/DaemonSet") {
+		if strings.HasSuffix(gvk, "/DaemonSet") {
 			t.Errorf("prune whitelist must not list a kind app2kube never emits: %q", gvk)
 		}
 	}
@@ -88,6 +93,11 @@ func TestEmittedKindsCoverAllGeneratedResources(t *testing.T) {
 		"tick": {Schedule: "* * * * *", Container: apiv1.Container{Command: []string{"true"}}},
 	}
 	app.Ingress = []Ingress{{Host: "full.example.com", TLSCrt: "C", TLSKey: "K"}}
+	app.Deployment.Autoscaling = AutoscalingSpec{Enabled: true, MinReplicas: ptr.To(int32(2)), MaxReplicas: 4}
+	app.NetworkPolicy = NetworkPolicySpec{Enabled: true, AllowSameApp: true}
+	rule := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}
+	app.RBAC = RBACSpec{Rules: []rbacv1.PolicyRule{rule}, ClusterRules: []rbacv1.PolicyRule{rule}}
+	app.Metrics = MetricsSpec{Enabled: true, Port: "web"}
 
 	manifest, err := app.GetManifest("yaml", OutputAll)
 	if err != nil {
@@ -95,7 +105,7 @@ func TestEmittedKindsCoverAllGeneratedResources(t *testing.T) {
 	}
 
 	registered := map[string]bool{}
-	for _, k := range emittedKinds {
+	for _, k := range slices.Concat(app.pruneAndDeleteKinds(), clusterScopedKinds) {
 		parts := strings.Split(k.GVK, "/")
 		registered[parts[len(parts)-1]] = true
 	}
@@ -116,6 +126,41 @@ func TestEmittedKindsCoverAllGeneratedResources(t *testing.T) {
 	if !seen["PodDisruptionBudget"] {
 		t.Errorf("expected a PodDisruptionBudget in the full manifest, kinds seen: %v", seen)
 	}
+	if !seen["ClusterRoleBinding"] || !seen["ServiceAccount"] {
+		t.Errorf("expected the ServiceAccount and RBAC in the full manifest, kinds seen: %v", seen)
+	}
+	if !seen["ServiceMonitor"] {
+		t.Errorf("expected a ServiceMonitor in the full manifest, kinds seen: %v", seen)
+	}
+	if !seen["NetworkPolicy"] {
+		t.Errorf("expected a NetworkPolicy in the full manifest, kinds seen: %v", seen)
+	}
+	if !seen["HorizontalPodAutoscaler"] {
+		t.Errorf("expected a HorizontalPodAutoscaler in the full manifest, kinds seen: %v", seen)
+	}
+}
+
+// The StatefulSet workload mode must register its kind too, so switching an
+// app to deployment.kind: StatefulSet keeps it prunable.
+func TestEmittedKindsCoverStatefulSet(t *testing.T) {
+	app := NewApp()
+	app.Name = "stateful"
+	app.Deployment.Kind = KindStatefulSet
+	app.Deployment.Containers = map[string]apiv1.Container{"app": {Image: "example/app:v1"}}
+
+	manifest, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	if !strings.Contains(manifest, "kind: StatefulSet") {
+		t.Fatalf("expected a StatefulSet in the manifest:\n%s", manifest)
+	}
+	if !slices.Contains(app.PruneWhitelist(), "apps/v1/StatefulSet") {
+		t.Errorf("prune whitelist must include apps/v1/StatefulSet: %v", app.PruneWhitelist())
+	}
+	if !strings.Contains(app.DeleteResourceTypes(), "statefulsets") {
+		t.Errorf("delete list must include statefulsets: %q", app.DeleteResourceTypes())
+	}
 }
 
 // #55: the creationTimestamp-stripping filter must preserve the final line even
@@ -176,3 +221,63 @@ func TestStripCreationTimestamp(t *testing.T) {
 		})
 	}
 }
+
+func TestManifestFiles(t *testing.T) {
+	app := NewApp()
+	app.Name = "shop"
+	app.ConfigMap = map[string]string{"KEY": "value"}
+	app.Deployment.Containers = map[string]apiv1.Container{"app": {Image: "example/shop:1.2"}}
+	for format, want := range map[string][]string{
+		"yaml": {"configmap-shop.yaml", "deployment-shop.yaml"},
+		"json": {"configmap-shop.json", "deployment-shop.json"},
+	} {
+		manifest, err := app.GetManifest(format, OutputConfigMap, OutputDeployment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := ManifestFiles(manifest, format)
+		if err != nil {
+			t.Fatalf("%s: ManifestFiles: %v", format, err)
+		}
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("%s: files %v, want %v", format, names, want)
+		}
+		// The files hold the objects of the manifest, nothing else.
+		prefix := map[string]string{"yaml": "# ConfigMap: shop\napiVersion: v1\n", "json": "{\n"}[format]
+		if content := string(files[0].Content); !strings.HasPrefix(content, prefix) || strings.Contains(content, "---") || strings.HasSuffix(content, "\n\n") {
+			t.Errorf("%s: content %q", format, content)
+		}
+	}
+	if _, err := ManifestFiles("", "wide"); err == nil {
+		t.Error("expected an error for the wide output format")
+	}
+}
+
+func TestManifestIndex(t *testing.T) {
+	files := []ManifestFile{
+		{Name: "configmap-shop.yaml", Content: []byte("# ConfigMap: shop\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: shop\n")},
+		{Name: "service-shop.json", Content: []byte(`{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "shop"}}`)},
+	}
+	for index, want := range map[ManifestIndex]string{
+		IndexKustomization: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- configmap-shop.yaml\n- service-shop.json\n",
+		IndexList:          "apiVersion: v1\nitems:\n- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: shop\n- apiVersion: v1\n  kind: Service\n  metadata:\n    name: shop\nkind: List\n",
+	} {
+		f, err := index.Index(files)
+		if err != nil {
+			t.Fatalf("%s: %v", index, err)
+		}
+		if string(f.Content) != want {
+			t.Errorf("%s: %s:\n%s\nwant:\n%s", index, f.Name, f.Content, want)
+		}
+	}
+	if f, err := IndexNone.Index(files); f != nil || err != nil {
+		t.Errorf("none: got %v, %v", f, err)
+	}
+	if _, err := ManifestIndex("helm").Index(files); err == nil || !strings.Contains(err.Error(), `unknown index "helm"`) {
+		t.Errorf("expected an error for an unknown index, got %v", err)
+	}
+}
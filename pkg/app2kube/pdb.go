@@ -8,18 +8,20 @@ import (
 
 // GetPodDisruptionBudget returns a PodDisruptionBudget that keeps at least one
 // pod running through a voluntary disruption (node drain/upgrade), but only when
-// the Deployment runs more than one replica. A single-replica PDB with
-// minAvailable:1 would block every drain (the node could never be emptied), and
-// nothing is emitted for the no-container case. The selector matches the
-// Deployment's selector (GetColorLabels) so it covers exactly its pods (#47).
+// the Deployment runs more than one replica — with autoscaling, when the HPA's
+// minReplicas is above one, since that is the floor a drain can rely on. A
+// single-replica PDB with minAvailable:1 would block every drain (the node
+// could never be emptied), and nothing is emitted for the no-container case.
+// The selector matches the Deployment's selector (GetColorLabels) so it covers
+// exactly its pods (#47).
 func (app *App) GetPodDisruptionBudget() (*policyv1.PodDisruptionBudget, error) {
 	if len(app.Deployment.Containers) == 0 {
 		return nil, nil
 	}
 
-	replicas := int32(1)
-	if app.Deployment.ReplicaCount != nil {
-		replicas = *app.Deployment.ReplicaCount
+	replicas := app.workloadReplicas()
+	if app.Deployment.Autoscaling.Enabled {
+		replicas = app.autoscalingMinReplicas()
 	}
 	if replicas <= 1 {
 		return nil, nil
@@ -0,0 +1,183 @@
+package app2kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	batch "k8s.io/api/batch/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// PostRenderer is an executable the manifests are piped through before they
+// are printed or applied, like a Helm post-renderer: it reads the rendered
+// objects as YAML on stdin and writes the objects to use instead on stdout.
+type PostRenderer struct {
+	path string
+	args []string
+}
+
+// NewPostRenderer returns the PostRenderer running command with args. A
+// command without a slash is looked up in PATH.
+func NewPostRenderer(command string, args ...string) (*PostRenderer, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("post-renderer: %w", err)
+	}
+	return &PostRenderer{path: path, args: args}, nil
+}
+
+// Run pipes manifest through the executable and returns what it writes to
+// stdout. A failure returns the error with what it wrote to stderr.
+func (p *PostRenderer) Run(manifest string) (string, error) {
+	cmd := exec.Command(p.path, p.args...)
+	cmd.Stdin = strings.NewReader(manifest)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("post-renderer %s: %w: %s", p.path, err, msg)
+		}
+		return "", fmt.Errorf("post-renderer %s: %w", p.path, err)
+	}
+	return stdout.String(), nil
+}
+
+// PostRender pipes manifest, rendered by GetManifest as YAML, through
+// renderer and returns the objects it writes like GetManifest renders them in
+// outputFormat, yaml or json. The items of a List are objects of their own.
+// Every object but a Namespace must keep the labels of the application: prune
+// and delete select the objects of the application with them, so an object
+// without them would be orphaned.
+func (app *App) PostRender(renderer *PostRenderer, manifest, outputFormat string) (string, error) {
+	if outputFormat != "yaml" && outputFormat != "json" {
+		return "", fmt.Errorf("post-rendered manifests are printed in yaml or json, not %s", outputFormat)
+	}
+	objs, err := app.postRenderObjects(renderer, manifest)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	for _, obj := range objs {
+		var body []byte
+		if outputFormat == "json" {
+			body, err = json.MarshalIndent(obj, "", "    ")
+			body = append(body, '\n')
+		} else {
+			body, err = yaml.Marshal(obj)
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&result, "---\n# %s: %s\n%s\n", objectKind(obj), objectName(obj), body)
+	}
+	return result.String(), nil
+}
+
+// PostRenderHookJobs returns the hook Jobs of phase piped through renderer,
+// like `manifest --type hook` renders them, for the CLI to run instead of
+// those of GetHookJobs. The renderer may only return Jobs.
+func (app *App) PostRenderHookJobs(renderer *PostRenderer, phase HookPhase) ([]*batch.Job, error) {
+	jobs, err := app.GetHookJobs(phase)
+	if err != nil || len(jobs) == 0 {
+		return jobs, err
+	}
+	printer, err := objPrinter("yaml")
+	if err != nil {
+		return nil, err
+	}
+	var manifest string
+	for _, job := range jobs {
+		yml, err := printObj(job, printer)
+		if err != nil {
+			return nil, err
+		}
+		manifest += yml
+	}
+
+	objs, err := app.postRenderObjects(renderer, manifest)
+	if err != nil {
+		return nil, err
+	}
+	jobs = make([]*batch.Job, 0, len(objs))
+	for _, obj := range objs {
+		if kind := objectKind(obj); kind != KindJob {
+			return nil, fmt.Errorf("the post-renderer returned %s/%s for the %s hooks: only Jobs are run", kind, objectName(obj), phase)
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		job := &batch.Job{}
+		if err := json.Unmarshal(data, job); err != nil {
+			return nil, fmt.Errorf("post-renderer output: Job %s: %w", objectName(obj), err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// postRenderObjects pipes manifest through renderer and returns the objects
+// it writes, checking each keeps the labels of the application.
+func (app *App) postRenderObjects(renderer *PostRenderer, manifest string) ([]map[string]any, error) {
+	out, err := renderer.Run(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []map[string]any
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(out), 4096)
+	for {
+		var obj map[string]any
+		if err := decoder.Decode(&obj); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("post-renderer output: %w", err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		if obj["kind"] == "List" {
+			items, _ := obj["items"].([]any)
+			for _, item := range items {
+				if item, ok := item.(map[string]any); ok {
+					objs = append(objs, item)
+				}
+			}
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	var stripped []string
+	for _, obj := range objs {
+		kind, name := objectKind(obj), objectName(obj)
+		if kind == "" || name == "" {
+			return nil, errors.New("post-renderer output: an object needs a kind and a name")
+		}
+		if kind == "Namespace" {
+			continue
+		}
+		labels, _ := objectMeta(obj)["labels"].(map[string]any)
+		var missing []string
+		for _, key := range sortedKeys(app.Labels) {
+			if labels[key] != app.Labels[key] {
+				missing = append(missing, key+"="+app.Labels[key])
+			}
+		}
+		if len(missing) > 0 {
+			stripped = append(stripped, fmt.Sprintf("%s/%s (%s)", kind, name, strings.Join(missing, ", ")))
+		}
+	}
+	if len(stripped) > 0 {
+		return nil, fmt.Errorf("the post-renderer stripped the labels of the application from %s: prune and delete would not find them",
+			strings.Join(stripped, ", "))
+	}
+	return objs, nil
+}
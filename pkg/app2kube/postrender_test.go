@@ -0,0 +1,117 @@
+package app2kube
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func postRenderTestApp() *App {
+	app := NewApp()
+	app.Name = "shop"
+	app.Labels[LabelName] = "shop"
+	app.Namespace = "web"
+	app.Deployment.Containers = map[string]apiv1.Container{"app": {Image: "example/shop:1.2"}}
+	return app
+}
+
+func TestPostRender(t *testing.T) {
+	app := postRenderTestApp()
+	manifest, err := app.GetManifest("yaml", OutputNamespace, OutputDeployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := NewPostRenderer("sed", "s#image: example/#image: registry.example.com/example/#")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := app.PostRender(renderer, manifest, "yaml")
+	if err != nil {
+		t.Fatalf("PostRender: %v", err)
+	}
+	for _, want := range []string{"---\n# Namespace: web\napiVersion: v1\n", "---\n# Deployment: shop\napiVersion: apps/v1\n", "image: registry.example.com/example/shop:1.2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %q in\n%s", want, out)
+		}
+	}
+	files, err := ManifestFiles(out, "yaml")
+	if err != nil || len(files) != 2 || files[1].Name != "deployment-shop.yaml" {
+		t.Errorf("the post-rendered manifest does not split into its objects: %v, %v", files, err)
+	}
+
+	out, err = app.PostRender(renderer, manifest, "json")
+	if err != nil {
+		t.Fatalf("PostRender json: %v", err)
+	}
+	if want := "# Deployment: shop\n{\n    \"apiVersion\": \"apps/v1\",\n"; !strings.Contains(out, want) {
+		t.Errorf("no %q in\n%s", want, out)
+	}
+}
+
+func TestPostRenderErrors(t *testing.T) {
+	app := postRenderTestApp()
+	manifest, err := app.GetManifest("yaml", OutputNamespace, OutputDeployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the Namespace may go without the labels of the application.
+	strip, err := NewPostRenderer("sed", `/app.kubernetes.io\/instance/d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.PostRender(strip, manifest, "yaml")
+	if want := "the post-renderer stripped the labels of the application from Deployment/shop (app.kubernetes.io/instance=production): "; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got %v, want %q", err, want)
+	}
+
+	fail, err := NewPostRenderer("sh", "-c", "echo boom >&2; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.PostRender(fail, manifest, "yaml"); err == nil || !strings.Contains(err.Error(), "exit status 3: boom") {
+		t.Errorf("expected the error of the post-renderer, got %v", err)
+	}
+
+	if _, err := app.PostRender(strip, manifest, "wide"); err == nil || !strings.Contains(err.Error(), "yaml or json, not wide") {
+		t.Errorf("expected an error for the wide output format, got %v", err)
+	}
+	if _, err := NewPostRenderer("app2kube-no-such-post-renderer"); err == nil {
+		t.Error("expected an error for a missing executable")
+	}
+}
+
+func TestPostRenderHookJobs(t *testing.T) {
+	app := postRenderTestApp()
+	app.Hooks.PreApply = map[string]HookSpec{
+		"migrate": {Container: apiv1.Container{Image: "example/shop:1.2", Command: []string{"migrate"}}},
+	}
+	renderer, err := NewPostRenderer("sed", "s#image: example/#image: registry.example.com/example/#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := app.PostRenderHookJobs(renderer, HookPreApply)
+	if err != nil {
+		t.Fatalf("PostRenderHookJobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Name != "shop-migrate" || jobs[0].Namespace != "web" {
+		t.Fatalf("unexpected jobs: %v", jobs)
+	}
+	if image := jobs[0].Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/example/shop:1.2" {
+		t.Errorf("image: got %q", image)
+	}
+	if jobs, err := app.PostRenderHookJobs(renderer, HookPreDelete); err != nil || len(jobs) != 0 {
+		t.Errorf("no hook, no job: got %v, %v", jobs, err)
+	}
+
+	// A hook runs a Job, nothing else.
+	rekind, err := NewPostRenderer("sed", "s/^kind: Job$/kind: Pod/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.PostRenderHookJobs(rekind, HookPreApply); err == nil || !strings.Contains(err.Error(), "Pod/shop-migrate for the pre-apply hooks") {
+		t.Errorf("expected an error for a Pod, got %v", err)
+	}
+}
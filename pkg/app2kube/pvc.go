@@ -6,8 +6,12 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 )
 
-// GetPersistentVolumeClaims resource
+// GetPersistentVolumeClaims resource. A StatefulSet app emits none: its
+// volumes become per-replica volumeClaimTemplates (GetStatefulSet).
 func (app *App) GetPersistentVolumeClaims() (claims []*apiv1.PersistentVolumeClaim, err error) {
+	if app.IsStatefulSet() {
+		return nil, nil
+	}
 	for _, volName := range sortedKeys(app.Volumes) {
 		volume := app.Volumes[volName]
 		if volume.MountPath == "" {
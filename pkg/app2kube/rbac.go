@@ -0,0 +1,126 @@
+package app2kube
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// usesRBAC reports whether the app requests any API permissions.
+func (app *App) usesRBAC() bool {
+	return len(app.RBAC.Rules) > 0 || len(app.RBAC.ClusterRules) > 0
+}
+
+// UsesClusterRBAC reports whether the app renders a ClusterRole and
+// ClusterRoleBinding (rbac.clusterRules is set).
+func (app *App) UsesClusterRBAC() bool {
+	return len(app.RBAC.ClusterRules) > 0
+}
+
+// createsServiceAccount reports whether app2kube renders the app's
+// ServiceAccount: on request, or implicitly when RBAC is requested and no
+// existing account is named (the bindings need a subject).
+func (app *App) createsServiceAccount() bool {
+	return app.ServiceAccount.Create || (app.usesRBAC() && app.Common.ServiceAccountName == "")
+}
+
+// GetServiceAccountName returns the ServiceAccount the app's pods run as:
+// common.serviceAccountName when set, the release name when app2kube creates
+// the account, otherwise empty (the namespace default account). The canary
+// track runs as the stable account.
+func (app *App) GetServiceAccountName() string {
+	if app.Common.ServiceAccountName != "" {
+		return app.Common.ServiceAccountName
+	}
+	if app.createsServiceAccount() {
+		return truncateNameTo(app.stableReleaseName(), MaxSubdomainNameLength)
+	}
+	return ""
+}
+
+// mountServiceAccountToken returns the pods' automountServiceAccountToken:
+// common.mountServiceAccountToken when set, otherwise true only when RBAC is
+// requested — permissions the pods cannot authenticate with are useless, while
+// every other app keeps the token unmounted.
+func (app *App) mountServiceAccountToken() bool {
+	if app.Common.MountServiceAccountToken != nil {
+		return *app.Common.MountServiceAccountToken
+	}
+	return app.usesRBAC()
+}
+
+// GetClusterRBACName returns the name of the ClusterRole and
+// ClusterRoleBinding. Cluster-scoped names are shared by every namespace, so
+// the release name is prefixed with the namespace to keep the same app in two
+// namespaces from overwriting each other's permissions.
+func (app *App) GetClusterRBACName() string {
+	return truncateNameTo(app.rbacSubjectNamespace()+"-"+app.GetReleaseName(), MaxSubdomainNameLength)
+}
+
+// rbacSubjectNamespace is the namespace of the ServiceAccount subject; a
+// binding subject of kind ServiceAccount must name one.
+func (app *App) rbacSubjectNamespace() string {
+	if app.Namespace == "" {
+		return NamespaceDefault
+	}
+	return app.Namespace
+}
+
+// rbacSubjects binds the app's ServiceAccount.
+func (app *App) rbacSubjects() []rbacv1.Subject {
+	return []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      app.GetServiceAccountName(),
+		Namespace: app.rbacSubjectNamespace(),
+	}}
+}
+
+// GetServiceAccount returns the app's ServiceAccount when app2kube creates it.
+func (app *App) GetServiceAccount() *apiv1.ServiceAccount {
+	if !app.createsServiceAccount() {
+		return nil
+	}
+	meta := app.GetObjectMeta(app.GetServiceAccountName())
+	meta.Annotations = app.ServiceAccount.Annotations
+	return &apiv1.ServiceAccount{ObjectMeta: meta}
+}
+
+// GetRole returns the Role and RoleBinding granting rbac.rules to the app's
+// ServiceAccount in its namespace, both named after the release.
+func (app *App) GetRole() (*rbacv1.Role, *rbacv1.RoleBinding) {
+	if len(app.RBAC.Rules) == 0 {
+		return nil, nil
+	}
+	name := app.GetReleaseName()
+	role := &rbacv1.Role{
+		ObjectMeta: app.GetObjectMeta(name),
+		Rules:      app.RBAC.Rules,
+	}
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: app.GetObjectMeta(name),
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+		Subjects:   app.rbacSubjects(),
+	}
+	return role, binding
+}
+
+// GetClusterRole returns the ClusterRole and ClusterRoleBinding granting
+// rbac.clusterRules to the app's ServiceAccount cluster-wide, both named
+// GetClusterRBACName and carrying no namespace.
+func (app *App) GetClusterRole() (*rbacv1.ClusterRole, *rbacv1.ClusterRoleBinding) {
+	if !app.UsesClusterRBAC() {
+		return nil, nil
+	}
+	name := app.GetClusterRBACName()
+	meta := metav1.ObjectMeta{Name: name, Labels: app.Labels}
+	role := &rbacv1.ClusterRole{
+		ObjectMeta: meta,
+		Rules:      app.RBAC.ClusterRules,
+	}
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: meta,
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+		Subjects:   app.rbacSubjects(),
+	}
+	return role, binding
+}
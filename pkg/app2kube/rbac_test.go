@@ -0,0 +1,86 @@
+package app2kube
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/utils/ptr"
+)
+
+var podReader = rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}
+
+// RBAC rules imply a ServiceAccount named after the release that the pods run
+// as, with the token mounted so they can use the granted permissions.
+func TestRBACCreatesServiceAccount(t *testing.T) {
+	app := deployApp(t)
+	app.Namespace = "prod"
+	app.RBAC.Rules = []rbacv1.PolicyRule{podReader}
+	app.RBAC.ClusterRules = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}}}
+
+	sa := app.GetServiceAccount()
+	if sa == nil || sa.Name != "example" || sa.Namespace != "prod" {
+		t.Fatalf("expected ServiceAccount prod/example, got %+v", sa)
+	}
+
+	role, binding := app.GetRole()
+	if role == nil || role.Name != "example" || len(role.Rules) != 1 {
+		t.Fatalf("role: got %+v", role)
+	}
+	if binding.RoleRef.Kind != "Role" || binding.RoleRef.Name != "example" {
+		t.Errorf("roleRef: got %+v", binding.RoleRef)
+	}
+	if s := binding.Subjects[0]; s.Kind != rbacv1.ServiceAccountKind || s.Name != "example" || s.Namespace != "prod" {
+		t.Errorf("subject: got %+v", s)
+	}
+
+	clusterRole, clusterBinding := app.GetClusterRole()
+	if clusterRole == nil || clusterRole.Name != "prod-example" || clusterRole.Namespace != "" {
+		t.Fatalf("cluster role must be namespace-prefixed and cluster-scoped: %+v", clusterRole)
+	}
+	if clusterBinding.RoleRef.Kind != "ClusterRole" || clusterBinding.Subjects[0].Namespace != "prod" {
+		t.Errorf("cluster binding: got %+v", clusterBinding)
+	}
+
+	dep, err := app.GetDeployment()
+	if err != nil {
+		t.Fatalf("GetDeployment: %v", err)
+	}
+	spec := dep.Spec.Template.Spec
+	if spec.ServiceAccountName != "example" || !*spec.AutomountServiceAccountToken {
+		t.Errorf("pods must run as the created account with its token: sa=%q automount=%v", spec.ServiceAccountName, *spec.AutomountServiceAccountToken)
+	}
+
+	// An explicit mountServiceAccountToken still wins.
+	app.Common.MountServiceAccountToken = ptr.To(false)
+	if app.mountServiceAccountToken() {
+		t.Error("explicit mountServiceAccountToken: false must be honored")
+	}
+}
+
+// Without RBAC nothing changes: no account is created and the token stays
+// unmounted; an existing account named in common.serviceAccountName is bound
+// instead of creating one.
+func TestServiceAccountDefaults(t *testing.T) {
+	app := deployApp(t)
+	if app.GetServiceAccount() != nil || app.GetServiceAccountName() != "" || app.mountServiceAccountToken() {
+		t.Error("no ServiceAccount and no token expected without RBAC")
+	}
+	if role, _ := app.GetRole(); role != nil {
+		t.Error("no Role expected without rbac.rules")
+	}
+
+	app.Common.ServiceAccountName = "existing"
+	app.RBAC.Rules = []rbacv1.PolicyRule{podReader}
+	if app.GetServiceAccount() != nil {
+		t.Error("an existing account must be bound, not created")
+	}
+	if _, binding := app.GetRole(); binding.Subjects[0].Name != "existing" {
+		t.Errorf("binding must target the existing account, got %q", binding.Subjects[0].Name)
+	}
+
+	app.ServiceAccount = ServiceAccountSpec{Create: true, Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn"}}
+	sa := app.GetServiceAccount()
+	if sa == nil || sa.Name != "existing" || sa.Annotations["eks.amazonaws.com/role-arn"] != "arn" {
+		t.Errorf("serviceAccount.create must create the named account with its annotations: %+v", sa)
+	}
+}
@@ -0,0 +1,364 @@
+package app2kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// SchemaDraft is the JSON Schema dialect ValuesSchema is written in.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema that ValuesSchema generates and strict
+// values loading checks against.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        schemaTypes        `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is false for a struct (only its properties are
+	// allowed) and the element schema for a map.
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// schemaTypes is the "type" keyword: a single type name or a list of them.
+type schemaTypes []string
+
+// MarshalJSON writes a single type as a plain string.
+func (t schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// schemaOverrides describes the types whose custom JSON decoding accepts
+// something other than their Go shape.
+var schemaOverrides = map[reflect.Type]schemaTypes{
+	reflect.TypeOf(intstr.IntOrString{}): {"integer", "string"},
+	reflect.TypeOf(resource.Quantity{}):  {"string", "number"},
+	reflect.TypeOf(metav1.Duration{}):    {"string"},
+	reflect.TypeOf(metav1.Time{}):        {"string"},
+	reflect.TypeOf(metav1.MicroTime{}):   {"string"},
+	reflect.TypeOf(Staging{}):            {"string", "boolean"},
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// ValuesSchema returns the JSON Schema of the values LoadValues accepts,
+// generated from the App struct. Every object is closed: a key the App does
+// not know is an error, not silently dropped. Named structs are shared through
+// $defs, keyed by package and type name.
+func ValuesSchema() *Schema {
+	g := schemaGenerator{defs: map[string]*Schema{}}
+	root := g.structSchema(reflect.TypeOf(App{}))
+	root.Schema = SchemaDraft
+	root.Title = "app2kube values"
+	// Value files name the files they extend and include; these keys are
+	// resolved while loading and never reach the App.
+	for _, key := range []string{extendsKey, includeKey} {
+		root.Properties[key] = &Schema{
+			Description: "Value files merged before this one, relative to it (value files only)",
+			Type:        schemaTypes{"string", "array"},
+			Items:       &Schema{Type: schemaTypes{"string"}},
+		}
+	}
+	root.Properties[appsKey] = &Schema{
+		Description: "Applications sharing the other values: their values with a name, or value files and directories named after them",
+		Type:        schemaTypes{"string", "array"},
+		Items:       &Schema{Type: schemaTypes{"object", "string"}},
+	}
+	root.Defs = g.defs
+	return root
+}
+
+type schemaGenerator struct {
+	defs map[string]*Schema
+}
+
+// schemaFor returns the schema of a field of type t: a $ref for named structs,
+// an inline schema otherwise.
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if types, ok := schemaOverrides[t]; ok {
+		return &Schema{Type: types}
+	}
+	// Any other type decoding itself may accept anything.
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: schemaTypes{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: schemaTypes{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: schemaTypes{"number"}}
+	case reflect.String:
+		return &Schema{Type: schemaTypes{"string"}}
+	case reflect.Slice, reflect.Array:
+		// encoding/json takes []byte as a base64 string.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: schemaTypes{"string"}}
+		}
+		return &Schema{Type: schemaTypes{"array"}, Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: schemaTypes{"object"}, AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		key := schemaDefKey(t)
+		if _, ok := g.defs[key]; !ok {
+			// Reserve the key first: a recursive type refers back to itself.
+			g.defs[key] = &Schema{}
+			*g.defs[key] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/$defs/" + key}
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema returns the closed object schema of a struct, inlining the
+// fields of embedded structs as encoding/json does.
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 schemaTypes{"object"},
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.addFields(s, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaFor(f.Type)
+	}
+}
+
+// schemaDefKey names a struct in $defs: app2kube types by their name, others
+// after their Go package too, e.g. "k8s.io.api.core.v1.Container".
+func schemaDefKey(t reflect.Type) string {
+	if t.PkgPath() == reflect.TypeOf(App{}).PkgPath() {
+		return t.Name()
+	}
+	return strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+}
+
+// ValuesError lists every key of the merged values that does not match
+// ValuesSchema, each with the value source it came from.
+type ValuesError struct {
+	Problems []string
+}
+
+func (e *ValuesError) Error() string {
+	return "invalid values:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// validateValues checks the merged values against the schema. origins maps a
+// value path to the file or flag that set it.
+func validateValues(schema *Schema, values map[string]any, origins valueOrigins) error {
+	v := schemaValidator{defs: schema.Defs, origins: origins}
+	v.validate(schema, "", values)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValuesError{Problems: v.problems}
+}
+
+type schemaValidator struct {
+	defs     map[string]*Schema
+	origins  valueOrigins
+	problems []string
+}
+
+func (v *schemaValidator) resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		s = v.defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	}
+	return s
+}
+
+func (v *schemaValidator) report(path, format string, args ...any) {
+	where := path
+	if source := v.origins.lookup(path); source != "" {
+		where += " (" + source + ")"
+	}
+	v.problems = append(v.problems, where+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) validate(s *Schema, path string, value any) {
+	s = v.resolve(s)
+	// A null is an unset key everywhere, as it is for encoding/json.
+	if value == nil || len(s.Type) == 0 {
+		return
+	}
+	got := valueType(value)
+	if !s.Type.accepts(got) {
+		v.report(path, "expected %s, got %s", strings.Join(s.Type, " or "), got)
+		return
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(val) {
+			child := joinValuePath(path, key)
+			if prop, ok := s.Properties[key]; ok {
+				v.validate(prop, child, val[key])
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case *Schema:
+				v.validate(extra, child, val[key])
+			case bool:
+				if !extra {
+					msg := fmt.Sprintf("unknown key %q", key)
+					if guess := suggestKey(key, sortedKeys(s.Properties)); guess != "" {
+						msg += fmt.Sprintf(", did you mean %q?", guess)
+					}
+					v.report(child, "%s", msg)
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range val {
+				v.validate(s.Items, path+"["+strconv.Itoa(i)+"]", item)
+			}
+		}
+	}
+}
+
+// accepts reports whether a value of JSON type got matches the schema type.
+// A string takes any scalar, as the typed decode turns `PORT: 8080` into the
+// string "8080".
+func (t schemaTypes) accepts(got string) bool {
+	for _, want := range t {
+		switch {
+		case want == got:
+			return true
+		case want == "number" && got == "integer":
+			return true
+		case want == "string" && (got == "integer" || got == "number" || got == "boolean"):
+			return true
+		}
+	}
+	return false
+}
+
+// valueType returns the JSON type name of a decoded value. Integers are
+// "integer" whatever their Go type, as are floats without a fraction.
+func valueType(value any) string {
+	switch val := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float64:
+		if val == math.Trunc(val) && !math.IsInf(val, 0) {
+			return "integer"
+		}
+		return "number"
+	case float32:
+		return "number"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// joinValuePath appends a key to a dotted value path.
+func joinValuePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggestKey returns the known key closest to a mistyped one, or "" when none
+// is close enough to be a likely typo. Case differences count as a perfect
+// match, since `Name:` for `name:` is the usual slip.
+func suggestKey(key string, known []string) string {
+	best, bestDist := "", math.MaxInt
+	for _, k := range known {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+		if d := levenshtein(strings.ToLower(key), strings.ToLower(k)); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	// Allow about one edit per three characters, at least two.
+	if bestDist > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
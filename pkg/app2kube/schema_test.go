@@ -0,0 +1,152 @@
+package app2kube
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValuesSchema(t *testing.T) {
+	schema := ValuesSchema()
+	if schema.AdditionalProperties != false {
+		t.Errorf("the root object must be closed")
+	}
+	if got := schema.Properties["deployment"].Ref; got != "#/$defs/DeploymentSpec" {
+		t.Errorf("deployment: got ref %q", got)
+	}
+	deployment := schema.Defs["DeploymentSpec"]
+	if deployment == nil || deployment.Properties["replicaCount"] == nil {
+		t.Fatalf("DeploymentSpec must list replicaCount: %+v", deployment)
+	}
+	// The fields of the embedded DeploymentSpec are inlined into workloads.
+	if schema.Defs["WorkloadSpec"].Properties["containers"] == nil {
+		t.Errorf("WorkloadSpec must inline the DeploymentSpec fields")
+	}
+
+	out, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, want := range []string{
+		`"$schema":"https://json-schema.org/draft/2020-12/schema"`,
+		`"staging":{"type":["string","boolean"]}`,
+		`"k8s.io.api.core.v1.Container":{`,
+		`"containerPort":{"type":"integer"}`,
+		`"port":{"type":["integer","string"]}`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("schema missing %s", want)
+		}
+	}
+}
+
+func TestLoadValuesStrict(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "values.yaml")
+	content := `
+name: example
+deploymnet:
+  replicaCount: 2
+deployment:
+  replicaCont: 2
+  containers:
+    app:
+      image: example/app
+      ports:
+        - containerPort: http
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewApp().LoadValues(ValueFiles{path}, []string{"namespace.name=prod"}, nil, nil)
+	var verr *ValuesError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValuesError, got %v", err)
+	}
+	want := []string{
+		`deployment.containers.app.ports[0].containerPort (` + path + `:11): expected integer, got string`,
+		`deployment.replicaCont (` + path + `:6): unknown key "replicaCont", did you mean "replicaCount"?`,
+		`deploymnet (` + path + `:3): unknown key "deploymnet", did you mean "deployment"?`,
+		`namespace (--set namespace.name=prod): expected string, got object`,
+	}
+	if strings.Join(verr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\ngot:\n%s\nwant:\n%s", strings.Join(verr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Keys the schema accepts through custom decoding pass.
+	app := NewApp()
+	if _, err := app.LoadValues(nil, []string{
+		"name=example",
+		"staging=true",
+		"deployment.containers.app.resources.limits.cpu=1",
+		"service.http.port=80",
+	}, nil, nil); err != nil {
+		t.Errorf("LoadValues: %v", err)
+	}
+}
+
+// Unquoted numbers and booleans in string maps load as strings, whether a
+// file, --set or the environment sets them.
+func TestLoadValuesStrictScalarStrings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "values.yaml")
+	content := `
+name: example
+env:
+  PORT: 8080
+  DEBUG: true
+configmap:
+  RATIO: 0.5
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp()
+	if _, err := app.LoadValueSources(ValueSources{
+		Files:   ValueFiles{path},
+		Values:  []string{"env.WORKERS=4"},
+		Environ: []string{"APP2KUBE_SET_SECRETS__PIN=1234"},
+	}); err != nil {
+		t.Fatalf("LoadValueSources: %v", err)
+	}
+	got := map[string]string{
+		"PORT": app.Env["PORT"], "DEBUG": app.Env["DEBUG"], "WORKERS": app.Env["WORKERS"],
+		"RATIO": app.ConfigMap["RATIO"], "PIN": app.Secrets["PIN"],
+	}
+	want := map[string]string{"PORT": "8080", "DEBUG": "true", "WORKERS": "4", "RATIO": "0.5", "PIN": "1234"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLoadValuesSchemaValidationDisabled(t *testing.T) {
+	app := NewApp()
+	app.SetSchemaValidation(false)
+	if _, err := app.LoadValues(nil, []string{"name=example", "deploymnet.replicaCount=2"}, nil, nil); err != nil {
+		t.Fatalf("an unknown key must be ignored without schema validation: %v", err)
+	}
+	if app.Name != "example" {
+		t.Errorf("name: got %q", app.Name)
+	}
+}
+
+func TestSuggestKey(t *testing.T) {
+	known := []string{"containers", "initContainers", "kind", "replicaCount"}
+	cases := map[string]string{
+		"replicacount": "replicaCount",
+		"contianers":   "containers",
+		"Kind":         "kind",
+		"strategy":     "",
+	}
+	for key, want := range cases {
+		if got := suggestKey(key, known); got != want {
+			t.Errorf("suggestKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -51,6 +51,28 @@ func (svc Service) effectiveServicePort() int32 {
 	return external
 }
 
+// servicePortName returns the name of the port of the Service generated for a
+// `service:` entry: its key as a DNS label, so a ServiceMonitor can refer to it.
+func servicePortName(key string) string {
+	return truncateName(sanitizeDNSName(key))
+}
+
+// namesServicePorts reports whether the Service ports are named: only a
+// ServiceMonitor refers to them, and naming them otherwise would change the
+// Services of every app.
+func (app *App) namesServicePorts() bool {
+	return app.usesPrometheusOperator() && app.metricsKind() == KindServiceMonitor
+}
+
+// servicePortNames returns the port names of the app's Services, sorted.
+func (app *App) servicePortNames() []string {
+	names := make([]string, 0, len(app.Service))
+	for _, key := range sortedKeys(app.Service) {
+		names = append(names, servicePortName(key))
+	}
+	return names
+}
+
 // GetServices resource
 func (app *App) GetServices() (services []*apiv1.Service, err error) {
 	if len(app.Deployment.Containers) > 0 {
@@ -82,6 +104,10 @@ func (app *App) GetServices() (services []*apiv1.Service, err error) {
 				},
 			}
 
+			if app.namesServicePorts() {
+				service.Spec.Ports[0].Name = servicePortName(name)
+			}
+
 			// Only pin a node port when the requested ExternalPort falls inside
 			// the default node-port range; otherwise leave it unset so the
 			// apiserver auto-assigns a valid port instead of rejecting the
@@ -97,6 +123,12 @@ func (app *App) GetServices() (services []*apiv1.Service, err error) {
 
 			services = append(services, service)
 		}
+
+		// A StatefulSet needs its governing headless Service; it is emitted with
+		// the other Services so `--type service` and the prune set cover it.
+		if headless := app.GetHeadlessService(); headless != nil {
+			services = append(services, headless)
+		}
 	}
 	return
 }
@@ -0,0 +1,96 @@
+package app2kube
+
+import (
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// GetStatefulSet returns the app's StatefulSet when deployment.kind is
+// StatefulSet. It shares the pod template with the Deployment
+// (workloadPodTemplate), but every app.Volume mounted by its containers becomes
+// a per-replica volumeClaimTemplate instead of a shared PVC — the claim
+// template name equals the volume name, so the mounts processContainer adds
+// resolve to each replica's own claim ("<volume>-<statefulset>-<ordinal>").
+func (app *App) GetStatefulSet() (*appsv1.StatefulSet, error) {
+	if len(app.Deployment.Containers) == 0 || !app.IsStatefulSet() {
+		return nil, nil
+	}
+	// The blue/green rotation pre-deletes and recreates the target-color
+	// workload and then flips the Service selector; doing that to a
+	// StatefulSet would orphan or swap its per-replica claims.
+	if app.Deployment.BlueGreenColor != "" {
+		return nil, errors.New("blue/green deployment is not supported with deployment.kind StatefulSet")
+	}
+
+	// Per-replica claims cannot deadlock scheduling, so podVolumes gets
+	// replicas=1 to skip the Deployment-only ReadWriteOnce warning.
+	template, err := app.workloadPodTemplate(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims []apiv1.PersistentVolumeClaim
+	volumes := template.Spec.Volumes[:0]
+	for _, vol := range template.Spec.Volumes {
+		spec, ok := app.Volumes[vol.Name]
+		if !ok || vol.PersistentVolumeClaim == nil {
+			volumes = append(volumes, vol)
+			continue
+		}
+		// Same fail-fast rule as GetPersistentVolumeClaims: an omitted
+		// accessModes yields a claim template the apiserver rejects (#48).
+		if len(spec.Spec.AccessModes) == 0 {
+			return nil, fmt.Errorf("accessModes required for PVC: %s", vol.Name)
+		}
+		claims = append(claims, apiv1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   vol.Name,
+				Labels: app.Labels,
+			},
+			Spec: spec.Spec,
+		})
+	}
+	template.Spec.Volumes = volumes
+
+	return &appsv1.StatefulSet{
+		ObjectMeta: app.GetObjectMeta(app.GetDeploymentName()),
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:             app.specReplicas(),
+			RevisionHistoryLimit: ptr.To(app.Deployment.RevisionHistoryLimit),
+			// Same full-label selector as the Deployment, so the Services and the
+			// PDB select the StatefulSet pods unchanged.
+			Selector: &metav1.LabelSelector{
+				MatchLabels: app.GetColorLabels(),
+			},
+			ServiceName:          app.GetHeadlessServiceName(),
+			PodManagementPolicy:  app.Deployment.StatefulSet.PodManagementPolicy,
+			UpdateStrategy:       app.Deployment.StatefulSet.UpdateStrategy,
+			Template:             template,
+			VolumeClaimTemplates: claims,
+		},
+	}, nil
+}
+
+// GetHeadlessService returns the governing Service of the StatefulSet: a
+// selector-only headless Service (clusterIP: None) that gives each replica a
+// stable DNS name ("<pod>.<release>-headless"). It carries no ports — a
+// headless Service may omit them and per-pod A records do not need them — so it
+// never has to reconcile port names with the app's regular Services.
+func (app *App) GetHeadlessService() *apiv1.Service {
+	if len(app.Deployment.Containers) == 0 || !app.IsStatefulSet() {
+		return nil
+	}
+	return &apiv1.Service{
+		ObjectMeta: app.GetObjectMeta(app.GetHeadlessServiceName()),
+		Spec: apiv1.ServiceSpec{
+			ClusterIP:                apiv1.ClusterIPNone,
+			Selector:                 app.GetColorLabels(),
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package app2kube
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// statefulApp returns a StatefulSet app with one ReadWriteOnce volume mounted
+// by its single app-image container.
+func statefulApp(t *testing.T) *App {
+	t.Helper()
+	app := deployApp(t)
+	app.Common.Image.Repository = "example/app"
+	app.Deployment.Kind = "statefulset" // case-insensitive
+	app.Deployment.Containers = map[string]apiv1.Container{"app": {}}
+	app.Deployment.ReplicaCount = ptr.To(int32(3))
+	app.Volumes = map[string]VolumeSpec{
+		"data": {MountPath: "/data", Spec: apiv1.PersistentVolumeClaimSpec{
+			AccessModes: []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteOnce},
+		}},
+	}
+	return app
+}
+
+func TestGetStatefulSetVolumeClaimTemplates(t *testing.T) {
+	app := statefulApp(t)
+
+	sts, err := app.GetStatefulSet()
+	if err != nil {
+		t.Fatalf("GetStatefulSet: %v", err)
+	}
+	if sts == nil {
+		t.Fatal("expected a StatefulSet")
+	}
+	if sts.Name != "example" || *sts.Spec.Replicas != 3 {
+		t.Errorf("name/replicas: got %q/%d", sts.Name, *sts.Spec.Replicas)
+	}
+	if sts.Spec.ServiceName != "example-headless" {
+		t.Errorf("serviceName: got %q, want example-headless", sts.Spec.ServiceName)
+	}
+	if len(sts.Spec.VolumeClaimTemplates) != 1 || sts.Spec.VolumeClaimTemplates[0].Name != "data" {
+		t.Fatalf("volumeClaimTemplates: got %+v", sts.Spec.VolumeClaimTemplates)
+	}
+	// The claim template replaces the shared PVC volume: the pod must not
+	// reference a standalone "<release>-data" claim.
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			t.Errorf("pod volume %q must come from the claim template, not a PVC reference", v.Name)
+		}
+	}
+	mounts := sts.Spec.Template.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].Name != "data" {
+		t.Errorf("container must mount the claim template by name: %+v", mounts)
+	}
+
+	// No standalone PVC and no Deployment in StatefulSet mode.
+	claims, err := app.GetPersistentVolumeClaims()
+	if err != nil || len(claims) != 0 {
+		t.Errorf("StatefulSet app must emit no standalone PVC: %v (err %v)", claims, err)
+	}
+	dep, err := app.GetDeployment()
+	if err != nil || dep != nil {
+		t.Errorf("StatefulSet app must emit no Deployment: %v (err %v)", dep, err)
+	}
+}
+
+func TestGetStatefulSetUpdateStrategyAndPolicy(t *testing.T) {
+	app := statefulApp(t)
+	app.Deployment.StatefulSet = StatefulSetSpec{
+		PodManagementPolicy: appsv1.ParallelPodManagement,
+		UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+			Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: ptr.To(int32(2))},
+		},
+	}
+	sts, err := app.GetStatefulSet()
+	if err != nil {
+		t.Fatalf("GetStatefulSet: %v", err)
+	}
+	if sts.Spec.PodManagementPolicy != appsv1.ParallelPodManagement {
+		t.Errorf("podManagementPolicy: got %q", sts.Spec.PodManagementPolicy)
+	}
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru == nil || *ru.Partition != 2 {
+		t.Errorf("partition not preserved: %+v", sts.Spec.UpdateStrategy)
+	}
+}
+
+func TestGetStatefulSetRejectsBlueGreen(t *testing.T) {
+	app := statefulApp(t)
+	app.Deployment.BlueGreenColor = "blue"
+	if _, err := app.GetStatefulSet(); err == nil {
+		t.Error("blue/green must be rejected for a StatefulSet")
+	}
+}
+
+func TestGetStatefulSetMissingAccessModes(t *testing.T) {
+	app := statefulApp(t)
+	app.Volumes["data"] = VolumeSpec{MountPath: "/data"}
+	if _, err := app.GetStatefulSet(); err == nil || !strings.Contains(err.Error(), "accessModes") {
+		t.Errorf("expected accessModes error, got %v", err)
+	}
+}
+
+// The governing headless Service is emitted with the other Services and
+// selects the StatefulSet pods.
+func TestGetServicesIncludesHeadlessForStatefulSet(t *testing.T) {
+	app := statefulApp(t)
+	app.Service = map[string]Service{"web": {Port: 80}}
+
+	services, err := app.GetServices()
+	if err != nil {
+		t.Fatalf("GetServices: %v", err)
+	}
+	if len(services) != 2 {
+		t.Fatalf("expected web + headless services, got %d", len(services))
+	}
+	headless := services[1]
+	if headless.Name != "example-headless" || headless.Spec.ClusterIP != apiv1.ClusterIPNone {
+		t.Errorf("headless service: got name=%q clusterIP=%q", headless.Name, headless.Spec.ClusterIP)
+	}
+	if headless.Spec.Selector[LabelName] != app.Labels[LabelName] {
+		t.Errorf("headless selector must match the pod labels: %v", headless.Spec.Selector)
+	}
+}
+
+// A CronJob pod has no ordinal, so in StatefulSet mode it must not reference
+// the (never created) standalone claim of a per-replica volume.
+func TestCronJobDropsStatefulSetVolumeMounts(t *testing.T) {
+	app := statefulApp(t)
+	app.Cronjob = map[string]CronjobSpec{
+		"tick": {Schedule: "* * * * *", Container: apiv1.Container{Command: []string{"true"}}},
+	}
+	crons, err := app.GetCronJobs()
+	if err != nil {
+		t.Fatalf("GetCronJobs: %v", err)
+	}
+	pod := crons[0].Spec.JobTemplate.Spec.Template.Spec
+	if len(pod.Volumes) != 0 || len(pod.Containers[0].VolumeMounts) != 0 {
+		t.Errorf("cron pod must carry no per-replica volume: volumes=%+v mounts=%+v", pod.Volumes, pod.Containers[0].VolumeMounts)
+	}
+}
+
+func TestLoadValuesUnknownDeploymentKind(t *testing.T) {
+	app := NewApp()
+	_, err := app.LoadValues(nil, []string{"name=example", "deployment.kind=DaemonSet"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "deployment.kind") {
+		t.Errorf("expected unknown deployment.kind error, got %v", err)
+	}
+}
+
+// A `headless` Service entry would collide with the governing headless
+// Service of a StatefulSet.
+func TestLoadValuesHeadlessServiceCollision(t *testing.T) {
+	_, err := NewApp().LoadValues(nil, []string{"name=example", "deployment.kind=StatefulSet", "service.Headless.port=80"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `service "Headless" is named like the headless Service`) {
+		t.Errorf("expected a headless Service collision, got %v", err)
+	}
+	if _, err := NewApp().LoadValues(nil, []string{"name=example", "service.Headless.port=80"}, nil, nil); err != nil {
+		t.Errorf("a Deployment has no headless Service: %v", err)
+	}
+}
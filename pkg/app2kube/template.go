@@ -0,0 +1,301 @@
+package app2kube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+)
+
+// Version is the app2kube version, exposed to value templates as
+// .Chart.Version. The CLI sets it at startup.
+var Version = "DEV"
+
+// maxTemplatePasses bounds the renders of the value files: references between
+// values settle in one pass per level of nesting, so values still changing
+// after this many passes refer to themselves.
+const maxTemplatePasses = 10
+
+// templateContext is the data every value file is rendered with.
+type templateContext struct {
+	// Values holds the merged values: on the first pass those of the earlier
+	// files and the --set flags, then the complete result of the previous pass.
+	Values  map[string]any
+	Release templateRelease
+	Chart   templateChart
+	Files   templateFiles
+}
+
+// templateRelease describes the release the values are for.
+type templateRelease struct {
+	Branch    string
+	IsStaging bool
+	Name      string
+	Namespace string
+	Staging   string
+}
+
+// templateChart describes app2kube itself, mirroring Helm's .Chart.
+type templateChart struct {
+	Name    string
+	Version string
+}
+
+// templateFiles reads files next to the value file being rendered.
+type templateFiles struct {
+	dir string
+	// blocked refuses every read, for safe templates.
+	blocked bool
+}
+
+// Get returns the content of a file, relative to the value file's directory.
+func (f templateFiles) Get(name string) (string, error) {
+	if f.blocked {
+		return "", errors.New("reading files (.Files) is not allowed in safe template mode")
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(f.dir, name)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newTemplateContext returns the context of a value file in dir rendered
+// against values.
+func newTemplateContext(values map[string]any, dir string) *templateContext {
+	ctx := &templateContext{
+		Values:  values,
+		Release: templateRelease{Namespace: NamespaceDefault},
+		Chart:   templateChart{Name: "app2kube", Version: Version},
+		Files:   templateFiles{dir: dir},
+	}
+	if name, ok := values["name"]; ok && name != nil {
+		ctx.Release.Name = fmt.Sprint(name)
+	}
+	if ns, ok := values["namespace"]; ok && ns != nil && fmt.Sprint(ns) != "" {
+		ctx.Release.Namespace = fmt.Sprint(ns)
+	}
+	if branch, ok := values["branch"]; ok && branch != nil {
+		ctx.Release.Branch = fmt.Sprint(branch)
+	}
+	// Decode staging the way the App does, so `staging: true` is anonymous.
+	if raw, err := json.Marshal(values["staging"]); err == nil {
+		var staging Staging
+		if staging.UnmarshalJSON(raw) == nil {
+			ctx.Release.IsStaging, ctx.Release.Staging = staging.Active, staging.Name
+		}
+	}
+	return ctx
+}
+
+// pinnedFuncs are the template functions whose result changes from call to
+// call. Value files are rendered several times, so these are pinned to the
+// result of their first call or the passes would never agree.
+var pinnedFuncs = []string{
+	"encryptAES", "genCA", "genPrivateKey", "genSelfSignedCert", "genSignedCert",
+	"getHostByName", "now", "randAlpha", "randAlphaNum", "randAscii",
+	"randNumeric", "shuffle", "uuidv4",
+}
+
+// pinnedCalls remembers the results of the pinned functions of one value file
+// across its renders, keyed by function, arguments and call order.
+type pinnedCalls map[string][]reflect.Value
+
+// funcs returns a copy of base whose pinned functions replay earlier results.
+// Call it once per render: the call order restarts with every map.
+func (p pinnedCalls) funcs(base template.FuncMap) template.FuncMap {
+	funcs := make(template.FuncMap, len(base))
+	for name, fn := range base {
+		funcs[name] = fn
+	}
+	calls := map[string]int{}
+	for _, name := range pinnedFuncs {
+		fn, ok := base[name]
+		if !ok {
+			continue
+		}
+		fv := reflect.ValueOf(fn)
+		funcs[name] = reflect.MakeFunc(fv.Type(), func(args []reflect.Value) []reflect.Value {
+			call := name
+			for _, arg := range args {
+				call += " " + fmt.Sprint(arg.Interface())
+			}
+			key := call + "#" + strconv.Itoa(calls[call])
+			calls[call]++
+			if res, ok := p[key]; ok {
+				return res
+			}
+			var res []reflect.Value
+			if fv.Type().IsVariadic() {
+				res = fv.CallSlice(args)
+			} else {
+				res = fv.Call(args)
+			}
+			p[key] = res
+			return res
+		}).Interface()
+	}
+	return funcs
+}
+
+// unsafeFuncs are the template functions safe templates refuse: they read the
+// environment, resolve DNS or return random data. env is allowed for the
+// variables a safe template policy lists.
+var unsafeFuncs = []string{
+	"encryptAES", "env", "expandenv", "genCA", "genPrivateKey",
+	"genSelfSignedCert", "genSignedCert", "getHostByName", "randAlpha",
+	"randAlphaNum", "randAscii", "randNumeric", "shuffle", "uuidv4",
+}
+
+// templatePolicy restricts what value file templates may do. A nil policy
+// allows everything.
+type templatePolicy struct {
+	allowedEnv map[string]bool
+}
+
+// newTemplatePolicy returns the safe template policy exposing the listed
+// environment variables.
+func newTemplatePolicy(allowedEnv []string) *templatePolicy {
+	p := &templatePolicy{allowedEnv: map[string]bool{}}
+	for _, name := range allowedEnv {
+		p.allowedEnv[name] = true
+	}
+	return p
+}
+
+// funcs returns the template functions allowed by the policy. The refused ones
+// stay defined, so a template using them still parses and check can report
+// where.
+func (p *templatePolicy) funcs(base template.FuncMap) template.FuncMap {
+	if p == nil {
+		return base
+	}
+	funcs := make(template.FuncMap, len(base))
+	for name, fn := range base {
+		funcs[name] = fn
+	}
+	for _, name := range unsafeFuncs {
+		funcs[name] = func(...any) (string, error) {
+			return "", fmt.Errorf("function %q is not allowed in safe template mode", name)
+		}
+	}
+	funcs["env"] = func(name string) (string, error) {
+		if !p.allowedEnv[name] {
+			return "", fmt.Errorf("environment variable %q is not allowed in safe template mode", name)
+		}
+		return os.Getenv(name), nil
+	}
+	return funcs
+}
+
+// check reports the first use of a refused function, of an environment
+// variable not allowed by name, or of .Files in the parsed templates, with its
+// file and line. Uses check cannot see, such as env with a computed name, fail
+// when executed instead.
+func (p *templatePolicy) check(tmpl *template.Template) error {
+	if p == nil {
+		return nil
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		var err error
+		walkTemplate(t.Tree.Root, func(node, next parse.Node) bool {
+			var msg string
+			switch n := node.(type) {
+			case *parse.IdentifierNode:
+				if n.Ident == "env" {
+					if s, ok := next.(*parse.StringNode); ok && !p.allowedEnv[s.Text] {
+						msg = fmt.Sprintf("environment variable %q is not allowed in safe template mode", s.Text)
+					}
+				} else if slices.Contains(unsafeFuncs, n.Ident) {
+					msg = fmt.Sprintf("function %q is not allowed in safe template mode", n.Ident)
+				}
+			case *parse.FieldNode:
+				if n.Ident[0] == "Files" {
+					msg = "reading files (.Files) is not allowed in safe template mode"
+				}
+			case *parse.VariableNode:
+				if len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == "Files" {
+					msg = "reading files (.Files) is not allowed in safe template mode"
+				}
+			}
+			if msg == "" {
+				return true
+			}
+			location, _ := t.Tree.ErrorContext(node)
+			err = fmt.Errorf("%s: %s", location, msg)
+			return false
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTemplate calls visit for every node of a template tree in order, with the
+// node following it in the same command (the first argument of a function), until
+// visit returns false.
+func walkTemplate(node parse.Node, visit func(node, next parse.Node) bool) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return true
+		}
+		for _, child := range n.Nodes {
+			if !walkTemplate(child, visit) {
+				return false
+			}
+		}
+	case *parse.ActionNode:
+		return walkTemplate(n.Pipe, visit)
+	case *parse.PipeNode:
+		if n == nil {
+			return true
+		}
+		for _, cmd := range n.Cmds {
+			if !walkTemplate(cmd, visit) {
+				return false
+			}
+		}
+	case *parse.CommandNode:
+		for i, arg := range n.Args {
+			var next parse.Node
+			if i+1 < len(n.Args) {
+				next = n.Args[i+1]
+			}
+			if !visit(arg, next) || !walkTemplate(arg, visit) {
+				return false
+			}
+		}
+	case *parse.ChainNode:
+		if !visit(n.Node, nil) {
+			return false
+		}
+		return walkTemplate(n.Node, visit)
+	case *parse.IfNode:
+		return walkBranch(&n.BranchNode, visit)
+	case *parse.RangeNode:
+		return walkBranch(&n.BranchNode, visit)
+	case *parse.WithNode:
+		return walkBranch(&n.BranchNode, visit)
+	case *parse.TemplateNode:
+		return walkTemplate(n.Pipe, visit)
+	}
+	return true
+}
+
+func walkBranch(n *parse.BranchNode, visit func(node, next parse.Node) bool) bool {
+	return walkTemplate(n.Pipe, visit) && walkTemplate(n.List, visit) && walkTemplate(n.ElseList, visit)
+}
@@ -0,0 +1,175 @@
+package app2kube
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeValueFiles writes the named value files into a temporary directory and
+// returns their paths in the given order.
+func writeValueFiles(t *testing.T, files ...[2]string) ValueFiles {
+	t.Helper()
+	dir := t.TempDir()
+	var paths ValueFiles
+	for _, f := range files {
+		path := filepath.Join(dir, f[0])
+		if err := os.WriteFile(path, []byte(f[1]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestValsTemplateContext(t *testing.T) {
+	files := writeValueFiles(t,
+		[2]string{"base.yaml", `
+name: example
+staging: stg
+ingress:
+  - host: {{ .Values.name }}.{{ .Values.domain }}
+configmap:
+  RELEASE: {{ .Release.Name }}-{{ .Release.Staging }}-{{ .Release.Branch }}
+  NAMESPACE: {{ .Release.Namespace }}
+  CHART: {{ .Chart.Name }}
+  GREETING: {{ .Files.Get "greeting.txt" | trim }}
+`},
+		[2]string{"domain.yaml", `domain: {{ .Values.zone }}`},
+		[2]string{"greeting.txt", "hello\n"},
+	)
+	loaded, err := vals(ValueSources{Files: files[:2], Values: []string{"zone=example.com", "branch=feat"}}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	for _, want := range []string{
+		"- host: example.example.com",
+		"RELEASE: example-stg-feat",
+		"NAMESPACE: default",
+		"CHART: app2kube",
+		"GREETING: hello",
+		"domain: example.com",
+	} {
+		if !strings.Contains(string(loaded.raw), want) {
+			t.Errorf("merged values missing %q:\n%s", want, loaded.raw)
+		}
+	}
+}
+
+// A value a later file overrides is seen by the earlier files' templates with
+// its final value, not the one they set themselves.
+func TestValsTemplateLaterOverride(t *testing.T) {
+	files := writeValueFiles(t,
+		[2]string{"base.yaml", "name: base\nenv:\n  APP: '{{ .Values.name }}'\n"},
+		[2]string{"override.yaml", "name: override\n"},
+	)
+	loaded, err := vals(ValueSources{Files: files}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if !strings.Contains(string(loaded.raw), "APP: override") {
+		t.Errorf("the template must see the final name:\n%s", loaded.raw)
+	}
+}
+
+func TestValsTemplateCycle(t *testing.T) {
+	files := writeValueFiles(t, [2]string{"v.yaml", "name: 'x{{ .Values.name }}'\n"})
+	_, err := vals(ValueSources{Files: files}, nil)
+	if err == nil || !strings.Contains(err.Error(), "do not settle") || !strings.Contains(err.Error(), ": name") {
+		t.Errorf("expected a cycle error naming the value, got %v", err)
+	}
+}
+
+// Functions returning a new result on every call are pinned across the
+// passes, so a file mixing them with value references still settles.
+func TestValsTemplatePinnedFuncs(t *testing.T) {
+	files := writeValueFiles(t, [2]string{"v.yaml", `
+name: example
+secrets:
+  TOKEN: {{ randAlphaNum 16 }}
+  OTHER: {{ randAlphaNum 16 }}
+  APP: {{ .Values.name }}
+`})
+	loaded, err := vals(ValueSources{Files: files}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	secrets := loaded.values["secrets"].(map[string]any)
+	if secrets["TOKEN"] == secrets["OTHER"] {
+		t.Errorf("each call must keep its own result: %v", secrets)
+	}
+}
+
+// A file whose template fails on the first pass only because a value is not
+// settled yet renders on a later one; an error that persists is reported.
+func TestValsTemplateError(t *testing.T) {
+	files := writeValueFiles(t,
+		[2]string{"ports.yaml", "port: {{ .Values.ports | first }}\n"},
+		[2]string{"list.yaml", "ports: [8080]\n"},
+	)
+	loaded, err := vals(ValueSources{Files: files}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if !strings.Contains(string(loaded.raw), "port: 8080") {
+		t.Errorf("the forward reference must resolve:\n%s", loaded.raw)
+	}
+
+	// A function failing on a value of the same file, not rendered yet.
+	files = writeValueFiles(t, [2]string{"self.yaml", "name: example\nenv:\n  NAME: {{ .Values.name | upper }}\n"})
+	loaded, err = vals(ValueSources{Files: files}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if !strings.Contains(string(loaded.raw), "NAME: EXAMPLE") {
+		t.Errorf("the reference to the same file must resolve:\n%s", loaded.raw)
+	}
+
+	files = writeValueFiles(t, [2]string{"bad.yaml", "port: {{ .Values.missing | first }}\n"})
+	if _, err := vals(ValueSources{Files: files}, nil); err == nil || !strings.Contains(err.Error(), "bad.yaml") {
+		t.Errorf("expected the error of bad.yaml, got %v", err)
+	}
+}
+
+func TestValsSafeTemplates(t *testing.T) {
+	t.Setenv("APP2KUBE_TEST_ALLOWED", "allowed")
+	policy := newTemplatePolicy([]string{"APP2KUBE_TEST_ALLOWED"})
+
+	files := writeValueFiles(t, [2]string{"v.yaml", `
+name: example
+configmap:
+  ALLOWED: {{ env "APP2KUBE_TEST_ALLOWED" }}
+  UPPER: {{ .Values.name | upper }}
+`})
+	loaded, err := vals(ValueSources{Files: files}, policy)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if !strings.Contains(string(loaded.raw), "ALLOWED: allowed") || !strings.Contains(string(loaded.raw), "UPPER: EXAMPLE") {
+		t.Errorf("safe templates must keep values and pure functions:\n%s", loaded.raw)
+	}
+
+	cases := map[string]struct {
+		template string
+		want     string
+	}{
+		"env not listed":  {"name: x\nhome: {{ env \"HOME\" }}\n", `v.yaml:2:9: environment variable "HOME" is not allowed`},
+		"expandenv":       {"name: {{ expandenv \"$HOME\" }}\n", `v.yaml:1:9: function "expandenv" is not allowed`},
+		"dns":             {"name: x\n{{ if false }}{{ getHostByName \"example.com\" }}{{ end }}\n", `v.yaml:2:17: function "getHostByName" is not allowed`},
+		"random in pipe":  {"name: {{ 8 | randAlphaNum }}\n", `function "randAlphaNum" is not allowed`},
+		"files":           {"name: {{ .Files.Get \"v.yaml\" }}\n", `v.yaml:1:15: reading files (.Files) is not allowed`},
+		"computed env":    {"name: {{ env (print \"HO\" \"ME\") }}\n", `environment variable "HOME" is not allowed`},
+		"files from root": {"name: {{ with .Values }}{{ $.Files.Get \"v.yaml\" }}{{ end }}\n", `reading files (.Files) is not allowed`},
+		"extends":         {"extends: other.yaml\nname: x\n", `v.yaml: extends and include are not allowed in safe template mode`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			files := writeValueFiles(t, [2]string{"v.yaml", tc.template})
+			_, err := vals(ValueSources{Files: files}, policy)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"text/template"
+	"text/template/parse"
 
 	"github.com/Masterminds/sprig"
 	"sigs.k8s.io/yaml"
@@ -38,12 +43,176 @@ func (v *ValueFiles) Set(value string) error {
 	return nil
 }
 
+// UnsetValue is the sentinel value that deletes a key set by an earlier
+// layer, e.g. `annotations: {example.com/debug: ~unset}` in an overlay file.
+// In a list it drops the entry.
+const UnsetValue = "~unset"
+
+// ReplaceValue, as the first entry of a list merged by key, replaces the list
+// of the earlier layers instead of merging with it, e.g.
+// `ingress: [~replace, {host: example.com}]`.
+const ReplaceValue = "~replace"
+
+// listMergeKeys maps the keys whose lists are merged entry by entry, like a
+// strategic merge patch, to the field identifying an entry. Other lists are
+// replaced wholesale.
+var listMergeKeys = map[string]string{
+	"apps":    "name",
+	"env":     "name",
+	"ingress": "host",
+	"ports":   "name",
+}
+
+// EnvValuePrefix starts the names of the environment variables setting
+// values: APP2KUBE_SET_DEPLOYMENT__REPLICACOUNT=3 sets deployment.replicaCount.
+const EnvValuePrefix = "APP2KUBE_SET_"
+
+// ValueSources lists the values LoadValueSources merges, in the order they
+// are applied: later sources win.
+type ValueSources struct {
+	// BaseFiles are merged first, such as the default .app2kube.yml.
+	BaseFiles ValueFiles
+	// OverlayDir holds the environment overlays merged after BaseFiles:
+	// <environment>.yml and <environment>-<branch>.yml, if they exist.
+	OverlayDir string
+	// Environment selects the overlays. When empty, the staging name of the
+	// values is used; anonymous staging selects none.
+	Environment string
+	// Files are the -f/--values files, each merged over the earlier ones.
+	Files ValueFiles
+	// Environ holds environment variables as "NAME=value", as returned by
+	// os.Environ. Those named with EnvValuePrefix set values, in name order.
+	Environ []string
+	// JSONValues are --set-json expressions, whose values are JSON.
+	JSONValues []string
+	// Values are --set expressions, typed like YAML scalars.
+	Values []string
+	// StringValues are --set-string expressions, always strings.
+	StringValues []string
+	// FileValues are --set-file expressions, each reading a file.
+	FileValues []string
+	// LiteralValues are --set-literal expressions: one key each, whose value
+	// is taken verbatim, commas and brackets included.
+	LiteralValues []string
+	// Unset are --unset paths, such as "ingress[0].annotations.example.com/debug",
+	// deleted from the merged values last.
+	Unset []string
+	// App selects, by name, the application of values declaring several
+	// under apps. Such values require it.
+	App string
+}
+
+// Empty reports whether the sources set no values at all.
+func (s ValueSources) Empty() bool {
+	return len(s.BaseFiles)+len(s.Files)+len(s.JSONValues)+len(s.Values)+len(s.StringValues)+
+		len(s.FileValues)+len(s.LiteralValues)+len(envValues(s.Environ)) == 0
+}
+
+// envValue is a value set by an environment variable, as a --set expression.
+type envValue struct {
+	name string
+	expr string
+	// str is set when the schema types the value as a string: it is then
+	// parsed like --set-string.
+	str bool
+}
+
+// parseInto sets the value in dest.
+func (v envValue) parseInto(dest map[string]any) error {
+	if v.str {
+		return strvals.ParseIntoString(v.expr, dest)
+	}
+	return strvals.ParseInto(v.expr, dest)
+}
+
+// envValues returns the values set by the EnvValuePrefix variables of environ,
+// in name order. The rest of a variable name is the value path, with "__"
+// between the keys: a key matching a property of ValuesSchema regardless of
+// case takes its spelling, other keys (container, configmap or label names)
+// are kept as written. The value is never split at commas, and typed as with
+// --set unless the schema types it as a string, such as a configmap value.
+func envValues(environ []string) []envValue {
+	var values []envValue
+	var schema *Schema
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvValuePrefix) || name == EnvValuePrefix {
+			continue
+		}
+		if schema == nil {
+			schema = ValuesSchema()
+		}
+		keys := strings.Split(strings.TrimPrefix(name, EnvValuePrefix), "__")
+		current := schema
+		for i, key := range keys {
+			current, keys[i] = schemaProperty(schema, current, key)
+			keys[i] = escapeSetValue(keys[i])
+		}
+		for current != nil && current.Ref != "" {
+			current = schema.Defs[strings.TrimPrefix(current.Ref, "#/$defs/")]
+		}
+		values = append(values, envValue{
+			name: name,
+			expr: strings.Join(keys, ".") + "=" + escapeSetValue(value),
+			str:  current != nil && slices.Equal(current.Type, schemaTypes{"string"}),
+		})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].name < values[j].name })
+	return values
+}
+
+// schemaProperty returns the schema of key in the object schema s, with the
+// spelling of the matching property. root holds the $defs.
+func schemaProperty(root, s *Schema, key string) (*Schema, string) {
+	if s == nil {
+		return nil, key
+	}
+	for s.Ref != "" {
+		s = root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	}
+	for _, name := range sortedKeys(s.Properties) {
+		if strings.EqualFold(name, key) {
+			return s.Properties[name], name
+		}
+	}
+	if extra, ok := s.AdditionalProperties.(*Schema); ok {
+		return extra, key
+	}
+	return nil, key
+}
+
+// escapeSetValue escapes the runes strvals would read as syntax.
+func escapeSetValue(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\,.=[]{}`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // Merges source and destination map, preferring values from the source map
 func mergeValues(dest map[string]any, src map[string]any) map[string]any {
 	for k, v := range src {
+		// The unset sentinel deletes the key, whatever an earlier file set.
+		if v == UnsetValue {
+			delete(dest, k)
+			continue
+		}
+		// A list under a merge key updates the earlier list entry by entry.
+		if srcList, ok := v.([]any); ok {
+			if destList, ok := dest[k].([]any); ok {
+				if positions, keyed := listPositions(k, destList, srcList); keyed {
+					dest[k] = mergeList(destList, srcList, positions)
+					continue
+				}
+			}
+		}
 		// If the key doesn't exist already, then just set the key to that value
 		if _, exists := dest[k]; !exists {
-			dest[k] = v
+			dest[k] = stripReplace(v)
 			continue
 		}
 		// A nil source value (a bare/null key like `common:` in a later -f file)
@@ -56,14 +225,14 @@ func mergeValues(dest map[string]any, src map[string]any) map[string]any {
 		nextMap, ok := v.(map[string]any)
 		// If it isn't another map, overwrite the value
 		if !ok {
-			dest[k] = v
+			dest[k] = stripReplace(v)
 			continue
 		}
 		// Edge case: If the key exists in the destination, but isn't a map
 		destMap, isMap := dest[k].(map[string]any)
 		// If the source map has a map for this key, prefer it
 		if !isMap {
-			dest[k] = v
+			dest[k] = stripReplace(v)
 			continue
 		}
 		// If we got to this point, it is a map in both, so merge them
@@ -72,56 +241,550 @@ func mergeValues(dest map[string]any, src map[string]any) map[string]any {
 	return dest
 }
 
-// vals merges values from files specified via -f/--values and
-// directly via --set or --set-string or --set-file, marshaling them to YAML
-func vals(valueFiles ValueFiles, values, stringValues, fileValues []string) ([]byte, error) {
-	base := map[string]any{}
+// listPositions returns the position every entry of src takes in the list
+// under key name once merged over dest, -1 for the ReplaceValue marker, and
+// whether the lists are merged by key. They are not when name has no merge
+// key, src starts with ReplaceValue or an entry of either list lacks the
+// merge key (e.g. an unnamed port): src then replaces dest.
+func listPositions(name string, dest, src []any) ([]int, bool) {
+	positions := make([]int, len(src))
+	next := 0
+	for i, entry := range src {
+		positions[i] = -1
+		if entry != ReplaceValue {
+			positions[i] = next
+			next++
+		}
+	}
+	field, ok := listMergeKeys[name]
+	if !ok || dest == nil || (len(src) > 0 && src[0] == ReplaceValue) {
+		return positions, false
+	}
 
-	// User specified a values files via -f/--values
-	for _, filePath := range valueFiles {
-		currentMap := map[string]any{}
+	index := map[string]int{}
+	for i, entry := range dest {
+		id, ok := listEntryKey(entry, field)
+		if !ok {
+			return positions, false
+		}
+		if _, seen := index[id]; !seen {
+			index[id] = i
+		}
+	}
+	keyed := make([]int, len(src))
+	next = len(dest)
+	for i, entry := range src {
+		id, ok := listEntryKey(entry, field)
+		if !ok {
+			return positions, false
+		}
+		if _, seen := index[id]; !seen {
+			index[id] = next
+			next++
+		}
+		keyed[i] = index[id]
+	}
+	return keyed, true
+}
+
+// listEntryKey returns the merge key of a list entry.
+func listEntryKey(entry any, field string) (string, bool) {
+	m, ok := entry.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	id, ok := m[field].(string)
+	return id, ok && id != ""
+}
 
-		var bytes []byte
-		var err error
-		if strings.TrimSpace(filePath) == "-" {
-			bytes, err = io.ReadAll(os.Stdin)
+// mergeList merges the entries of src into dest at the positions from
+// listPositions: an entry with the key of an earlier one is merged into it,
+// the others are appended.
+func mergeList(dest, src []any, positions []int) []any {
+	for i, entry := range src {
+		if p := positions[i]; p < len(dest) {
+			dest[p] = mergeValues(dest[p].(map[string]any), entry.(map[string]any))
 		} else {
-			bytes, err = readFile(filePath)
+			dest = append(dest, stripReplace(entry))
 		}
+	}
+	return dest
+}
 
+// stripReplace removes the ReplaceValue markers from the lists of a value
+// merged as a whole, so the merged values never hold one.
+func stripReplace(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			val[k] = stripReplace(child)
+		}
+	case []any:
+		kept := val[:0]
+		for _, child := range val {
+			if child != ReplaceValue {
+				kept = append(kept, stripReplace(child))
+			}
+		}
+		return kept
+	}
+	return v
+}
+
+// valueFile is one -f/--values file, read once and rendered on every pass.
+type valueFile struct {
+	path   string
+	source string
+	dir    string
+	raw    []byte
+	pinned pinnedCalls
+	// last is the result of the latest render that succeeded and text the
+	// YAML it was parsed from. err is the error of the latest render.
+	last map[string]any
+	text []byte
+	err  error
+	// key identifies the file for cycle detection; children are the files it
+	// extends or includes, by path.
+	key      string
+	children map[string]*valueFile
+}
+
+// Value files name the files they are composed of under these keys.
+const (
+	extendsKey = "extends"
+	includeKey = "include"
+)
+
+// valueLayer is the values one file contributes to a pass.
+type valueLayer struct {
+	file   *valueFile
+	values map[string]any
+}
+
+// newValueFile reads the value file at path: "-" reads stdin, a trailing "?"
+// allows the file to be missing.
+func newValueFile(path string) (*valueFile, error) {
+	f := &valueFile{path: path, source: strings.TrimSuffix(path, "?"), pinned: pinnedCalls{}, children: map[string]*valueFile{}}
+	var err error
+	if strings.TrimSpace(path) == "-" {
+		f.source, f.dir, f.key = "stdin", ".", "stdin"
+		f.raw, err = io.ReadAll(os.Stdin)
+		return f, err
+	}
+	f.dir = filepath.Dir(f.source)
+	if f.key, err = filepath.Abs(f.source); err != nil {
+		return nil, err
+	}
+	f.raw, err = readFile(path)
+	return f, err
+}
+
+// layers renders the file against values and returns the layers it merges,
+// in order: those of the files it extends, then of the files it includes,
+// then its own values without the extends and include keys, the application
+// files they list resolved relative to the file. chain lists the
+// files extending or including this one. A render error is kept in err for
+// the file to contribute its fallback instead, as the value files do.
+func (f *valueFile) layers(values map[string]any, policy *templatePolicy, chain []*valueFile) ([]valueLayer, error) {
+	for i, parent := range chain {
+		if parent.key == f.key {
+			names := make([]string, 0, len(chain)-i+1)
+			for _, c := range chain[i:] {
+				names = append(names, c.source)
+			}
+			return nil, fmt.Errorf("value files include each other: %s", strings.Join(append(names, f.source), " -> "))
+		}
+	}
+
+	own, err := f.render(values, policy)
+	f.err = err
+	if err != nil {
+		own = f.fallback()
+	}
+	var paths []string
+	for _, key := range []string{extendsKey, includeKey} {
+		names, err := composedFiles(own[key])
 		if err != nil {
-			return []byte{}, err
+			return nil, fmt.Errorf("%s: %s: %w", f.source, key, err)
 		}
+		paths = append(paths, names...)
+	}
 
-		// Apply template to file
-		bytes, err = templating(bytes)
+	// Like .Files, composition would read any local file.
+	if len(paths) > 0 && policy != nil {
+		return nil, fmt.Errorf("%s: extends and include are not allowed in safe template mode", f.source)
+	}
+	var layers []valueLayer
+	for _, path := range paths {
+		child, err := f.child(path)
 		if err != nil {
-			return []byte{}, err
+			return nil, fmt.Errorf("%s: %w", f.source, err)
+		}
+		childLayers, err := child.layers(values, policy, append(chain, f))
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, childLayers...)
+	}
+	apps, hasApps := own[appsKey]
+	if len(paths) > 0 || hasApps {
+		stripped := make(map[string]any, len(own))
+		for k, v := range own {
+			if k != extendsKey && k != includeKey {
+				stripped[k] = v
+			}
+		}
+		if hasApps {
+			stripped[appsKey] = f.appPaths(apps)
+		}
+		own = stripped
+	}
+	return append(layers, valueLayer{file: f, values: own}), nil
+}
+
+// composedFiles returns the file names of an extends or include value: a
+// single name or a list of them.
+func composedFiles(value any) ([]string, error) {
+	switch val := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{val}, nil
+	case []any:
+		names := make([]string, 0, len(val))
+		for _, v := range val {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a file name, got %s", valueType(v))
+			}
+			names = append(names, name)
 		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("expected a file name or a list of file names, got %s", valueType(value))
+	}
+}
+
+// child returns the file path names, relative to this file's directory, read
+// on first use.
+func (f *valueFile) child(path string) (*valueFile, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.dir, path)
+	}
+	if child, ok := f.children[path]; ok {
+		return child, nil
+	}
+	child, err := newValueFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f.children[path] = child
+	return child, nil
+}
+
+// render templates the file against values under policy and parses the
+// result.
+func (f *valueFile) render(values map[string]any, policy *templatePolicy) (map[string]any, error) {
+	data := newTemplateContext(values, f.dir)
+	data.Files.blocked = policy != nil
+	bytes, err := templating(f.source, f.raw, data, f.pinned.funcs(policy.funcs(sprig.TxtFuncMap())), policy)
+	if err != nil {
+		return nil, err
+	}
+	currentMap := map[string]any{}
+	if err := yaml.Unmarshal(bytes, &currentMap); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", f.path, err)
+	}
+	f.last, f.text = currentMap, bytes
+	return currentMap, nil
+}
 
-		if err := yaml.Unmarshal(bytes, &currentMap); err != nil {
-			return []byte{}, fmt.Errorf("failed to parse %s: %w", filePath, err)
+// fallback returns what the file contributes to a pass its render fails in:
+// its latest successful render or, before it ever rendered, the values of its
+// template text alone. The latter lets the file's own static values settle
+// when a template refers to them.
+func (f *valueFile) fallback() map[string]any {
+	if f.last != nil {
+		return f.last
+	}
+	tmpl, err := template.New(f.source).Funcs(sprig.TxtFuncMap()).Parse(string(f.raw))
+	if err != nil || tmpl.Tree == nil {
+		return nil
+	}
+	var text bytes.Buffer
+	for _, node := range tmpl.Tree.Root.Nodes {
+		if t, ok := node.(*parse.TextNode); ok {
+			text.Write(t.Text)
+		}
+	}
+	static := map[string]any{}
+	if yaml.Unmarshal(text.Bytes(), &static) != nil {
+		return nil
+	}
+	return static
+}
+
+// loadedValues is the result of vals.
+type loadedValues struct {
+	// raw is the merged values as YAML and values as a map.
+	raw    []byte
+	values map[string]any
+	// origins has the file or flag that set every value.
+	origins valueOrigins
+	// overlays are the environment overlay files merged.
+	overlays []string
+	// apps names the applications the values declare. The values are those
+	// of the one selected, or else the shared values with apps.
+	apps []string
+}
+
+// vals merges values from files specified via -f/--values and
+// directly via --set or --set-string or --set-file, marshaling them to YAML.
+// It also returns the merged map and, for every value path, the file or flag
+// that last set it.
+//
+// Value files are templates that may refer to other values (.Values,
+// .Release), including those set by later files or by the files themselves, so
+// they are rendered in passes until the merged values stop changing. The first
+// pass gives each file the values of the earlier files and the flags; every
+// later pass gives all files the complete result of the previous one. A file
+// whose render fails contributes its fallback to the pass: it may depend on a
+// value not settled yet, and its error is reported only if it still fails at
+// the end.
+// A value file may name files it extends and includes, relative to itself;
+// their values merge before its own, as if they were given before it.
+// The environment overlays are looked up with the environment and branch the
+// values without them set, and then merged after the base files.
+// Values declaring apps yield those of the application src.App, if set.
+// A non-nil policy renders the files as safe templates.
+func vals(src ValueSources, policy *templatePolicy) (*loadedValues, error) {
+	// User specified a values files via -f/--values
+	baseFiles, err := readValueFiles(src.BaseFiles)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readValueFiles(src.Files)
+	if err != nil {
+		return nil, err
+	}
+	base, layers, err := mergeValueFiles(nil, append(baseFiles, files...), src, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlays []string
+	if src.OverlayDir != "" {
+		overlays = findOverlays(src.OverlayDir, src.Environment, base)
+		if len(overlays) > 0 {
+			overlayFiles, err := readValueFiles(overlays)
+			if err != nil {
+				return nil, err
+			}
+			all := append(append(baseFiles, overlayFiles...), files...)
+			if base, layers, err = mergeValueFiles(nil, all, src, policy); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, layer := range layers {
+		if layer.file.err != nil {
+			return nil, layer.file.err
+		}
+	}
+
+	// The origins of each file are recorded over the files before it, so the
+	// entries of a list merged by key get their merged positions.
+	origins := valueOrigins{}
+	merged := map[string]any{}
+	for _, layer := range layers {
+		if layer.values == nil {
+			continue
+		}
+		var lines map[string]int
+		if layer.file.last != nil {
+			lines = valueLines(layer.file.text)
+		}
+		origins.record(layer.values, merged, layer.file.source, lines)
+		merged = mergeValues(merged, copyValues(layer.values).(map[string]any))
+	}
+	entries, err := appEntries(base[appsKey], policy)
+	if err != nil {
+		return nil, err
+	}
+	apps := appNames(entries)
+	if src.App != "" {
+		// The entries may come from the flags, the shared values must not.
+		if base, err = selectApp(merged, entries, origins, src, policy); err != nil {
+			return nil, err
+		}
+	}
+	// Each flag value is parsed a second time into an empty map only to learn
+	// which paths it sets.
+	for _, value := range envValues(src.Environ) {
+		set := map[string]any{}
+		if value.parseInto(set) == nil {
+			origins.record(set, nil, "env "+value.name, nil)
+		}
+	}
+	for _, value := range src.JSONValues {
+		set := map[string]any{}
+		if strvals.ParseJSON(value, set) == nil {
+			origins.record(set, nil, "--set-json "+value, nil)
+		}
+	}
+	for _, value := range src.Values {
+		set := map[string]any{}
+		if strvals.ParseInto(value, set) == nil {
+			origins.record(set, nil, "--set "+value, nil)
+		}
+	}
+	for _, value := range src.StringValues {
+		set := map[string]any{}
+		if strvals.ParseIntoString(value, set) == nil {
+			origins.record(set, nil, "--set-string "+value, nil)
+		}
+	}
+	for _, value := range src.FileValues {
+		set := map[string]any{}
+		if strvals.ParseIntoFile(value, set, func([]rune) (any, error) { return "", nil }) == nil {
+			origins.record(set, nil, "--set-file "+value, nil)
+		}
+	}
+	for _, value := range src.LiteralValues {
+		set := map[string]any{}
+		if strvals.ParseLiteralInto(value, set) == nil {
+			origins.record(set, nil, "--set-literal "+value, nil)
+		}
+	}
+
+	out, err := yaml.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	return &loadedValues{raw: out, values: base, origins: origins, overlays: overlays, apps: apps}, nil
+}
+
+// readValueFiles reads the value files at paths.
+func readValueFiles(paths ValueFiles) ([]*valueFile, error) {
+	files := make([]*valueFile, 0, len(paths))
+	for _, filePath := range paths {
+		f, err := newValueFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// mergeValueFiles renders the files in passes until the merged values settle
+// and returns them with the layers of the last pass. The files merge over
+// shared, if any. The render errors are left in the layers' files.
+func mergeValueFiles(shared map[string]any, files []*valueFile, src ValueSources, policy *templatePolicy) (map[string]any, []valueLayer, error) {
+	var (
+		base     map[string]any
+		previous map[string]any
+		layers   []valueLayer
+	)
+	for pass := 1; ; pass++ {
+		base = copyValues(shared).(map[string]any)
+		layers = nil
+		for _, f := range files {
+			context := previous
+			if pass == 1 {
+				context = copyValues(base).(map[string]any)
+				if err := applySetValues(context, src); err != nil {
+					return nil, nil, err
+				}
+			}
+			fileLayers, err := f.layers(context, policy, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, layer := range fileLayers {
+				if layer.values == nil {
+					continue
+				}
+				// Merge with the previous map. It takes ownership of the
+				// maps merged in, so merge a copy and keep the original.
+				base = mergeValues(base, copyValues(layer.values).(map[string]any))
+			}
+			layers = append(layers, fileLayers...)
+		}
+		if err := applySetValues(base, src); err != nil {
+			return nil, nil, err
+		}
+		if len(files) == 0 || (pass > 1 && reflect.DeepEqual(base, previous)) {
+			return base, layers, nil
+		}
+		if pass == maxTemplatePasses {
+			return nil, nil, fmt.Errorf("value templates do not settle after %d passes, check for values referring to themselves: %s",
+				maxTemplatePasses, strings.Join(changedValuePaths("", previous, base), ", "))
+		}
+		previous = base
+	}
+}
+
+// findOverlays returns the overlay files in dir for environment, or else the
+// staging name of values, and the branch of values: <environment>.yml, then
+// <environment>-<branch>.yml, those that exist. The names are sanitized like
+// the release name, so branch feature/foo looks for prod-feature-foo.yml.
+func findOverlays(dir, environment string, values map[string]any) []string {
+	release := newTemplateContext(values, "").Release
+	if environment == "" {
+		environment = release.Staging
+	}
+	if environment == "" {
+		return nil
+	}
+	names := []string{sanitizeDNSName(environment) + ".yml"}
+	if release.Branch != "" {
+		names = append(names, sanitizeDNSName(environment)+"-"+sanitizeDNSName(release.Branch)+".yml")
+	}
+	var overlays []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			overlays = append(overlays, path)
+		}
+	}
+	return overlays
+}
+
+// applySetValues applies the environment variables and the --set family of
+// flags over the values of the files, in the order of ValueSources, and then
+// removes every unset value.
+func applySetValues(base map[string]any, src ValueSources) error {
+	// User specified a value via APP2KUBE_SET_<PATH>
+	for _, value := range envValues(src.Environ) {
+		if err := value.parseInto(base); err != nil {
+			return fmt.Errorf("failed parsing %s: %w", value.name, err)
+		}
+	}
+
+	// User specified a value via --set-json
+	for _, value := range src.JSONValues {
+		if err := strvals.ParseJSON(value, base); err != nil {
+			return fmt.Errorf("failed parsing --set-json data: %w", err)
 		}
-		// Merge with the previous map
-		base = mergeValues(base, currentMap)
 	}
 
 	// User specified a value via --set
-	for _, value := range values {
+	for _, value := range src.Values {
 		if err := strvals.ParseInto(value, base); err != nil {
-			return []byte{}, fmt.Errorf("failed parsing --set data: %w", err)
+			return fmt.Errorf("failed parsing --set data: %w", err)
 		}
 	}
 
 	// User specified a value via --set-string
-	for _, value := range stringValues {
+	for _, value := range src.StringValues {
 		if err := strvals.ParseIntoString(value, base); err != nil {
-			return []byte{}, fmt.Errorf("failed parsing --set-string data: %w", err)
+			return fmt.Errorf("failed parsing --set-string data: %w", err)
 		}
 	}
 
 	// User specified a value via --set-file
-	for _, value := range fileValues {
+	for _, value := range src.FileValues {
 		reader := func(rs []rune) (any, error) {
 			b, err := os.ReadFile(string(rs))
 			if err != nil {
@@ -134,11 +797,124 @@ func vals(valueFiles ValueFiles, values, stringValues, fileValues []string) ([]b
 			return strings.TrimSpace(string(b)), nil
 		}
 		if err := strvals.ParseIntoFile(value, base, reader); err != nil {
-			return []byte{}, fmt.Errorf("failed parsing --set-file data: %w", err)
+			return fmt.Errorf("failed parsing --set-file data: %w", err)
+		}
+	}
+
+	// User specified a value via --set-literal
+	for _, value := range src.LiteralValues {
+		if err := strvals.ParseLiteralInto(value, base); err != nil {
+			return fmt.Errorf("failed parsing --set-literal data: %w", err)
+		}
+	}
+
+	// User specified a path via --unset
+	for _, path := range src.Unset {
+		unsetValue(base, path)
+	}
+	pruneUnset(base)
+	return nil
+}
+
+// unsetValue replaces the value at path, if any, with the unset sentinel for
+// pruneUnset to remove.
+func unsetValue(values map[string]any, path string) {
+	var current any = values
+	for path != "" {
+		switch val := current.(type) {
+		case map[string]any:
+			key, rest, ok := nextValueKey(val, path)
+			if !ok {
+				return
+			}
+			if rest == "" {
+				val[key] = UnsetValue
+				return
+			}
+			current, path = val[key], rest
+		case []any:
+			i, rest, ok := nextValueIndex(val, path)
+			if !ok {
+				return
+			}
+			if rest == "" {
+				val[i] = UnsetValue
+				return
+			}
+			current, path = val[i], rest
+		default:
+			return
+		}
+	}
+}
+
+// pruneUnset removes the keys and list entries set to the unset sentinel that
+// no merge consumed, such as those of a subtree new to the merge or set by a
+// flag, and the list entries set to ReplaceValue by a flag.
+func pruneUnset(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			if child == UnsetValue {
+				delete(val, k)
+				continue
+			}
+			val[k] = pruneUnset(child)
+		}
+	case []any:
+		kept := val[:0]
+		for _, child := range val {
+			if child != UnsetValue && child != ReplaceValue {
+				kept = append(kept, pruneUnset(child))
+			}
 		}
+		return kept
 	}
+	return v
+}
+
+// copyValues returns a deep copy of parsed values.
+func copyValues(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, child := range val {
+			out[k] = copyValues(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, child := range val {
+			out[i] = copyValues(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
 
-	return yaml.Marshal(base)
+// changedValuePaths returns the paths whose values differ between a and b.
+func changedValuePaths(path string, a, b any) []string {
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+	if !aok || !bok {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{path}
+	}
+	keys := map[string]bool{}
+	for k := range am {
+		keys[k] = true
+	}
+	for k := range bm {
+		keys[k] = true
+	}
+	var changed []string
+	for _, k := range sortedKeys(keys) {
+		changed = append(changed, changedValuePaths(joinValuePath(path, k), am[k], bm[k])...)
+	}
+	return changed
 }
 
 // readFile loads a file from the local filesystem. A trailing '?' marks the
@@ -163,24 +939,30 @@ func readFile(filePath string) ([]byte, error) {
 	return bytes, nil
 }
 
-// templating renders a value file through text/template with the full sprig
-// FuncMap before YAML parsing.
+// templating renders a value file through text/template with funcs (the full
+// sprig FuncMap) and the template context before YAML parsing. The template is
+// named after the file so errors point at it. A non-nil policy rejects the
+// template when it uses anything the policy refuses.
 //
 // Trust boundary: this is an intentional feature (VALUES.md documents
 // {{ env "VAR" }}), so value files are treated as TRUSTED input — same trust
 // level as the argv that names them. The full sprig map includes env/expandenv
-// (host environment access) and getHostByName (DNS lookup), so a value file from
-// an untrusted source could exfiltrate host secrets or probe via DNS. Do not feed
-// attacker-influenced value files to app2kube; if that is ever required, strip
-// env/expandenv/getHostByName from the FuncMap here.
-func templating(raw []byte) ([]byte, error) {
-	tmpl, err := template.New("values").Funcs(sprig.FuncMap()).Parse(string(raw))
+// (host environment access) and getHostByName (DNS lookup), and .Files reads
+// any local file, so a value file from an untrusted source could exfiltrate
+// host secrets or probe via DNS. Untrusted value files (e.g. from fork pull
+// requests in CI) must be rendered with a safe template policy
+// (SetSafeTemplates), which refuses those functions and .Files.
+func templating(name string, raw []byte, data *templateContext, funcs template.FuncMap, policy *templatePolicy) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(string(raw))
 	if err != nil {
 		return nil, err
 	}
+	if err := policy.check(tmpl); err != nil {
+		return nil, err
+	}
 
 	var b bytes.Buffer
-	err = tmpl.Execute(&b, nil)
+	err = tmpl.Execute(&b, data)
 	if err != nil {
 		return nil, err
 	}
@@ -5,7 +5,11 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/Masterminds/sprig"
 )
 
 func TestValueFilesFlag(t *testing.T) {
@@ -89,6 +93,193 @@ func TestMergeValuesNilSetsNewKey(t *testing.T) {
 	}
 }
 
+func TestMergeValuesUnset(t *testing.T) {
+	dest := map[string]any{
+		"cronjob": map[string]any{"cleanup": map[string]any{"schedule": "@daily"}},
+		"ingress": []any{map[string]any{"host": "example.com"}},
+	}
+	src := map[string]any{
+		"cronjob": map[string]any{"cleanup": UnsetValue},
+		"ingress": UnsetValue,
+		"name":    UnsetValue,
+	}
+	out := mergeValues(dest, src)
+	if _, ok := out["ingress"]; ok {
+		t.Errorf("ingress must be deleted: %v", out["ingress"])
+	}
+	if cronjob := out["cronjob"].(map[string]any); len(cronjob) != 0 {
+		t.Errorf("nested key must be deleted: %v", cronjob)
+	}
+	if _, ok := out["name"]; ok {
+		t.Errorf("a key absent from dest must stay absent: %v", out["name"])
+	}
+}
+
+func TestValsUnset(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	overlay := filepath.Join(dir, "prod.yaml")
+	if err := os.WriteFile(base, []byte(`
+name: example
+ingress:
+  - host: example.com
+    annotations:
+      example.com/debug: "true"
+      example.com/owner: web
+  - host: www.example.com
+deployment:
+  containers:
+    app:
+      env:
+        DEBUG: "true"
+        LOG_LEVEL: info
+cronjob:
+  cleanup:
+    schedule: "@daily"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overlay, []byte(`
+cronjob:
+  cleanup: ~unset
+  report:
+    schedule: "@weekly"
+    suspend: ~unset
+deployment:
+  containers:
+    app:
+      env:
+        DEBUG: ~unset
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := vals(ValueSources{
+		Files:  ValueFiles{base, overlay},
+		Values: []string{"deployment.containers.app.env.LOG_LEVEL=" + UnsetValue},
+		Unset:  []string{"ingress[0].annotations.example.com/debug", "ingress[1]", "missing.key", "ingress[5]"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	want := map[string]any{
+		"name": "example",
+		"ingress": []any{
+			map[string]any{"host": "example.com", "annotations": map[string]any{"example.com/owner": "web"}},
+		},
+		"deployment": map[string]any{
+			"containers": map[string]any{"app": map[string]any{"env": map[string]any{}}},
+		},
+		"cronjob": map[string]any{"report": map[string]any{"schedule": "@weekly"}},
+	}
+	if !reflect.DeepEqual(loaded.values, want) {
+		t.Errorf("merged values:\ngot:  %v\nwant: %v", loaded.values, want)
+	}
+}
+
+func TestValsKeyedLists(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	overlay := filepath.Join(dir, "prod.yaml")
+	if err := os.WriteFile(base, []byte(`
+ingress:
+  - host: example.com
+    annotations:
+      example.com/owner: web
+  - host: www.example.com
+deployment:
+  containers:
+    app:
+      env:
+        - name: LOG_LEVEL
+          value: debug
+        - name: REGION
+          value: eu
+      ports:
+        - name: http
+          containerPort: 8080
+        - name: metrics
+          containerPort: 9090
+    sidecar:
+      ports:
+        - containerPort: 8081
+networkPolicy:
+  allowFromApps: [billing, reports]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overlay, []byte(`
+ingress:
+  - host: www.example.com
+    annotations:
+      example.com/canary: "false"
+  - host: api.example.com
+deployment:
+  containers:
+    app:
+      env:
+        - name: LOG_LEVEL
+          value: info
+      ports:
+        - ~replace
+        - name: http
+          containerPort: 80
+    sidecar:
+      ports:
+        - containerPort: 8082
+networkPolicy:
+  allowFromApps: [billing]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := vals(ValueSources{
+		Files:  ValueFiles{base, overlay},
+		Values: []string{`ingress[0].annotations.example\.com/owner=api`},
+	}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	want := map[string]any{
+		"ingress": []any{
+			map[string]any{"host": "example.com", "annotations": map[string]any{"example.com/owner": "api"}},
+			map[string]any{"host": "www.example.com", "annotations": map[string]any{"example.com/canary": "false"}},
+			map[string]any{"host": "api.example.com"},
+		},
+		"deployment": map[string]any{"containers": map[string]any{
+			"app": map[string]any{
+				"env": []any{
+					map[string]any{"name": "LOG_LEVEL", "value": "info"},
+					map[string]any{"name": "REGION", "value": "eu"},
+				},
+				// ~replace forces the overlay's list.
+				"ports": []any{map[string]any{"name": "http", "containerPort": float64(80)}},
+			},
+			// Unnamed ports cannot be merged by key and are replaced.
+			"sidecar": map[string]any{"ports": []any{map[string]any{"containerPort": float64(8082)}}},
+		}},
+		// Lists without a merge key are replaced.
+		"networkPolicy": map[string]any{"allowFromApps": []any{"billing"}},
+	}
+	if !reflect.DeepEqual(loaded.values, want) {
+		t.Errorf("merged values:\ngot:  %v\nwant: %v", loaded.values, want)
+	}
+
+	// Origins follow the merged positions.
+	for path, source := range map[string]string{
+		"ingress[1].annotations.example.com/canary": overlay + ":5",
+		"ingress[2].host":                                  overlay + ":6",
+		"ingress[0].annotations.example.com/owner":         `--set ingress[0].annotations.example\.com/owner=api`,
+		"deployment.containers.app.env[0].value":           overlay + ":12",
+		"deployment.containers.app.env[1].value":           base + ":14",
+		"deployment.containers.app.ports[0].containerPort": overlay + ":16",
+	} {
+		if got := loaded.origins.lookup(path); got != source {
+			t.Errorf("origin of %s: got %q, want %q", path, got, source)
+		}
+	}
+}
+
 func TestValsTemplating(t *testing.T) {
 	dir := t.TempDir()
 	f := filepath.Join(dir, "v.yaml")
@@ -97,12 +288,12 @@ func TestValsTemplating(t *testing.T) {
 	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
 		t.Fatal(err)
 	}
-	out, err := vals(ValueFiles{f}, nil, nil, nil)
+	loaded, err := vals(ValueSources{Files: ValueFiles{f}}, nil)
 	if err != nil {
 		t.Fatalf("vals: %v", err)
 	}
-	if string(out) != "name: MYAPP\n" {
-		t.Errorf("templating: got %q", string(out))
+	if string(loaded.raw) != "name: MYAPP\n" {
+		t.Errorf("templating: got %q", string(loaded.raw))
 	}
 }
 
@@ -112,12 +303,12 @@ func TestValsSetOverridesFile(t *testing.T) {
 	if err := os.WriteFile(f, []byte("name: fromfile\n"), 0644); err != nil {
 		t.Fatal(err)
 	}
-	out, err := vals(ValueFiles{f}, []string{"name=fromset"}, nil, nil)
+	loaded, err := vals(ValueSources{Files: ValueFiles{f}, Values: []string{"name=fromset"}}, nil)
 	if err != nil {
 		t.Fatalf("vals: %v", err)
 	}
-	if string(out) != "name: fromset\n" {
-		t.Errorf("--set must override file: got %q", string(out))
+	if string(loaded.raw) != "name: fromset\n" {
+		t.Errorf("--set must override file: got %q", string(loaded.raw))
 	}
 }
 
@@ -127,13 +318,13 @@ func TestValsSetFile(t *testing.T) {
 	if err := os.WriteFile(valFile, []byte("  topsecret  \n"), 0644); err != nil {
 		t.Fatal(err)
 	}
-	out, err := vals(nil, nil, nil, []string{"password=" + valFile})
+	loaded, err := vals(ValueSources{FileValues: []string{"password=" + valFile}}, nil)
 	if err != nil {
 		t.Fatalf("vals: %v", err)
 	}
 	// --set-file trims surrounding whitespace.
-	if string(out) != "password: topsecret\n" {
-		t.Errorf("set-file: got %q", string(out))
+	if string(loaded.raw) != "password: topsecret\n" {
+		t.Errorf("set-file: got %q", string(loaded.raw))
 	}
 }
 
@@ -142,24 +333,235 @@ func TestValsSetFile(t *testing.T) {
 // failed read can never surface as a silently-empty key.
 func TestValsSetFileMissingError(t *testing.T) {
 	missing := filepath.Join(t.TempDir(), "nope.txt")
-	out, err := vals(nil, nil, nil, []string{"password=" + missing})
+	loaded, err := vals(ValueSources{FileValues: []string{"password=" + missing}}, nil)
 	if err == nil {
 		t.Errorf("expected error for --set-file pointing at a missing file")
 	}
-	if len(out) != 0 {
-		t.Errorf("no value must be returned on a read error, got %q", string(out))
+	if loaded != nil {
+		t.Errorf("no value must be returned on a read error, got %q", string(loaded.raw))
 	}
 }
 
 // #36: --set-string keeps a numeric-looking value typed as a string (quoted),
 // unlike --set which would render it as a bare integer.
 func TestValsSetStringKeepsNumericAsString(t *testing.T) {
-	out, err := vals(nil, nil, []string{"port=8080"}, nil)
+	loaded, err := vals(ValueSources{StringValues: []string{"port=8080"}}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if string(loaded.raw) != "port: \"8080\"\n" {
+		t.Errorf("--set-string must keep a numeric value as a string, got %q", string(loaded.raw))
+	}
+}
+
+func TestValsSetJSONAndLiteral(t *testing.T) {
+	loaded, err := vals(ValueSources{
+		JSONValues:    []string{`common.tolerations=[{"key":"dedicated","operator":"Exists"}]`},
+		LiteralValues: []string{"secrets.DSN=postgres://u:p@db/app?opts=a,b[c]"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	want := map[string]any{
+		"common":  map[string]any{"tolerations": []any{map[string]any{"key": "dedicated", "operator": "Exists"}}},
+		"secrets": map[string]any{"DSN": "postgres://u:p@db/app?opts=a,b[c]"},
+	}
+	if !reflect.DeepEqual(loaded.values, want) {
+		t.Errorf("merged values:\ngot:  %v\nwant: %v", loaded.values, want)
+	}
+	if got := loaded.origins.lookup("secrets.DSN"); got != "--set-literal secrets.DSN=postgres://u:p@db/app?opts=a,b[c]" {
+		t.Errorf("origin of secrets.DSN: got %q", got)
+	}
+}
+
+func TestValsEnvValues(t *testing.T) {
+	environ := []string{
+		"HOME=/root",
+		"APP2KUBE_SET_DEPLOYMENT__REPLICACOUNT=3",
+		"APP2KUBE_SET_deployment__containers__app__image=example/app:v2",
+		"APP2KUBE_SET_CONFIGMAP__DB_HOST=db.example.com,db2.example.com",
+		// A value the schema types as a string stays one, as written.
+		"APP2KUBE_SET_CONFIGMAP__DB_PORT=05432",
+		"APP2KUBE_SET_NAME=fromenv",
+	}
+	loaded, err := vals(ValueSources{Environ: environ, Values: []string{"name=fromset"}}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	want := map[string]any{
+		"configmap": map[string]any{"DB_HOST": "db.example.com,db2.example.com", "DB_PORT": "05432"},
+		"deployment": map[string]any{
+			"replicaCount": int64(3),
+			"containers":   map[string]any{"app": map[string]any{"image": "example/app:v2"}},
+		},
+		// --set wins over the environment.
+		"name": "fromset",
+	}
+	if !reflect.DeepEqual(loaded.values, want) {
+		t.Errorf("merged values:\ngot:  %v\nwant: %v", loaded.values, want)
+	}
+	if got := loaded.origins.explain("deployment.replicaCount"); got != "env APP2KUBE_SET_DEPLOYMENT__REPLICACOUNT" {
+		t.Errorf("origin of deployment.replicaCount: got %q", got)
+	}
+	if got := loaded.origins.explain("configmap.DB_PORT"); got != "env APP2KUBE_SET_CONFIGMAP__DB_PORT" {
+		t.Errorf("origin of configmap.DB_PORT: got %q", got)
+	}
+	if !(ValueSources{Environ: environ[:1]}).Empty() || (ValueSources{Environ: environ[1:2]}).Empty() {
+		t.Errorf("Empty must count the value environment variables only")
+	}
+}
+
+func TestValsExtendsInclude(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"base/app2kube.yml": `common:
+  ingress:
+    class: nginx
+  tolerations:
+    - key: dedicated
+      operator: Exists
+deployment:
+  replicaCount: 2
+`,
+		"base/resources.yml": `deployment:
+  replicaCount: 3
+  containers:
+    app:
+      resources:
+        limits:
+          cpu: 500m
+`,
+		"shop/snippets/probes.yml": `deployment:
+  containers:
+    app:
+      readinessProbe:
+        httpGet:
+          path: /healthz
+`,
+		"shop/app2kube.yml": `extends: ../base/app2kube.yml
+include:
+  - ../base/resources.yml
+  - snippets/probes.yml
+  - snippets/missing.yml?
+name: shop
+deployment:
+  containers:
+    app:
+      image: example/shop
+`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shop := filepath.Join(dir, "shop", "app2kube.yml")
+	loaded, err := vals(ValueSources{Files: ValueFiles{shop}}, nil)
+	if err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	want := map[string]any{
+		"name": "shop",
+		"common": map[string]any{
+			"ingress":     map[string]any{"class": "nginx"},
+			"tolerations": []any{map[string]any{"key": "dedicated", "operator": "Exists"}},
+		},
+		"deployment": map[string]any{
+			"replicaCount": float64(3),
+			"containers": map[string]any{"app": map[string]any{
+				"image":          "example/shop",
+				"resources":      map[string]any{"limits": map[string]any{"cpu": "500m"}},
+				"readinessProbe": map[string]any{"httpGet": map[string]any{"path": "/healthz"}},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(loaded.values, want) {
+		t.Errorf("merged values:\ngot:  %v\nwant: %v", loaded.values, want)
+	}
+	if got, want := loaded.origins.explain("deployment.replicaCount"), filepath.Join(dir, "base", "resources.yml")+":2 (overrides "+filepath.Join(dir, "base", "app2kube.yml")+":8 = 2)"; got != want {
+		t.Errorf("origin of deployment.replicaCount:\ngot:  %s\nwant: %s", got, want)
+	}
+
+	// A file extending itself, directly or not, is an error.
+	loop := writeValueFiles(t,
+		[2]string{"a.yml", "extends: b.yml\n"},
+		[2]string{"b.yml", "include: [a.yml]\n"},
+	)
+	_, err = vals(ValueSources{Files: loop[:1]}, nil)
+	if err == nil || !strings.Contains(err.Error(), "value files include each other: "+loop[0]+" -> "+loop[1]+" -> "+loop[0]) {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+
+	// A missing file without "?" is an error naming the including file.
+	missing := writeValueFiles(t, [2]string{"c.yml", "extends: nowhere.yml\n"})
+	if _, err := vals(ValueSources{Files: missing}, nil); err == nil || !strings.HasPrefix(err.Error(), missing[0]+": ") {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestValsOverlays(t *testing.T) {
+	dir := t.TempDir()
+	overlayDir := filepath.Join(dir, ".app2kube")
+	if err := os.Mkdir(overlayDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"base.yml":                      "name: shop\nstaging: qa\nbranch: feature/cart\ndeployment:\n  replicaCountStaging: 1\n",
+		"extra.yml":                     "configmap:\n  SOURCE: extra\n",
+		".app2kube/qa.yml":              "deployment:\n  replicaCountStaging: 2\nconfigmap:\n  SOURCE: qa\n  ENV: qa\n",
+		".app2kube/qa-feature-cart.yml": "deployment:\n  replicaCountStaging: 3\n",
+		".app2kube/prod.yml":            "configmap:\n  ENV: prod\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := ValueSources{
+		BaseFiles:  ValueFiles{filepath.Join(dir, "base.yml")},
+		OverlayDir: overlayDir,
+		Files:      ValueFiles{filepath.Join(dir, "extra.yml")},
+	}
+
+	// The staging name and branch select the overlays, merged between the
+	// base files and the other files.
+	loaded, err := vals(src, nil)
 	if err != nil {
 		t.Fatalf("vals: %v", err)
 	}
-	if string(out) != "port: \"8080\"\n" {
-		t.Errorf("--set-string must keep a numeric value as a string, got %q", string(out))
+	wantOverlays := []string{filepath.Join(overlayDir, "qa.yml"), filepath.Join(overlayDir, "qa-feature-cart.yml")}
+	if !reflect.DeepEqual(loaded.overlays, wantOverlays) {
+		t.Errorf("overlays: got %v, want %v", loaded.overlays, wantOverlays)
+	}
+	want := map[string]any{"SOURCE": "extra", "ENV": "qa"}
+	if got := loaded.values["configmap"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("configmap: got %v, want %v", got, want)
+	}
+	if got := loaded.values["deployment"].(map[string]any)["replicaCountStaging"]; got != float64(3) {
+		t.Errorf("replicaCountStaging: got %v", got)
+	}
+
+	// An explicit environment wins over the staging name.
+	src.Environment = "prod"
+	if loaded, err = vals(src, nil); err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if want := []string{filepath.Join(overlayDir, "prod.yml")}; !reflect.DeepEqual(loaded.overlays, want) {
+		t.Errorf("overlays: got %v, want %v", loaded.overlays, want)
+	}
+
+	// Anonymous staging has no environment.
+	src.Environment = ""
+	src.Values = []string{"staging=true"}
+	if loaded, err = vals(src, nil); err != nil {
+		t.Fatalf("vals: %v", err)
+	}
+	if len(loaded.overlays) != 0 {
+		t.Errorf("anonymous staging must load no overlay, got %v", loaded.overlays)
 	}
 }
 
@@ -206,7 +608,7 @@ func TestReadFileMissingLocalAllowed(t *testing.T) {
 }
 
 func TestTemplatingInvalid(t *testing.T) {
-	if _, err := templating([]byte("{{ .Broken ")); err == nil {
+	if _, err := templating("values", []byte("{{ .Broken "), nil, sprig.TxtFuncMap(), nil); err == nil {
 		t.Errorf("expected parse error for malformed template")
 	}
 }
@@ -216,7 +618,7 @@ func TestTemplatingInvalid(t *testing.T) {
 // turning special characters in template output into entities
 // (e.g. & -> &amp;, < -> &lt;), which is invalid for config values.
 func TestTemplatingNoHTMLEscape(t *testing.T) {
-	out, err := templating([]byte(`value: {{ "a&b<c>d" }}`))
+	out, err := templating("values", []byte(`value: {{ "a&b<c>d" }}`), nil, sprig.TxtFuncMap(), nil)
 	if err != nil {
 		t.Fatalf("templating: %v", err)
 	}
@@ -0,0 +1,127 @@
+package app2kube
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+)
+
+// validateWorkloads rejects named workloads the generators cannot render.
+func (app *App) validateWorkloads() error {
+	for _, name := range sortedKeys(app.Workloads) {
+		w := app.Workloads[name]
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("invalid workload name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if len(w.Containers) == 0 {
+			return fmt.Errorf("workloads.%s.containers is required", name)
+		}
+		if w.Kind != "" && !strings.EqualFold(w.Kind, KindDeployment) {
+			return fmt.Errorf("workloads.%s.kind must be %s, got %q", name, KindDeployment, w.Kind)
+		}
+		if as := w.Autoscaling; as.Enabled {
+			if as.MaxReplicas < 1 {
+				return fmt.Errorf("workloads.%s.autoscaling.maxReplicas must be at least 1", name)
+			}
+			if as.MinReplicas != nil && (*as.MinReplicas < 1 || *as.MinReplicas > as.MaxReplicas) {
+				return fmt.Errorf("workloads.%s.autoscaling.minReplicas must be between 1 and maxReplicas (%d)", name, as.MaxReplicas)
+			}
+		}
+	}
+	return nil
+}
+
+// WorkloadApps returns the app's named workloads, in sorted name order, each as
+// a copy of the app rendering that workload in place of deployment: the
+// Deployment "<release>-<workload>" with its own PDB, HPA, NetworkPolicy and
+// Services, sharing the app's ConfigMap, Secret, env, volumes and
+// ServiceAccount. Their objects carry the app labels plus the component label,
+// so prune and `delete all` cover them; their pods carry the instance label
+// "<instance>-<workload>" instead, so the app's own Services and the other
+// workloads never select them. A workload has no workloads of its own.
+func (app *App) WorkloadApps() []*App {
+	if app.workload != "" || len(app.Workloads) == 0 {
+		return nil
+	}
+	apps := make([]*App, 0, len(app.Workloads))
+	for _, name := range sortedKeys(app.Workloads) {
+		apps = append(apps, app.workloadApp(name))
+	}
+	return apps
+}
+
+// workloadApp returns the copy of the app rendering the named workload.
+func (app *App) workloadApp(name string) *App {
+	w := app.Workloads[name]
+	wa := *app
+	wa.workload = name
+
+	wa.Labels = make(map[string]string, len(app.Labels)+1)
+	for k, v := range app.Labels {
+		wa.Labels[k] = v
+	}
+	wa.Labels[LabelComponent] = name
+
+	// Workloads are not colored: a blue/green deploy rolls them in place
+	// with the first phase, like the ConfigMap they consume.
+	wa.Deployment = w.DeploymentSpec
+	wa.Deployment.BlueGreenColor = ""
+	if app.Staging.Active {
+		wa.Deployment.RevisionHistoryLimit = 0
+		wa.Deployment.Autoscaling.Enabled = false
+		wa.Deployment.ReplicaCount = ptr.To(int32(1))
+		if w.ReplicaCountStaging > 0 {
+			wa.Deployment.ReplicaCount = ptr.To(w.ReplicaCountStaging)
+		}
+	}
+
+	if w.NodeSelector != nil {
+		wa.Common.NodeSelector = w.NodeSelector
+	}
+	if w.Resources != nil {
+		wa.Common.Resources = w.Resources
+	}
+	if w.Tolerations != nil {
+		wa.Common.Tolerations = w.Tolerations
+	}
+	wa.Service = w.Service
+	return &wa
+}
+
+// workloadInstance returns the instance label of the pods of the named
+// workload.
+func (app *App) workloadInstance(name string) string {
+	return truncateName(app.Labels[LabelInstance] + "-" + name)
+}
+
+// sameAppInstances returns the instance label values of every pod of the
+// app: its own and those of its named workloads.
+func (app *App) sameAppInstances() []string {
+	instances := []string{app.Labels[LabelInstance]}
+	for _, name := range sortedKeys(app.Workloads) {
+		instances = append(instances, app.workloadInstance(name))
+	}
+	return instances
+}
+
+// perWorkload wraps the render function of a per-workload generator so it
+// renders the app's own workload and then every named workload.
+func perWorkload(render func(app *App) ([]runtime.Object, error)) func(app *App) ([]runtime.Object, error) {
+	return func(app *App) ([]runtime.Object, error) {
+		objs, err := render(app)
+		if err != nil {
+			return nil, err
+		}
+		for _, wa := range app.WorkloadApps() {
+			wobjs, err := render(wa)
+			if err != nil {
+				return nil, fmt.Errorf("workload %s: %w", wa.workload, err)
+			}
+			objs = append(objs, wobjs...)
+		}
+		return objs, nil
+	}
+}
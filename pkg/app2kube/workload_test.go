@@ -0,0 +1,179 @@
+package app2kube
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const workloadValues = `
+name: example
+configmap:
+  KEY: value
+common:
+  nodeSelector:
+    pool: web
+deployment:
+  containers:
+    web:
+      image: example/app:v1
+service:
+  http:
+    port: 8080
+workloads:
+  worker:
+    replicaCount: 2
+    nodeSelector:
+      pool: batch
+    containers:
+      worker:
+        image: example/app:v1
+        command: [worker]
+    service:
+      metrics:
+        port: 9090
+`
+
+// A named workload renders as "<release>-<workload>" next to the main
+// Deployment, sharing the app's config, with its own PDB and Service selecting
+// only its own pods.
+func TestWorkloadApps(t *testing.T) {
+	app := mustUnmarshalApp(t, workloadValues)
+	if err := app.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	app.Labels[LabelName] = "example"
+
+	workloads := app.WorkloadApps()
+	if len(workloads) != 1 {
+		t.Fatalf("expected one workload, got %d", len(workloads))
+	}
+	worker := workloads[0]
+	if worker.GetDeploymentName() != "example-worker" {
+		t.Errorf("workload deployment name: got %q", worker.GetDeploymentName())
+	}
+	if worker.WorkloadApps() != nil {
+		t.Errorf("a workload must not render workloads of its own")
+	}
+
+	objs, err := perWorkload(func(app *App) ([]runtime.Object, error) {
+		d, err := app.GetDeployment()
+		return []runtime.Object{d}, err
+	})(app)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expected the main and the worker Deployment, got %d", len(objs))
+	}
+	web, wd := objs[0].(*appsv1.Deployment), objs[1].(*appsv1.Deployment)
+	if wd.Name != "example-worker" || *wd.Spec.Replicas != 2 {
+		t.Errorf("worker deployment: got %s with %d replicas", wd.Name, *wd.Spec.Replicas)
+	}
+	if wd.Labels[LabelComponent] != "worker" || wd.Labels[LabelInstance] != "production" {
+		t.Errorf("worker object labels must extend the app labels: %v", wd.Labels)
+	}
+	if got := wd.Spec.Template.Labels[LabelInstance]; got != "production-worker" {
+		t.Errorf("worker pods must carry their own instance, got %q", got)
+	}
+	if got := wd.Spec.Template.Spec.NodeSelector["pool"]; got != "batch" {
+		t.Errorf("workload nodeSelector must override common, got %q", got)
+	}
+	if got := web.Spec.Template.Spec.NodeSelector["pool"]; got != "web" {
+		t.Errorf("main nodeSelector must stay common, got %q", got)
+	}
+	envFrom := wd.Spec.Template.Spec.Containers[0].EnvFrom
+	if len(envFrom) == 0 || envFrom[0].ConfigMapRef == nil || envFrom[0].ConfigMapRef.Name != "example" {
+		t.Errorf("worker must consume the shared ConfigMap: %+v", envFrom)
+	}
+
+	services, err := app.GetServices()
+	if err != nil {
+		t.Fatalf("GetServices: %v", err)
+	}
+	if got := services[0].Spec.Selector[LabelInstance]; got != "production" {
+		t.Errorf("the main Service must not select worker pods, instance %q", got)
+	}
+	wservices, err := worker.GetServices()
+	if err != nil {
+		t.Fatalf("worker GetServices: %v", err)
+	}
+	if len(wservices) != 1 || wservices[0].Name != "example-worker-metrics" || wservices[0].Spec.Selector[LabelInstance] != "production-worker" {
+		t.Errorf("worker Service: got %+v", wservices)
+	}
+
+	pdb, err := worker.GetPodDisruptionBudget()
+	if err != nil || pdb == nil {
+		t.Fatalf("a 2-replica workload must get a PDB: %v", err)
+	}
+	if pdb.Name != "example-worker" || pdb.Spec.Selector.MatchLabels[LabelInstance] != "production-worker" {
+		t.Errorf("worker PDB: got %s selecting %v", pdb.Name, pdb.Spec.Selector.MatchLabels)
+	}
+}
+
+func TestWorkloadsInManifest(t *testing.T) {
+	app := mustUnmarshalApp(t, workloadValues)
+	m, err := app.GetManifest("yaml", OutputAll)
+	if err != nil {
+		t.Fatalf("GetManifest: %v", err)
+	}
+	for _, want := range []string{
+		"# Deployment: example\n",
+		"# Deployment: example-worker",
+		"# PodDisruptionBudget: example-worker",
+		"# Service: example-http",
+		"# Service: example-worker-metrics",
+		"app.kubernetes.io/component: worker",
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("manifest missing %q:\n%s", want, m)
+		}
+	}
+	if strings.Count(m, "# ConfigMap:") != 1 {
+		t.Errorf("the workloads must share the app's single ConfigMap:\n%s", m)
+	}
+}
+
+func TestValidateWorkloads(t *testing.T) {
+	container := map[string]apiv1.Container{"app": {Image: "example/app:v1"}}
+	cases := map[string]struct {
+		spec  WorkloadSpec
+		name  string
+		valid bool
+	}{
+		"valid":         {WorkloadSpec{DeploymentSpec: DeploymentSpec{Containers: container}}, "worker", true},
+		"no containers": {WorkloadSpec{}, "worker", false},
+		"bad name":      {WorkloadSpec{DeploymentSpec: DeploymentSpec{Containers: container}}, "Bad_Name", false},
+		"statefulset":   {WorkloadSpec{DeploymentSpec: DeploymentSpec{Containers: container, Kind: KindStatefulSet}}, "worker", false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			app := deployApp(t)
+			app.Workloads = map[string]WorkloadSpec{tc.name: tc.spec}
+			if err := app.validateWorkloads(); (err == nil) != tc.valid {
+				t.Errorf("validateWorkloads() = %v, want valid=%v", err, tc.valid)
+			}
+		})
+	}
+}
+
+// allowSameApp admits the pods of the named workloads too, which carry their
+// own instance label.
+func TestNetworkPolicyAllowSameAppWorkloads(t *testing.T) {
+	app := mustUnmarshalApp(t, workloadValues)
+	app.NetworkPolicy = NetworkPolicySpec{Enabled: true, AllowSameApp: true}
+	policy, err := app.GetNetworkPolicy()
+	if err != nil {
+		t.Fatalf("GetNetworkPolicy: %v", err)
+	}
+	selector := policy.Spec.Ingress[0].From[0].PodSelector
+	if _, ok := selector.MatchLabels[LabelInstance]; ok || len(selector.MatchExpressions) != 1 {
+		t.Fatalf("expected an instance In expression, got %+v", selector)
+	}
+	if got := selector.MatchExpressions[0].Values; len(got) != 2 || got[0] != "production" || got[1] != "production-worker" {
+		t.Errorf("instances: got %v", got)
+	}
+}
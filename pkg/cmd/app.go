@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
 	"github.com/spf13/cobra"
@@ -12,53 +14,175 @@ import (
 
 const defaultFile = ".app2kube.yml"
 
+// overlayDir holds the environment overlays, loaded after the default file:
+// <environment>.yml and <environment>-<branch>.yml.
+const overlayDir = ".app2kube"
+
 // appOptions holds the per-command inputs used to load an application's values.
 // Each command owns its own instance (created by addAppFlags), so commands no
 // longer share mutable package-level state.
 type appOptions struct {
+	// apps selects the applications of values declaring several (--app).
+	apps             []string
 	valueFiles       app2kube.ValueFiles
+	environment      string
+	jsonValues       []string
 	values           []string
 	stringValues     []string
 	fileValues       []string
+	literalValues    []string
+	unset            []string
 	verbose          bool
 	includeNamespace bool
+	// skipSchemaValidation loads legacy value files whose unknown keys the
+	// strict check against the values schema would reject.
+	skipSchemaValidation bool
+	// safeTemplates renders untrusted value files without access to the
+	// environment (beyond templateEnv), local files, DNS or randomness.
+	safeTemplates bool
+	templateEnv   []string
 	// blueGreen requests resolving the target blue/green color in initApp. It is
 	// per-command state (bound by addBlueGreenFlag, or set explicitly by the
 	// blue-green subcommands) rather than a package global, so commands no longer
 	// leak it into each other.
 	blueGreen bool
+	// postRenderer pipes the rendered manifests through an executable, with
+	// postRendererArgs (bound by addPostRendererFlags).
+	postRenderer     string
+	postRendererArgs []string
 }
 
+// initApp loads the application to act on. Values declaring several
+// applications need --app to select one.
 func (o *appOptions) initApp(ctx context.Context) (*app2kube.App, error) {
 	if flagAllApplications {
-		return &app2kube.App{
-			Name:      "all",
-			Namespace: *kubeConfigFlags.Namespace,
-		}, nil
+		return allApp(), nil
 	}
 
-	// The default file is always used as a base when present in the current
-	// directory; values from -f/--set extend and override it, so it must come
-	// first. A non-NotExist Stat error (e.g. permission denied) must not be
-	// treated as "present". A local copy avoids mutating the bound flag value.
-	valueFiles := o.valueFiles
-	if _, err := os.Stat(defaultFile); err == nil {
-		valueFiles = append(app2kube.ValueFiles{defaultFile}, valueFiles...)
+	name, err := o.appName()
+	if err != nil {
+		return nil, err
+	}
+	return o.loadApp(ctx, name)
+}
+
+// appName returns the application selected from values declaring several,
+// or "" for the values of a single application.
+func (o *appOptions) appName() (string, error) {
+	names, err := o.appNames()
+	if err != nil {
+		return "", err
+	}
+	if len(names) > 1 {
+		return "", fmt.Errorf("the values declare the applications %s: select one with --app", strings.Join(names, ", "))
 	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	return "", nil
+}
 
-	if len(valueFiles)+len(o.values)+len(o.stringValues)+len(o.fileValues) == 0 {
-		return nil, errors.New("values are required")
+// initApps loads the applications to act on: the application of the values,
+// or those of values declaring several, all of them unless --app selects
+// some.
+func (o *appOptions) initApps(ctx context.Context) ([]*app2kube.App, error) {
+	if flagAllApplications {
+		return []*app2kube.App{allApp()}, nil
 	}
 
-	app := app2kube.NewApp()
+	names, err := o.appNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		app, err := o.loadApp(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		return []*app2kube.App{app}, nil
+	}
+	apps := make([]*app2kube.App, 0, len(names))
+	for _, name := range names {
+		app, err := o.loadApp(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// allApp is the pseudo-application of --all, every app2kube application of
+// the namespace.
+func allApp() *app2kube.App {
+	return &app2kube.App{
+		Name:      "all",
+		Namespace: *kubeConfigFlags.Namespace,
+	}
+}
 
-	rawVals, err := app.LoadValues(valueFiles, o.values, o.stringValues, o.fileValues)
+// appNames returns the applications selected from values declaring several:
+// those named with --app, or else all of them. It returns nil for the values
+// of a single application.
+func (o *appOptions) appNames() ([]string, error) {
+	app, src, err := o.newApp()
+	if err != nil {
+		return nil, err
+	}
+	declared, err := app.AppNames(src)
 	if err != nil {
 		return nil, err
 	}
+	if len(declared) == 0 {
+		if len(o.apps) > 0 {
+			return nil, errors.New("--app requires values declaring apps")
+		}
+		return nil, nil
+	}
+	if len(o.apps) == 0 {
+		return declared, nil
+	}
+	for _, name := range o.apps {
+		if !slices.Contains(declared, name) {
+			return nil, fmt.Errorf("unknown --app %q (the values declare %s)", name, strings.Join(declared, ", "))
+		}
+	}
+	return o.apps, nil
+}
+
+// loadApp loads the values of the application name of values declaring
+// several, or of the only one when name is empty.
+func (o *appOptions) loadApp(ctx context.Context, name string) (*app2kube.App, error) {
+	app, _, err := o.loadAppValues(ctx, name)
+	return app, err
+}
+
+// loadAppValues is loadApp also returning the merged values.
+func (o *appOptions) loadAppValues(ctx context.Context, name string) (*app2kube.App, []byte, error) {
+	app, src, err := o.newApp()
+	if err != nil {
+		return nil, nil, err
+	}
+	src.App = name
 
+	rawVals, err := app.LoadValueSources(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An explicit --env without any overlay is most likely a typo.
+	if o.environment != "" && len(app.Overlays()) == 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: no overlay for environment %s in %s\n", o.environment, overlayDir)
+	}
 	if o.verbose {
-		fmt.Fprintf(os.Stderr, "---\n# merged values\n%s\n", rawVals)
+		fmt.Fprint(os.Stderr, "---\n")
+		if name != "" {
+			fmt.Fprintf(os.Stderr, "# app: %s\n", name)
+		}
+		if overlays := app.Overlays(); len(overlays) > 0 {
+			fmt.Fprintf(os.Stderr, "# overlays: %s\n", strings.Join(overlays, ", "))
+		}
+		fmt.Fprintf(os.Stderr, "# merged values\n%s\n", rawVals)
 	}
 
 	// Namespace precedence: flag > file > default. An explicitly-set --namespace
@@ -74,11 +198,51 @@ func (o *appOptions) initApp(ctx context.Context) (*app2kube.App, error) {
 	if o.blueGreen {
 		app.Deployment.BlueGreenColor, err = getTargetBlueGreenColor(ctx, app.Namespace, app.Labels)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	return app, nil
+	return app, rawVals, nil
+}
+
+// newApp returns the App to load the values into, configured from the flags,
+// and the values to load: the default file, when present, and the overlays of
+// the environment, then -f, the APP2KUBE_SET_ environment variables and the
+// --set family.
+func (o *appOptions) newApp() (*app2kube.App, app2kube.ValueSources, error) {
+	// The default file is always used as a base when present in the current
+	// directory; values from -f/--set extend and override it, so it must come
+	// first. A non-NotExist Stat error (e.g. permission denied) must not be
+	// treated as "present".
+	var baseFiles app2kube.ValueFiles
+	if _, err := os.Stat(defaultFile); err == nil {
+		baseFiles = app2kube.ValueFiles{defaultFile}
+	}
+
+	src := app2kube.ValueSources{
+		BaseFiles:     baseFiles,
+		OverlayDir:    overlayDir,
+		Environment:   o.environment,
+		Files:         o.valueFiles,
+		Environ:       os.Environ(),
+		JSONValues:    o.jsonValues,
+		Values:        o.values,
+		StringValues:  o.stringValues,
+		FileValues:    o.fileValues,
+		LiteralValues: o.literalValues,
+		Unset:         o.unset,
+	}
+	if src.Empty() {
+		return nil, app2kube.ValueSources{}, errors.New("values are required")
+	}
+	if len(o.templateEnv) > 0 && !o.safeTemplates {
+		return nil, app2kube.ValueSources{}, errors.New("--template-env requires --safe-templates")
+	}
+
+	app := app2kube.NewApp()
+	app.SetSchemaValidation(!o.skipSchemaValidation)
+	app.SetSafeTemplates(o.safeTemplates, o.templateEnv...)
+	return app, src, nil
 }
 
 // resolveNamespace applies the namespace precedence flag > file > default. An
@@ -97,10 +261,18 @@ func resolveNamespace(flagChanged bool, flagNs, fileNs string) string {
 
 func addAppFlags(cmd *cobra.Command) *appOptions {
 	o := &appOptions{}
+	cmd.Flags().StringArrayVar(&o.apps, "app", []string{}, "Act on this application of values declaring apps (can specify multiple; default: all of them)")
+	cmd.Flags().StringVar(&o.environment, "env", "", "Load the overlays of this environment from "+overlayDir+"/ (default: the staging name)")
 	cmd.Flags().BoolVarP(&o.includeNamespace, "include-namespace", "", false, "Include namespace manifest")
+	cmd.Flags().BoolVar(&o.safeTemplates, "safe-templates", false, "Render value files as untrusted: no environment, file, DNS or random template functions")
 	cmd.Flags().StringArrayVar(&o.values, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
 	cmd.Flags().StringArrayVar(&o.fileValues, "set-file", []string{}, "Set values from respective files specified via the command line (can specify multiple or separate values with commas: key1=path1,key2=path2)")
+	cmd.Flags().StringArrayVar(&o.jsonValues, "set-json", []string{}, "Set JSON values on the command line (can specify multiple or separate values with commas: key1=jsonval1,key2=jsonval2)")
+	cmd.Flags().StringArrayVar(&o.literalValues, "set-literal", []string{}, "Set a literal STRING value on the command line, commas and brackets included (can specify multiple)")
 	cmd.Flags().StringArrayVar(&o.stringValues, "set-string", []string{}, "Set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	cmd.Flags().BoolVar(&o.skipSchemaValidation, "skip-schema-validation", false, "Do not reject unknown or mistyped keys in the values (for legacy value files)")
+	cmd.Flags().StringArrayVar(&o.unset, "unset", []string{}, "Remove the value at a dotted path such as env.DEBUG or ingress[0] from the merged values (can specify multiple)")
+	cmd.Flags().StringArrayVar(&o.templateEnv, "template-env", []string{}, "Environment variable the env template function may read with --safe-templates (can specify multiple)")
 	cmd.Flags().VarP(&o.valueFiles, "values", "f", "Specify values in a YAML file (can specify multiple). Add the suffix '?' to the file name so that it can be skipped if it is not found")
 	cmd.Flags().BoolVarP(&o.verbose, "verbose", "v", false, "Show the parsed YAML values as well")
 	return o
@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
 	"github.com/spf13/cobra"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/kubectl/pkg/cmd/apply"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
@@ -48,6 +55,9 @@ var (
 	applyWithStatus bool
 	applyWithTrack  string
 	applyTimeout    = defaultTrackTimeout
+	applyCanary     bool
+	applyWeight     int32
+	applyParallel   = 4
 )
 
 // validateTrackValue checks the --track flag value. The valid set is known at
@@ -62,6 +72,239 @@ func validateTrackValue(v string) error {
 	}
 }
 
+// applier applies rendered app manifests with the kubectl apply flags bound to
+// one command. apply, canary promote and the canary rollout share it, so every
+// path applies, prunes and runs hooks the same way.
+type applier struct {
+	cmd   *cobra.Command
+	flags *apply.ApplyFlags
+	opts  *appOptions
+	app   *app2kube.App
+	args  []string
+	// pruneWhitelist is derived per-app from the generator registry
+	// (output.go) so it cannot drift: every kind app2kube can emit is
+	// prunable (e.g. the PodDisruptionBudget that disappears when replicas
+	// scale back to 1, whose stale minAvailable would otherwise block every
+	// node drain), and nothing it never emits is listed (a stale entry would
+	// let prune delete an unrelated object matching the selector). It is
+	// app-aware so the cert-manager Certificate is only pruned when this app
+	// actually uses letsencrypt.
+	pruneWhitelist []string
+	// dryRun is the --dry-run strategy. Hook Jobs are created directly rather
+	// than through kubectl apply, so a dry run cannot preview them and skips
+	// them instead.
+	dryRun cmdutil.DryRunStrategy
+	// selector, when set, scopes prune to the objects of several applications
+	// instead of those of app.
+	selector string
+	// renderer is the --post-renderer the manifests are piped through, if
+	// any.
+	renderer *app2kube.PostRenderer
+	// timeout is the --timeout of the command in minutes for tracking the
+	// workloads and hook Jobs; 0 waits forever.
+	timeout int
+}
+
+func newApplier(cmd *cobra.Command, flags *apply.ApplyFlags, opts *appOptions, app *app2kube.App, args []string) (*applier, error) {
+	dryRun, err := cmdutil.GetDryRunStrategy(cmd)
+	if err != nil {
+		return nil, err
+	}
+	renderer, err := opts.newPostRenderer()
+	if err != nil {
+		return nil, err
+	}
+	return &applier{
+		cmd:            cmd,
+		flags:          flags,
+		opts:           opts,
+		app:            app,
+		args:           args,
+		pruneWhitelist: app.PruneWhitelist(),
+		dryRun:         dryRun,
+		renderer:       renderer,
+		timeout:        applyTimeout,
+	}, nil
+}
+
+// apply applies a rendered manifest, pruning the app's objects missing from it
+// when prune is set.
+func (a *applier) apply(manifest string, prune bool) error {
+	// Several applications apply concurrently: each call sets up its own
+	// flags, and its own namespace over the one of the flags.
+	flags := a.applyFlags()
+	flags.Overwrite = true
+	flags.Prune = prune
+	flags.PruneWhitelist = a.pruneWhitelist
+	o, err := flags.ToOptions(kubeFactory, a.cmd, "app2kube", a.args)
+	if err != nil {
+		return err
+	}
+	o.DryRunStrategy = a.dryRun
+
+	if a.app.Namespace != "" {
+		o.Namespace = a.app.Namespace
+	}
+	if o.Namespace != "" {
+		o.EnforceNamespace = true
+	}
+
+	if o.Prune {
+		o.Selector = a.selector
+		if o.Selector == "" {
+			if o.Selector, err = scopedSelector(a.app.Labels); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	// Pre-build the objects from an in-memory reader and hand them to
+	// apply via SetObjects, so Run() uses them directly and never reads
+	// os.Stdin. This replaces the previous global os.Stdin hijack and its
+	// pipe-buffer deadlock window.
+	infos, err := streamApplyObjects(o.Builder, o.Validator, o.Namespace, o.EnforceNamespace, o.Selector, manifest)
+	// Return the error instead of cmdutil.CheckErr (which os.Exit()s): a
+	// parse error means app2kube emitted an invalid manifest (a bug), and
+	// blue/green callers must regain control to report that traffic was
+	// NOT switched before the process exits (#60).
+	if err != nil {
+		return err
+	}
+	// Mirror GetObjects(): apply ApplySet labels when one is configured.
+	// app2kube never sets --applyset today, so o.ApplySet is nil and this
+	// is a no-op, but it keeps parity with the builder path it replaces.
+	if o.ApplySet != nil {
+		if err := o.ApplySet.AddLabels(infos...); err != nil {
+			return err
+		}
+	}
+	o.SetObjects(infos)
+
+	return o.Run()
+}
+
+// applyFlags returns fresh kubectl apply flags with the settings of those
+// bound to the command. The printer of an apply writes the operation of each
+// object ("created", "configured") into its PrintFlags, so concurrent applies
+// must not share them.
+func (a *applier) applyFlags() *apply.ApplyFlags {
+	flags := apply.NewApplyFlags(a.flags.IOStreams)
+	flags.FieldManager = a.flags.FieldManager
+	flags.Selector = a.flags.Selector
+	flags.All = a.flags.All
+	flags.OpenAPIPatch = a.flags.OpenAPIPatch
+	flags.DeleteFlags.FileNameFlags.Filenames = a.flags.DeleteFlags.FileNameFlags.Filenames
+	*flags.PrintFlags.OutputFormat = *a.flags.PrintFlags.OutputFormat
+	flags.PrintFlags.JSONYamlPrintFlags.ShowManagedFields = a.flags.PrintFlags.JSONYamlPrintFlags.ShowManagedFields
+	// The template flags are only read.
+	flags.PrintFlags.TemplatePrinterFlags = a.flags.PrintFlags.TemplatePrinterFlags
+	// --record is not bound, so nothing is recorded, but completing the
+	// record flags parses the flags of the command again, which concurrent
+	// applies share.
+	flags.RecordFlags = nil
+	return flags
+}
+
+// manifest renders the given resource types of app (the applier's app or its
+// canary track) as JSON, prefixed with the Namespace when --include-namespace
+// asks for it, through the --post-renderer if any.
+func (a *applier) manifest(app *app2kube.App, output ...app2kube.OutputResource) (string, error) {
+	return renderManifest(app, a.renderer, "json", app.Namespace != app2kube.NamespaceDefault && a.opts.includeNamespace, output...)
+}
+
+// runHooks runs the app's hook Jobs of one apply phase.
+func (a *applier) runHooks(ctx context.Context, phase app2kube.HookPhase) error {
+	if !a.app.HasHooks(phase) {
+		return nil
+	}
+	if a.dryRun != cmdutil.DryRunNone {
+		fmt.Fprintf(os.Stderr, "• Skipping %s hooks (dry run)\n", phase)
+		return nil
+	}
+	if phase == app2kube.HookPreApply {
+		// Hook pods consume the app's ConfigMap/Secret (envFrom) and
+		// volumes. Apply those first so a first deploy's migration
+		// does not wait on config that does not exist yet, and every
+		// migration runs with the new values.
+		manifest, err := a.manifest(a.app, app2kube.OutputSecret, app2kube.OutputConfigMap, app2kube.OutputPersistentVolumeClaim)
+		if err != nil {
+			return err
+		}
+		if err := a.apply(manifest, false); err != nil {
+			return err
+		}
+	}
+	kcs, err := kubeFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	return runHooks(ctx, kcs, a.app, phase, a.renderer, trackHookJob(a.timeout))
+}
+
+// deploy applies the app the way apply does without --canary or --blue-green:
+// its pre-apply hooks, its manifest, pruning the objects missing from it when
+// prune is set, the tracking --track asks for and its post-apply hooks.
+func (a *applier) deploy(ctx context.Context, prune bool) error {
+	if err := a.runHooks(ctx, app2kube.HookPreApply); err != nil {
+		return err
+	}
+
+	manifest, err := a.manifest(a.app, app2kube.OutputAll)
+	if err != nil {
+		return err
+	}
+	if err := a.apply(manifest, prune); err != nil {
+		return err
+	}
+
+	// Prune a ClusterRole/ClusterRoleBinding that dropped out of the values by
+	// name; kubectl's prune never lists them.
+	if a.flags.Prune && !a.app.UsesClusterRBAC() && a.dryRun == cmdutil.DryRunNone {
+		kcs, err := kubeFactory.KubernetesClientSet()
+		if err != nil {
+			return err
+		}
+		if err := deleteClusterRBAC(ctx, kcs, a.app); err != nil {
+			return err
+		}
+	}
+
+	if err := a.track(ctx); err != nil {
+		return err
+	}
+	// Post-apply hooks run once the apply (and, with --track, the rollout) is
+	// done.
+	return a.runHooks(ctx, app2kube.HookPostApply)
+}
+
+// track follows the app's workloads as --track asks.
+func (a *applier) track(ctx context.Context) error {
+	workloads := appWorkloads(a.app)
+	if len(workloads) == 0 {
+		return nil
+	}
+	switch strings.ToLower(applyWithTrack) {
+	case "follow":
+		return trackFollow(ctx, workloads, a.app.Namespace, a.timeout, time.Now())
+	case "ready":
+		return trackReady(ctx, workloads, a.app.Namespace, a.timeout, time.Now())
+	}
+	return nil
+}
+
+// addApplyFlags binds the kubectl apply flags ApplyFlags.ToOptions reads.
+func addApplyFlags(cmd *cobra.Command, flags *apply.ApplyFlags) {
+	flags.PrintFlags.AddFlags(cmd)
+	cmdutil.AddDryRunFlag(cmd)
+	cmdutil.AddServerSideApplyFlags(cmd)
+	cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddFieldManagerFlagVar(cmd, &flags.FieldManager, apply.FieldManagerClientSideApply)
+}
+
 // NewCmdApply return apply command
 func NewCmdApply() *cobra.Command {
 	flags := apply.NewApplyFlags(ioStreams)
@@ -73,92 +316,50 @@ func NewCmdApply() *cobra.Command {
 		Short: "Apply a configuration to a resource in kubernetes",
 		Args:  cobra.NoArgs,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return validateTrackValue(applyWithTrack)
+			if err := validateTrackValue(applyWithTrack); err != nil {
+				return err
+			}
+			if applyParallel < 1 {
+				return fmt.Errorf("invalid --parallel %d (must be at least 1)", applyParallel)
+			}
+			return validateCanaryFlags(applyCanary, cmd.Flags().Changed("weight"), applyWeight, opts.blueGreen, flags.Prune)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
-			app, err := opts.initApp(ctx)
+			apps, err := opts.initApps(ctx)
 			cmdutil.CheckErr(err)
+			if len(apps) > 1 {
+				return applyApps(ctx, cmd, flags, opts, apps, args)
+			}
+			app := apps[0]
 
-			// The prune whitelist is derived per-app from the generator registry
-			// (output.go) so it cannot drift: every kind app2kube can emit is
-			// prunable (e.g. the PodDisruptionBudget that disappears when replicas
-			// scale back to 1, whose stale minAvailable would otherwise block
-			// every node drain), and nothing it never emits is listed (a stale
-			// entry would let prune delete an unrelated object matching the
-			// selector). It is app-aware so the cert-manager Certificate is only
-			// pruned when this app actually uses letsencrypt.
-			applyPruneWhitelist := app.PruneWhitelist()
-
-			applyManifest := func(manifest string, prune bool) error {
-				flags.Overwrite = true
-				flags.Prune = prune
-				flags.PruneWhitelist = applyPruneWhitelist
-				o, err := flags.ToOptions(kubeFactory, cmd, "app2kube", args)
-				cmdutil.CheckErr(err)
-				o.DryRunStrategy, err = cmdutil.GetDryRunStrategy(cmd)
-				cmdutil.CheckErr(err)
-
-				if o.Namespace != "" {
-					o.EnforceNamespace = true
-				}
-
-				if o.Prune {
-					o.Selector, err = scopedSelector(app.Labels)
-					cmdutil.CheckErr(err)
-				}
+			a, err := newApplier(cmd, flags, opts, app, args)
+			cmdutil.CheckErr(err)
 
-				cmdutil.CheckErr(o.Validate())
-
-				// Pre-build the objects from an in-memory reader and hand them to
-				// apply via SetObjects, so Run() uses them directly and never reads
-				// os.Stdin. This replaces the previous global os.Stdin hijack and its
-				// pipe-buffer deadlock window.
-				infos, err := streamApplyObjects(o.Builder, o.Validator, o.Namespace, o.EnforceNamespace, o.Selector, manifest)
-				// Return the error instead of cmdutil.CheckErr (which os.Exit()s): a
-				// parse error means app2kube emitted an invalid manifest (a bug), and
-				// blue/green callers must regain control to report that traffic was
-				// NOT switched before the process exits (#60).
-				if err != nil {
-					return err
-				}
-				// Mirror GetObjects(): apply ApplySet labels when one is configured.
-				// app2kube never sets --applyset today, so o.ApplySet is nil and this
-				// is a no-op, but it keeps parity with the builder path it replaces.
-				if o.ApplySet != nil {
-					if err := o.ApplySet.AddLabels(infos...); err != nil {
-						return err
-					}
+			if applyCanary {
+				// The canary rollout tracks the canary itself, and the
+				// stable track's hooks run on promotion.
+				var weight *int32
+				if cmd.Flags().Changed("weight") {
+					weight = &applyWeight
 				}
-				o.SetObjects(infos)
-
-				if runErr := o.Run(); runErr != nil {
-					return runErr
+				cmdutil.CheckErr(runCanary(ctx, a, weight))
+				if applyWithStatus {
+					fmt.Println()
+					cmdutil.CheckErr(status(ctx, app))
 				}
-
 				return nil
 			}
 
-			getManifest := func(output app2kube.OutputResource) (string, error) {
-				manifest, err := app.GetManifest("json", output)
-				if err != nil {
-					return "", err
-				}
-				if app.Namespace != app2kube.NamespaceDefault && opts.includeNamespace {
-					namespace, err := app.GetManifest("json", app2kube.OutputNamespace)
-					if err != nil {
-						return "", err
-					}
-					manifest = namespace + manifest
-				}
-				return manifest, nil
-			}
-
 			if opts.blueGreen {
 				if flags.Prune {
 					return fmt.Errorf("cannot prune resources with blue-green deployment")
 				}
 
+				// Pre-apply hooks run before phase 1 touches the target color;
+				// a failed hook leaves both colors as they were.
+				cmdutil.CheckErr(a.runHooks(ctx, app2kube.HookPreApply))
+
 				kcs, err := kubeFactory.KubernetesClientSet()
 				cmdutil.CheckErr(err)
 
@@ -168,7 +369,7 @@ func NewCmdApply() *cobra.Command {
 				// instead of being printed while the doomed apply proceeds (#65).
 				cmdutil.CheckErr(preDeleteDeployment(ctx, kcs, app.GetDeploymentName(), app.Namespace))
 
-				manifest, err := getManifest(app2kube.OutputAllForDeployment)
+				manifest, err := a.manifest(app, app2kube.OutputAllForDeployment)
 				cmdutil.CheckErr(err)
 
 				// Progress/diagnostics go to stderr so piped manifest/data on stdout
@@ -179,17 +380,17 @@ func NewCmdApply() *cobra.Command {
 				// Service/Ingress once it is ready. If either step fails the live
 				// color is unchanged — report that traffic was not switched and
 				// return; re-running replaces the stale target-color deployment (#60).
-				if err := applyManifest(manifest, false); err != nil {
+				if err := a.apply(manifest, false); err != nil {
 					fmt.Fprintf(os.Stderr, "• %s\n", blueGreenNotSwitchedMsg(app.Deployment.BlueGreenColor))
 					return err
 				}
 
-				if err := trackReady(ctx, app.GetDeploymentName(), app.Namespace, defaultTrackTimeout, time.Now()); err != nil {
+				if err := trackReady(ctx, appWorkloads(app), app.Namespace, defaultTrackTimeout, time.Now()); err != nil {
 					fmt.Fprintf(os.Stderr, "• %s\n", blueGreenNotSwitchedMsg(app.Deployment.BlueGreenColor))
 					return err
 				}
 
-				manifest, err = app.GetManifest("json", app2kube.OutputAllOther)
+				manifest, err = renderManifest(app, a.renderer, "json", false, app2kube.OutputAllOther)
 				cmdutil.CheckErr(err)
 
 				fmt.Fprintf(os.Stderr, "• Final deploy for [%s]:\n", colorize(app.Deployment.BlueGreenColor))
@@ -201,30 +402,15 @@ func NewCmdApply() *cobra.Command {
 				// while the Ingress still lags. Warn that traffic may be partially
 				// switched (not "not switched" as in phase 1) so the operator knows
 				// to re-run to converge, instead of os.Exit()ing with no guidance.
-				if err := applyManifest(manifest, false); err != nil {
+				if err := a.apply(manifest, false); err != nil {
 					fmt.Fprintf(os.Stderr, "• WARNING: the final switch for [%s] failed partway; live traffic may be PARTIALLY switched. Re-run the blue-green deploy to converge.\n", colorize(app.Deployment.BlueGreenColor))
 					return err
 				}
-			} else {
-				manifest, err := getManifest(app2kube.OutputAll)
-				cmdutil.CheckErr(err)
-
-				cmdutil.CheckErr(applyManifest(manifest, flags.Prune))
-			}
 
-			if applyWithTrack != "" && len(app.Deployment.Containers) > 0 {
-				// Scope the tracking error to its own variable instead of reusing
-				// the outer err, and CheckErr it inside the block. Reusing err meant
-				// any future early path leaving err non-nil before here would be
-				// re-surfaced via CheckErr's os.Exit even when tracking ran fine (#68).
-				var trackErr error
-				switch strings.ToLower(applyWithTrack) {
-				case "follow":
-					trackErr = trackFollow(ctx, app.GetDeploymentName(), app.Namespace, applyTimeout, time.Now())
-				case "ready":
-					trackErr = trackReady(ctx, app.GetDeploymentName(), app.Namespace, applyTimeout, time.Now())
-				}
-				cmdutil.CheckErr(trackErr)
+				cmdutil.CheckErr(a.track(ctx))
+				cmdutil.CheckErr(a.runHooks(ctx, app2kube.HookPostApply))
+			} else {
+				cmdutil.CheckErr(a.deploy(ctx, flags.Prune))
 			}
 
 			if applyWithStatus {
@@ -238,16 +424,202 @@ func NewCmdApply() *cobra.Command {
 
 	opts = addAppFlags(applyCmd)
 	addBlueGreenFlag(applyCmd, opts)
-	flags.PrintFlags.AddFlags(applyCmd)
-	cmdutil.AddDryRunFlag(applyCmd)
-	cmdutil.AddServerSideApplyFlags(applyCmd)
-	cmdutil.AddValidateFlags(applyCmd)
-	cmdutil.AddFieldManagerFlagVar(applyCmd, &flags.FieldManager, apply.FieldManagerClientSideApply)
+	addPostRendererFlags(applyCmd, opts)
+	addApplyFlags(applyCmd, flags)
 
 	applyCmd.Flags().BoolVar(&flags.Prune, "prune", false, "Automatically delete resource objects, including the uninitialized ones, that do not appear in the configs and are created by either apply.")
 	applyCmd.Flags().BoolVar(&applyWithStatus, "status", false, "Show application resources status in kubernetes after apply")
 	applyCmd.Flags().StringVar(&applyWithTrack, "track", "", "Track Deployment (ready|follow)")
 	applyCmd.Flags().IntVar(&applyTimeout, "timeout", defaultTrackTimeout, "Timeout in minutes for --track. 0 is wait forever")
+	applyCmd.Flags().BoolVar(&applyCanary, "canary", false, "Deploy a canary next to the stable Deployment behind a weighted nginx canary Ingress")
+	applyCmd.Flags().Int32Var(&applyWeight, "weight", 0, "Canary traffic weight in percent (overrides canary.weight and canary.steps)")
+	applyCmd.Flags().IntVar(&applyParallel, "parallel", applyParallel, "Number of applications of values declaring apps applied at once")
 
 	return applyCmd
 }
+
+// appResult is the outcome of applying one application of several.
+type appResult struct {
+	name     string
+	duration time.Duration
+	err      error
+}
+
+// applyApps applies the applications of values declaring several, at most
+// --parallel at once, and prints the result of each. With --prune, once every
+// application applied, the objects missing from all their manifests are
+// pruned in one scope per namespace, selecting the objects of every
+// application in it: an object moving from one application to another is
+// never pruned in between.
+func applyApps(ctx context.Context, cmd *cobra.Command, flags *apply.ApplyFlags, opts *appOptions, apps []*app2kube.App, args []string) error {
+	if applyCanary || opts.blueGreen {
+		return errors.New("--canary and --blue-green deploy a single application: select it with --app")
+	}
+	cmd.SilenceUsage = true
+
+	// Applications sharing a namespace would create it concurrently; it is
+	// applied once, before them.
+	appOpts := *opts
+	appOpts.includeNamespace = false
+	appliers := make([]*applier, len(apps))
+	namespaces := map[string]bool{}
+	for i, app := range apps {
+		a, err := newApplier(cmd, flags, &appOpts, app, args)
+		if err != nil {
+			return err
+		}
+		appliers[i] = a
+		if opts.includeNamespace && app.Namespace != app2kube.NamespaceDefault && !namespaces[app.Namespace] {
+			namespaces[app.Namespace] = true
+			manifest, err := renderManifest(app, a.renderer, "json", false, app2kube.OutputNamespace)
+			if err != nil {
+				return err
+			}
+			if err := a.apply(manifest, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	results := make([]appResult, len(appliers))
+	slots := make(chan struct{}, applyParallel)
+	var wg sync.WaitGroup
+	for i, a := range appliers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			start := time.Now()
+			err := a.deploy(ctx, false)
+			results[i] = appResult{name: a.app.Name, duration: time.Since(start), err: err}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+	}
+	if flags.Prune {
+		if failed > 0 {
+			fmt.Fprintln(os.Stderr, "• Skipping prune: not every application was applied")
+		} else if err := pruneApps(appliers); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stderr)
+	printAppResults(os.Stderr, results)
+
+	if applyWithStatus {
+		for _, app := range apps {
+			fmt.Println()
+			if err := status(ctx, app); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d applications failed", failed, len(results))
+	}
+	return nil
+}
+
+// pruneApps applies the manifests of the applications again with prune, in
+// one apply per group of pruneGroups whose selector matches the objects of
+// every application in it.
+func pruneApps(appliers []*applier) error {
+	groups, err := pruneGroups(appliers)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		var (
+			manifest  string
+			whitelist []string
+			apps      []*app2kube.App
+		)
+		for _, a := range group {
+			m, err := a.manifest(a.app, app2kube.OutputAll)
+			if err != nil {
+				return err
+			}
+			manifest += m
+			whitelist = append(whitelist, a.pruneWhitelist...)
+			apps = append(apps, a.app)
+		}
+		selector, err := appsSelector(apps)
+		if err != nil {
+			return err
+		}
+		pruner := *group[0]
+		slices.Sort(whitelist)
+		pruner.pruneWhitelist = slices.Compact(whitelist)
+		pruner.selector = selector
+		if err := pruner.apply(manifest, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneGroups groups the applications sharing a namespace and every label but
+// the name, so one selector matches exactly the objects of a group. It refuses
+// groups whose selector would match the objects of another group, which that
+// group's prune would then delete.
+func pruneGroups(appliers []*applier) ([][]*applier, error) {
+	var order []string
+	groups := map[string][]*applier{}
+	for _, a := range appliers {
+		key := a.app.Namespace + "/" + labels.SelectorFromSet(unnamedLabels(a.app.Labels)).String()
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], a)
+	}
+
+	var result [][]*applier
+	for _, key := range order {
+		group := groups[key]
+		var apps []*app2kube.App
+		for _, a := range group {
+			apps = append(apps, a.app)
+		}
+		selector, err := appsSelector(apps)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range appliers {
+			if other.app.Namespace == group[0].app.Namespace && !slices.Contains(group, other) &&
+				parsed.Matches(labels.Set(other.app.Labels)) {
+				return nil, fmt.Errorf("refusing to prune: the selector %q of %s also matches %s", selector, group[0].app.Name, other.app.Name)
+			}
+		}
+		result = append(result, group)
+	}
+	return result, nil
+}
+
+// printAppResults prints a table of the applications applied, with how long
+// each took and its error, if any.
+func printAppResults(w io.Writer, results []appResult) {
+	tw := printers.GetNewTabWriter(w)
+	fmt.Fprintln(tw, "APP\tDURATION\tRESULT")
+	for _, r := range results {
+		result := "applied"
+		if r.err != nil {
+			result = "failed: " + r.err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.name, r.duration.Round(time.Second), result)
+	}
+	_ = tw.Flush()
+}
@@ -1,14 +1,26 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
 	"os"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
+	"github.com/spf13/cobra"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/rest/fake"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
 	"k8s.io/kubectl/pkg/validation"
 )
 
@@ -95,3 +107,93 @@ func TestValidateTrackValue(t *testing.T) {
 		}
 	}
 }
+
+func TestPrintAppResults(t *testing.T) {
+	var out strings.Builder
+	printAppResults(&out, []appResult{
+		{name: "web", duration: 12400 * time.Millisecond},
+		{name: "api", duration: 3 * time.Second, err: errors.New("timed out")},
+	})
+	want := "APP   DURATION   RESULT\n" +
+		"web   12s        applied\n" +
+		"api   3s         failed: timed out\n"
+	if out.String() != want {
+		t.Errorf("results:\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Applications applied concurrently must not share the kubectl printer flags
+// the operation of each object is written to (run with -race).
+func TestApplyConcurrently(t *testing.T) {
+	tf := cmdtesting.NewTestFactory().WithNamespace("test")
+	defer tf.Cleanup()
+	tf.ClientConfigVal = cmdtesting.DefaultClientConfig()
+	// The fake client records the last request, so each object gets its own.
+	tf.UnstructuredClientForMappingFunc = func(schema.GroupVersion) (resource.RESTClient, error) {
+		return &fake.RESTClient{
+			NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				switch req.Method {
+				case http.MethodGet:
+					return &http.Response{StatusCode: http.StatusNotFound, Header: cmdtesting.DefaultHeader(), Body: io.NopCloser(bytes.NewReader(nil))}, nil
+				case http.MethodPost:
+					return &http.Response{StatusCode: http.StatusCreated, Header: cmdtesting.DefaultHeader(), Body: req.Body}, nil
+				}
+				t.Errorf("unexpected request: %s %s", req.Method, req.URL)
+				return nil, errors.New("unexpected request")
+			}),
+		}, nil
+	}
+	origFactory := kubeFactory
+	kubeFactory = tf
+	defer func() { kubeFactory = origFactory }()
+
+	out := &syncBuffer{}
+	flags := apply.NewApplyFlags(genericclioptions.IOStreams{Out: out, ErrOut: io.Discard})
+	flags.DeleteFlags.FileNameFlags.Filenames = &[]string{"-"}
+	cmd := &cobra.Command{}
+	addApplyFlags(cmd, flags)
+
+	names := []string{"shop", "cart"}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		app := app2kube.NewApp()
+		if _, err := app.LoadValues(nil, []string{"name=" + name, "namespace=test", "configmap.MODE=prod"}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		a, err := newApplier(cmd, flags, &appOptions{}, app, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := a.manifest(app, app2kube.OutputConfigMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.apply(manifest, false); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		if want := "configmap/" + name + " created\n"; !strings.Contains(out.buf.String(), want) {
+			t.Errorf("no %q in:\n%s", want, out.buf.String())
+		}
+	}
+}
@@ -73,7 +73,7 @@ func NewCmdBlueGreen() *cobra.Command {
 		// value, so a slow previous-color rollout is not cut off prematurely. A
 		// command-local variable is used rather than the global trackTimeout so a
 		// rollback can't leak its timeout into a later track in the same process.
-		err := trackReady(ctx, app.GetDeploymentName(), app.Namespace, rollbackTimeout, time.Now())
+		err := trackReady(ctx, appWorkloads(app), app.Namespace, rollbackTimeout, time.Now())
 		if err != nil {
 			return err
 		}
@@ -117,8 +117,9 @@ func NewCmdBlueGreen() *cobra.Command {
 		if err := deleteDeployment(ctx, app.GetDeploymentName(), app.Namespace); err != nil {
 			return err
 		}
-		// Remove the matching per-color PodDisruptionBudget too (multi-replica
-		// deploys only); otherwise it is orphaned by the prune.
+		// Remove the matching per-color PodDisruptionBudget,
+		// HorizontalPodAutoscaler and NetworkPolicy too (when emitted);
+		// otherwise they are orphaned by the prune.
 		kcs, err := kubeFactory.KubernetesClientSet()
 		if err != nil {
 			return err
@@ -126,6 +127,12 @@ func NewCmdBlueGreen() *cobra.Command {
 		if err := prunePodDisruptionBudget(ctx, kcs, app.GetDeploymentName(), app.Namespace); err != nil {
 			return err
 		}
+		if err := pruneHorizontalPodAutoscaler(ctx, kcs, app.GetDeploymentName(), app.Namespace); err != nil {
+			return err
+		}
+		if err := pruneNetworkPolicy(ctx, kcs, app.GetDeploymentName(), app.Namespace); err != nil {
+			return err
+		}
 		fmt.Printf("Deployment %s pruned\n", colorize(app.Deployment.BlueGreenColor, app.GetDeploymentName()))
 		return nil
 	})
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/cmd/apply"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// validateCanaryFlags rejects apply flag combinations the canary rollout cannot
+// honor. It runs in PreRunE, before initApp touches the cluster.
+func validateCanaryFlags(canary, weightSet bool, weight int32, blueGreen, prune bool) error {
+	if !canary {
+		if weightSet {
+			return fmt.Errorf("--weight requires --canary")
+		}
+		return nil
+	}
+	switch {
+	case blueGreen:
+		return fmt.Errorf("--canary cannot be combined with --blue-green")
+	case prune:
+		// The stable prune selector does not match the canary track, and
+		// pruning the stable objects mid-rollout is never what is meant.
+		return fmt.Errorf("cannot prune resources with canary deployment")
+	case weightSet && (weight < 0 || weight > 100):
+		return fmt.Errorf("invalid --weight %d (must be between 0 and 100)", weight)
+	}
+	return nil
+}
+
+// canarySelector selects the objects of the canary track of an app.
+func canarySelector(canary *app2kube.App) string {
+	return labels.SelectorFromSet(labels.Set{
+		app2kube.LabelName:     canary.Labels[app2kube.LabelName],
+		app2kube.LabelInstance: canary.Labels[app2kube.LabelInstance],
+		app2kube.LabelTrack:    app2kube.TrackCanary,
+	}).String()
+}
+
+// runCanary deploys the canary track next to the stable one and walks its
+// traffic weights. The first weight is applied with the canary workload and
+// waited for; every later step pauses for canary.pause, re-checks that the
+// canary is ready and only then shifts more traffic. With canary.autoPromote
+// the stable track is promoted after one more pause and readiness check.
+func runCanary(ctx context.Context, a *applier, weight *int32) error {
+	canary, err := a.app.CanaryApp()
+	if err != nil {
+		return err
+	}
+
+	weights := a.app.CanaryWeights(weight)
+	canary.SetCanaryWeight(weights[0])
+	manifest, err := a.manifest(canary, app2kube.CanaryResources...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "• Canary %s at %d%% of traffic:\n", canary.GetDeploymentName(), weights[0])
+	if err := a.apply(manifest, false); err != nil {
+		return err
+	}
+
+	// Nothing was rolled out to wait for, so a dry run stops at the first
+	// step.
+	if a.dryRun != cmdutil.DryRunNone {
+		return nil
+	}
+
+	if err := trackReady(ctx, appWorkloads(canary), canary.Namespace, a.timeout, time.Now()); err != nil {
+		return err
+	}
+
+	kcs, err := kubeFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	for _, w := range weights[1:] {
+		if err := pauseCanary(ctx, a.app.Canary.Pause.Duration); err != nil {
+			return err
+		}
+		if err := checkCanaryReady(ctx, kcs, canary); err != nil {
+			return err
+		}
+		canary.SetCanaryWeight(w)
+		manifest, err := a.manifest(canary, app2kube.OutputIngress)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "• Canary %s at %d%% of traffic:\n", canary.GetDeploymentName(), w)
+		if err := a.apply(manifest, false); err != nil {
+			return err
+		}
+	}
+
+	if !a.app.Canary.AutoPromote {
+		fmt.Fprintf(os.Stderr, "• Canary %s is live; run `canary promote` or `canary abort` with the same values to finish\n", canary.GetDeploymentName())
+		return nil
+	}
+
+	if err := pauseCanary(ctx, a.app.Canary.Pause.Duration); err != nil {
+		return err
+	}
+	if err := checkCanaryReady(ctx, kcs, canary); err != nil {
+		return fmt.Errorf("not promoting: %w", err)
+	}
+	return promoteCanary(ctx, a, kcs)
+}
+
+// pauseCanary waits between two canary steps, returning early when ctx is
+// cancelled.
+func pauseCanary(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "• Pausing %s\n", d)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// checkCanaryReady verifies that the canary Deployment is still fully rolled
+// out and ready before more traffic is shifted to it: a canary whose pods
+// started crash-looping during the pause must stop the rollout.
+func checkCanaryReady(ctx context.Context, kcs kubernetes.Interface, canary *app2kube.App) error {
+	name := canary.GetDeploymentName()
+	deploy, err := kcs.AppsV1().Deployments(canary.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	want := int32(1)
+	if deploy.Spec.Replicas != nil {
+		want = *deploy.Spec.Replicas
+	}
+	st := deploy.Status
+	if st.ObservedGeneration < deploy.Generation || st.UpdatedReplicas < want || st.ReadyReplicas < want {
+		return fmt.Errorf("canary %s is not ready (%d/%d replicas ready)", name, st.ReadyReplicas, want)
+	}
+	return nil
+}
+
+// promoteCanary rolls the stable track to the current values (the image the
+// canary ran) with an ordinary apply, hooks included, waits for it and then
+// removes the canary track.
+func promoteCanary(ctx context.Context, a *applier, kcs kubernetes.Interface) error {
+	canary, err := a.app.CanaryApp()
+	if err != nil {
+		return err
+	}
+
+	if err := a.runHooks(ctx, app2kube.HookPreApply); err != nil {
+		return err
+	}
+
+	manifest, err := a.manifest(a.app, app2kube.OutputAll)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "• Promote %s to stable:\n", canary.GetDeploymentName())
+	if err := a.apply(manifest, false); err != nil {
+		return err
+	}
+
+	if a.dryRun != cmdutil.DryRunNone {
+		return nil
+	}
+
+	if err := trackReady(ctx, appWorkloads(a.app), a.app.Namespace, a.timeout, time.Now()); err != nil {
+		return err
+	}
+	if err := a.runHooks(ctx, app2kube.HookPostApply); err != nil {
+		return err
+	}
+	return deleteCanary(ctx, kcs, canary)
+}
+
+// deleteCanary removes every object of the canary track. The canary Ingresses
+// go first so no traffic is routed to the canary while its Service and pods
+// are being torn down. Objects already gone are ignored, so an abort can be
+// re-run.
+func deleteCanary(ctx context.Context, kcs kubernetes.Interface, canary *app2kube.App) error {
+	ns := canary.Namespace
+	list := metav1.ListOptions{LabelSelector: canarySelector(canary)}
+
+	ingresses, err := kcs.NetworkingV1().Ingresses(ns).List(ctx, list)
+	if err != nil {
+		return err
+	}
+	if err := deleteNamed(ctx, "Ingress", objectNames(ingresses.Items), kcs.NetworkingV1().Ingresses(ns).Delete); err != nil {
+		return err
+	}
+
+	services, err := kcs.CoreV1().Services(ns).List(ctx, list)
+	if err != nil {
+		return err
+	}
+	if err := deleteNamed(ctx, "Service", objectNames(services.Items), kcs.CoreV1().Services(ns).Delete); err != nil {
+		return err
+	}
+
+	deployments, err := kcs.AppsV1().Deployments(ns).List(ctx, list)
+	if err != nil {
+		return err
+	}
+	if err := deleteNamed(ctx, "Deployment", objectNames(deployments.Items), kcs.AppsV1().Deployments(ns).Delete); err != nil {
+		return err
+	}
+
+	policies, err := kcs.NetworkingV1().NetworkPolicies(ns).List(ctx, list)
+	if err != nil {
+		return err
+	}
+	if err := deleteNamed(ctx, "NetworkPolicy", objectNames(policies.Items), kcs.NetworkingV1().NetworkPolicies(ns).Delete); err != nil {
+		return err
+	}
+
+	configMaps, err := kcs.CoreV1().ConfigMaps(ns).List(ctx, list)
+	if err != nil {
+		return err
+	}
+	if err := deleteNamed(ctx, "ConfigMap", objectNames(configMaps.Items), kcs.CoreV1().ConfigMaps(ns).Delete); err != nil {
+		return err
+	}
+
+	secrets, err := kcs.CoreV1().Secrets(ns).List(ctx, list)
+	if err != nil {
+		return err
+	}
+	return deleteNamed(ctx, "Secret", objectNames(secrets.Items), kcs.CoreV1().Secrets(ns).Delete)
+}
+
+// objectNames returns the names of listed objects.
+func objectNames[T any, PT interface {
+	*T
+	metav1.Object
+}](items []T) []string {
+	names := make([]string, 0, len(items))
+	for i := range items {
+		names = append(names, PT(&items[i]).GetName())
+	}
+	return names
+}
+
+// deleteNamed deletes the named objects of one kind, ignoring those already
+// gone.
+func deleteNamed(ctx context.Context, kind string, names []string, del func(context.Context, string, metav1.DeleteOptions) error) error {
+	for _, name := range names {
+		err := del(ctx, name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s %s deleted\n", kind, name)
+	}
+	return nil
+}
+
+// NewCmdCanary return canary command
+func NewCmdCanary() *cobra.Command {
+	canaryCmd := &cobra.Command{
+		Use:   "canary",
+		Short: "Commands for canary deployment",
+	}
+
+	promoteFlags := apply.NewApplyFlags(ioStreams)
+	var (
+		promoteOpts    *appOptions
+		promoteTimeout int
+	)
+	promoteCmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Roll the stable Deployment to the canary values and remove the canary",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			app, err := promoteOpts.initApp(ctx)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			a, err := newApplier(cmd, promoteFlags, promoteOpts, app, args)
+			if err != nil {
+				return err
+			}
+			a.timeout = promoteTimeout
+			kcs, err := kubeFactory.KubernetesClientSet()
+			if err != nil {
+				return err
+			}
+			return promoteCanary(ctx, a, kcs)
+		},
+	}
+	promoteOpts = addAppFlags(promoteCmd)
+	addApplyFlags(promoteCmd, promoteFlags)
+	addPostRendererFlags(promoteCmd, promoteOpts)
+	promoteCmd.Flags().IntVarP(&promoteTimeout, "timeout", "t", defaultTrackTimeout, "Timeout of operation in minutes. 0 is wait forever")
+	canaryCmd.AddCommand(promoteCmd)
+
+	var abortOpts *appOptions
+	abortCmd := &cobra.Command{
+		Use:   "abort",
+		Short: "Remove the canary and route all traffic back to the stable Deployment",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			// Nothing is rendered, but the flags are checked as for promote.
+			if _, err := abortOpts.newPostRenderer(); err != nil {
+				return err
+			}
+			app, err := abortOpts.initApp(ctx)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			canary, err := app.CanaryApp()
+			if err != nil {
+				return err
+			}
+			kcs, err := kubeFactory.KubernetesClientSet()
+			if err != nil {
+				return err
+			}
+			return deleteCanary(ctx, kcs, canary)
+		},
+	}
+	abortOpts = addAppFlags(abortCmd)
+	addPostRendererFlags(abortCmd, abortOpts)
+	_ = abortCmd.Flags().MarkHidden("include-namespace")
+	canaryCmd.AddCommand(abortCmd)
+
+	return canaryCmd
+}
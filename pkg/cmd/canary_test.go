@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func canaryTestApp(t *testing.T) *app2kube.App {
+	t.Helper()
+	app := app2kube.NewApp()
+	app.Name = "web"
+	app.Namespace = "prod"
+	app.Labels[app2kube.LabelName] = "web"
+	app.Deployment.Containers = map[string]apiv1.Container{"app": {Image: "example/web:v2"}}
+	canary, err := app.CanaryApp()
+	if err != nil {
+		t.Fatalf("CanaryApp: %v", err)
+	}
+	return canary
+}
+
+func TestValidateCanaryFlags(t *testing.T) {
+	cases := map[string]struct {
+		canary, weightSet, blueGreen, prune bool
+		weight                              int32
+		valid                               bool
+	}{
+		"plain apply":        {valid: true},
+		"canary":             {canary: true, valid: true},
+		"canary with weight": {canary: true, weightSet: true, weight: 25, valid: true},
+		"weight alone":       {weightSet: true, weight: 25},
+		"weight over 100":    {canary: true, weightSet: true, weight: 150},
+		"with blue-green":    {canary: true, blueGreen: true},
+		"with prune":         {canary: true, prune: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateCanaryFlags(tc.canary, tc.weightSet, tc.weight, tc.blueGreen, tc.prune)
+			if (err == nil) != tc.valid {
+				t.Errorf("validateCanaryFlags() = %v, want valid=%v", err, tc.valid)
+			}
+		})
+	}
+}
+
+// A canary whose pods stopped being ready during a pause must halt the rollout
+// before more traffic is shifted to it.
+func TestCheckCanaryReady(t *testing.T) {
+	ctx := context.Background()
+	canary := canaryTestApp(t)
+	deploy := func(ready int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: canary.GetDeploymentName(), Namespace: "prod", Generation: 2},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+			Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 2, ReadyReplicas: ready},
+		}
+	}
+
+	if err := checkCanaryReady(ctx, fake.NewSimpleClientset(deploy(2)), canary); err != nil {
+		t.Errorf("a ready canary must pass: %v", err)
+	}
+	if err := checkCanaryReady(ctx, fake.NewSimpleClientset(deploy(1)), canary); err == nil {
+		t.Errorf("a canary with unready replicas must fail")
+	}
+	if err := checkCanaryReady(ctx, fake.NewSimpleClientset(), canary); err == nil {
+		t.Errorf("a missing canary must fail")
+	}
+}
+
+// deleteCanary removes only the canary track's objects, never the stable ones
+// sharing the namespace, and is safe to re-run.
+func TestDeleteCanary(t *testing.T) {
+	ctx := context.Background()
+	canary := canaryTestApp(t)
+	canaryLabels := map[string]string{
+		app2kube.LabelName:     "web",
+		app2kube.LabelInstance: canary.Labels[app2kube.LabelInstance],
+		app2kube.LabelTrack:    app2kube.TrackCanary,
+	}
+	stableLabels := map[string]string{
+		app2kube.LabelName:     "web",
+		app2kube.LabelInstance: "production",
+	}
+	meta := func(name string, labels map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "prod", Labels: labels}
+	}
+
+	kcs := fake.NewSimpleClientset(
+		&networkingv1.Ingress{ObjectMeta: meta("web-canary-example.com", canaryLabels)},
+		&networkingv1.Ingress{ObjectMeta: meta("web-example.com", stableLabels)},
+		&apiv1.Service{ObjectMeta: meta("web-canary", canaryLabels)},
+		&apiv1.Service{ObjectMeta: meta("web", stableLabels)},
+		&appsv1.Deployment{ObjectMeta: meta("web-canary", canaryLabels)},
+		&appsv1.Deployment{ObjectMeta: meta("web", stableLabels)},
+		&apiv1.ConfigMap{ObjectMeta: meta("web-canary", canaryLabels)},
+		&apiv1.Secret{ObjectMeta: meta("web-canary", canaryLabels)},
+	)
+	if err := deleteCanary(ctx, kcs, canary); err != nil {
+		t.Fatalf("deleteCanary: %v", err)
+	}
+
+	if _, err := kcs.AppsV1().Deployments("prod").Get(ctx, "web-canary", metav1.GetOptions{}); err == nil {
+		t.Errorf("the canary Deployment must be deleted")
+	}
+	if _, err := kcs.NetworkingV1().Ingresses("prod").Get(ctx, "web-canary-example.com", metav1.GetOptions{}); err == nil {
+		t.Errorf("the canary Ingress must be deleted")
+	}
+	if _, err := kcs.CoreV1().Secrets("prod").Get(ctx, "web-canary", metav1.GetOptions{}); err == nil {
+		t.Errorf("the canary Secret must be deleted")
+	}
+	if _, err := kcs.AppsV1().Deployments("prod").Get(ctx, "web", metav1.GetOptions{}); err != nil {
+		t.Errorf("the stable Deployment must be kept: %v", err)
+	}
+	if _, err := kcs.NetworkingV1().Ingresses("prod").Get(ctx, "web-example.com", metav1.GetOptions{}); err != nil {
+		t.Errorf("the stable Ingress must be kept: %v", err)
+	}
+	if _, err := kcs.CoreV1().Services("prod").Get(ctx, "web", metav1.GetOptions{}); err != nil {
+		t.Errorf("the stable Service must be kept: %v", err)
+	}
+
+	if err := deleteCanary(ctx, kcs, canary); err != nil {
+		t.Errorf("re-running deleteCanary must succeed: %v", err)
+	}
+}
+
+// promote and abort take the post-renderer flags of apply, and promote's
+// --timeout is its own: setting it must not change apply's.
+func TestCanarySubcommandFlags(t *testing.T) {
+	for _, c := range NewCmdCanary().Commands() {
+		for _, name := range []string{"post-renderer", "post-renderer-args"} {
+			if c.Flags().Lookup(name) == nil {
+				t.Errorf("canary %s must expose --%s", c.Name(), name)
+			}
+		}
+		if c.Name() != "promote" {
+			continue
+		}
+		if err := c.Flags().Set("timeout", "42"); err != nil {
+			t.Fatal(err)
+		}
+		if applyTimeout != defaultTrackTimeout {
+			t.Errorf("promote --timeout changed apply's timeout to %d", applyTimeout)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
@@ -17,6 +21,13 @@ func TestCommandsRejectUnexpectedArgs(t *testing.T) {
 		noArgCmds = append(noArgCmds, parent.Commands()...)
 	}
 	for _, c := range noArgCmds {
+		// `config explain` takes an optional path, but no more.
+		if c.Name() == "explain" {
+			if c.ValidateArgs([]string{"name", "unexpected"}) == nil {
+				t.Errorf("command %q must reject a second positional arg", c.Name())
+			}
+			continue
+		}
 		if c.ValidateArgs([]string{"unexpected"}) == nil {
 			t.Errorf("command %q must reject unexpected positional args", c.Name())
 		}
@@ -109,6 +120,35 @@ func TestInitAppFromSet(t *testing.T) {
 	}
 }
 
+func TestInitAppSafeTemplates(t *testing.T) {
+	resetAppFlags()
+	defer resetAppFlags()
+	t.Setenv("APP2KUBE_TEST_NAME", "fromenv")
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte(`name: {{ env "APP2KUBE_TEST_NAME" }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &appOptions{valueFiles: app2kube.ValueFiles{path}, templateEnv: []string{"APP2KUBE_TEST_NAME"}}
+	if _, err := o.initApp(context.Background()); err == nil || !strings.Contains(err.Error(), "--template-env requires --safe-templates") {
+		t.Errorf("--template-env without --safe-templates: got %v", err)
+	}
+
+	o.safeTemplates = true
+	app, err := o.initApp(context.Background())
+	if err != nil {
+		t.Fatalf("initApp: %v", err)
+	}
+	if app.Name != "fromenv" {
+		t.Errorf("name: got %q", app.Name)
+	}
+
+	o.templateEnv = nil
+	if _, err := o.initApp(context.Background()); err == nil || !strings.Contains(err.Error(), `"APP2KUBE_TEST_NAME" is not allowed`) {
+		t.Errorf("a variable not listed must be refused, got %v", err)
+	}
+}
+
 func TestInitAppNamespaceOverride(t *testing.T) {
 	resetAppFlags()
 	defer resetAppFlags()
@@ -208,6 +248,121 @@ func TestScopedSelector(t *testing.T) {
 	flagAllApplications = false
 }
 
+func TestAppsSelector(t *testing.T) {
+	resetAppFlags()
+	defer resetAppFlags()
+
+	apps := []*app2kube.App{
+		{Labels: map[string]string{"app.kubernetes.io/name": "web", "app.kubernetes.io/instance": "production", "team": "shop"}},
+		{Labels: map[string]string{"app.kubernetes.io/name": "api", "app.kubernetes.io/instance": "production", "team": "shop"}},
+	}
+	got, err := appsSelector(apps)
+	if err != nil {
+		t.Fatalf("appsSelector: %v", err)
+	}
+	if want := "app.kubernetes.io/instance=production,team=shop,app.kubernetes.io/name in (api,web)"; got != want {
+		t.Errorf("selector: got %q, want %q", got, want)
+	}
+
+	// Differing labels are not dropped: that would widen the selector.
+	apps[1].Labels["app.kubernetes.io/instance"] = "staging"
+	if _, err := appsSelector(apps); err == nil || !strings.Contains(err.Error(), "their labels differ") {
+		t.Errorf("expected error for differing instances, got %v", err)
+	}
+	apps[1].Labels["app.kubernetes.io/instance"] = "production"
+
+	// Every application must be scoped, as for a single one.
+	apps = append(apps, &app2kube.App{Labels: map[string]string{"app.kubernetes.io/instance": "production"}})
+	if _, err := appsSelector(apps); err == nil {
+		t.Errorf("expected error for an unscoped application")
+	}
+}
+
+// Applications of several instances in one namespace are pruned apart, each
+// group with a selector matching only its own objects.
+func TestPruneGroups(t *testing.T) {
+	resetAppFlags()
+	defer resetAppFlags()
+
+	app := func(name, instance string) *applier {
+		return &applier{app: &app2kube.App{Name: name, Namespace: "shop", Labels: map[string]string{
+			"app.kubernetes.io/name": name, "app.kubernetes.io/instance": instance,
+		}}}
+	}
+	groups, err := pruneGroups([]*applier{app("web", "production"), app("web-branch", "staging"), app("api", "production")})
+	if err != nil {
+		t.Fatalf("pruneGroups: %v", err)
+	}
+	var got []string
+	for _, group := range groups {
+		var apps []*app2kube.App
+		for _, a := range group {
+			apps = append(apps, a.app)
+		}
+		selector, err := appsSelector(apps)
+		if err != nil {
+			t.Fatalf("appsSelector: %v", err)
+		}
+		got = append(got, selector)
+	}
+	want := []string{
+		"app.kubernetes.io/instance=production,app.kubernetes.io/name in (api,web)",
+		"app.kubernetes.io/instance=staging,app.kubernetes.io/name in (web-branch)",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("selectors: got %q, want %q", got, want)
+	}
+
+	// A group whose selector matches another group's objects is refused.
+	extra := app("web", "production")
+	extra.app.Labels["team"] = "shop"
+	if _, err := pruneGroups([]*applier{app("web", "production"), extra}); err == nil || !strings.Contains(err.Error(), "also matches") {
+		t.Errorf("expected error for an overlapping selector, got %v", err)
+	}
+}
+
+func TestInitApps(t *testing.T) {
+	resetAppFlags()
+	defer resetAppFlags()
+	o := &appOptions{
+		jsonValues: []string{`apps=[{"name":"web"},{"name":"api","namespace":"core"}]`},
+		values:     []string{"namespace=shop"},
+	}
+
+	loaded, err := o.initApps(context.Background())
+	if err != nil {
+		t.Fatalf("initApps: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Name != "web" || loaded[1].Name != "api" {
+		t.Fatalf("applications: got %+v", loaded)
+	}
+	// The flags win over the applications' own values.
+	if loaded[1].Namespace != "shop" {
+		t.Errorf("namespace: got %q", loaded[1].Namespace)
+	}
+
+	if _, err := o.initApp(context.Background()); err == nil || !strings.Contains(err.Error(), "select one with --app") {
+		t.Errorf("a single-application command must require --app, got %v", err)
+	}
+	o.apps = []string{"api"}
+	app, err := o.initApp(context.Background())
+	if err != nil {
+		t.Fatalf("initApp: %v", err)
+	}
+	if app.Name != "api" {
+		t.Errorf("name: got %q", app.Name)
+	}
+
+	o.apps = []string{"nope"}
+	if _, err := o.initApps(context.Background()); err == nil || !strings.Contains(err.Error(), `unknown --app "nope" (the values declare web, api)`) {
+		t.Errorf("expected an unknown --app error, got %v", err)
+	}
+	o = &appOptions{values: []string{"name=app"}, apps: []string{"app"}}
+	if _, err := o.initApps(context.Background()); err == nil || !strings.Contains(err.Error(), "--app requires values declaring apps") {
+		t.Errorf("expected --app to require apps, got %v", err)
+	}
+}
+
 func TestCommandConstructors(t *testing.T) {
 	// Smoke: command constructors must build without panicking and expose the
 	// expected names.
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"sort"
@@ -173,6 +174,51 @@ func NewCmdConfig() *cobra.Command {
 	encryptCmd.Flags().VarP(&encryptFiles, "values", "f", "Encrypt secrets in a file (can specify multiple)")
 	configCmd.AddCommand(encryptCmd)
 
+	explainCmd := &cobra.Command{
+		Use:   "explain [path]",
+		Short: "Print the merged values with the source of each value",
+		Long:  "Prints the merged values, or those under a dotted path such as deployment.replicaCount,\nwith a comment naming the value file and line or the flag that set each value\nand the earlier layers it overrode.",
+		Args:  cobra.MaximumNArgs(1),
+	}
+	explainOpts := addAppFlags(explainCmd)
+	_ = explainCmd.Flags().MarkHidden("include-namespace")
+	explainCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		app, src, err := explainOpts.newApp()
+		if err != nil {
+			return err
+		}
+		var path string
+		if len(args) == 1 {
+			path = args[0]
+		}
+		cmd.SilenceUsage = true
+		if src.App, err = explainOpts.appName(); err != nil {
+			return err
+		}
+		out, err := app.ExplainValues(src, path)
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	}
+	configCmd.AddCommand(explainCmd)
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of values",
+		Long:  "Prints the JSON Schema of the values files, for editors and CI linters.\nThe same schema is enforced when values are loaded unless --skip-schema-validation is set.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := json.MarshalIndent(app2kube.ValuesSchema(), "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		},
+	})
+
 	configCmd.AddCommand(&cobra.Command{
 		Use:   "generate-keys",
 		Short: "Generate RSA keys",
@@ -71,56 +71,30 @@ func NewCmdDelete() *cobra.Command {
 		Short: "Delete resources from kubernetes",
 		Args:  deleteArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			o, err := deleteFlags.ToOptions(nil, ioStreams)
-			cmdutil.CheckErr(err)
-
-			app, err := opts.initApp(cmd.Context())
+			cmdutil.CheckErr(validateDeleteFlags(opts.includeNamespace, args))
+			renderer, err := opts.newPostRenderer()
 			cmdutil.CheckErr(err)
 
-			o.DryRunStrategy, err = cmdutil.GetDryRunStrategy(cmd)
+			apps, err := opts.initApps(cmd.Context())
 			cmdutil.CheckErr(err)
 
-			cmdutil.CheckErr(validateDeleteFlags(opts.includeNamespace, args))
-
-			var deleteManifest string
-			byManifest := false
-			if opts.includeNamespace && app.Namespace != "" {
-				args = []string{"namespace", app.Namespace}
-			} else if len(args) == 1 && args[0] == "all" {
-				// The kubectl resource list is derived per-app from the generator
-				// registry (output.go) so it cannot drift — notably it names
-				// poddisruptionbudgets (excluded by kubectl's "all" category) and
-				// includes the cert-manager Certificate only when this app uses
-				// letsencrypt, avoiding a reference to a CRD the cluster may lack.
-				args = []string{app.DeleteResourceTypes()}
-				o.LabelSelector, err = scopedSelector(app.Labels)
-				cmdutil.CheckErr(err)
-			} else if len(args) == 0 {
-				deleteManifest, err = app.GetManifest("json", app2kube.OutputAll)
-				cmdutil.CheckErr(err)
-				byManifest = true
+			// Applications sharing a namespace delete it once.
+			deleted := map[string]bool{}
+			for _, app := range apps {
+				if opts.includeNamespace && deleted[app.Namespace] {
+					continue
+				}
+				deleted[app.Namespace] = true
+				// kubectl reads the namespace from the flags.
+				*kubeConfigFlags.Namespace = app.Namespace
+				deleteApp(cmd, deleteFlags, opts, app, args, renderer)
 			}
-
-			cmdutil.CheckErr(o.Complete(kubeFactory, args, cmd))
-
-			// For delete-by-manifest, Complete built an (empty) Result from the
-			// FilenameOptions; replace it with one fed from an in-memory reader so
-			// RunDelete never reads os.Stdin. Complete still wired up the mapper,
-			// dynamic client and dry-run strategy that DeleteResult needs.
-			if byManifest {
-				cmdNamespace, enforceNamespace, err := kubeFactory.ToRawKubeConfigLoader().Namespace()
-				cmdutil.CheckErr(err)
-				r := streamDeleteResult(kubeFactory.NewBuilder(), cmdNamespace, enforceNamespace, deleteManifest)
-				cmdutil.CheckErr(r.Err())
-				o.Result = r
-			}
-
-			cmdutil.CheckErr(o.RunDelete(kubeFactory))
 		},
 	}
 
 	opts = addAppFlags(deleteCmd)
 	addBlueGreenFlag(deleteCmd, opts)
+	addPostRendererFlags(deleteCmd, opts)
 
 	deleteCmd.Flags().BoolVar(&flagAllInstances, "all-instances", false, "Delete all instances of application with the cmd 'delete all'")
 	deleteCmd.Flags().BoolVar(deleteFlags.IgnoreNotFound, "ignore-not-found", *deleteFlags.IgnoreNotFound, "Treat \"resource not found\" as a successful delete.")
@@ -129,3 +103,77 @@ func NewCmdDelete() *cobra.Command {
 
 	return deleteCmd
 }
+
+// deleteApp deletes the resources of one application: those of its manifest,
+// piped through renderer unless it is nil, or with "all" those its labels
+// select, or with --include-namespace its namespace.
+func deleteApp(cmd *cobra.Command, deleteFlags *delete.DeleteFlags, opts *appOptions, app *app2kube.App, args []string, renderer *app2kube.PostRenderer) {
+	o, err := deleteFlags.ToOptions(nil, ioStreams)
+	cmdutil.CheckErr(err)
+
+	o.DryRunStrategy, err = cmdutil.GetDryRunStrategy(cmd)
+	cmdutil.CheckErr(err)
+
+	var deleteManifest string
+	byManifest := false
+	if opts.includeNamespace && app.Namespace != "" {
+		args = []string{"namespace", app.Namespace}
+	} else if len(args) == 1 && args[0] == "all" {
+		// The kubectl resource list is derived per-app from the generator
+		// registry (output.go) so it cannot drift — notably it names
+		// poddisruptionbudgets (excluded by kubectl's "all" category) and
+		// includes the cert-manager Certificate only when this app uses
+		// letsencrypt, avoiding a reference to a CRD the cluster may lack.
+		args = []string{app.DeleteResourceTypes()}
+		o.LabelSelector, err = scopedSelector(app.Labels)
+		cmdutil.CheckErr(err)
+	} else if len(args) == 0 {
+		deleteManifest, err = renderManifest(app, renderer, "json", false, app2kube.OutputAll)
+		cmdutil.CheckErr(err)
+		byManifest = true
+	}
+
+	cmdutil.CheckErr(o.Complete(kubeFactory, args, cmd))
+
+	// For delete-by-manifest, Complete built an (empty) Result from the
+	// FilenameOptions; replace it with one fed from an in-memory reader so
+	// RunDelete never reads os.Stdin. Complete still wired up the mapper,
+	// dynamic client and dry-run strategy that DeleteResult needs.
+	if byManifest {
+		cmdNamespace, enforceNamespace, err := kubeFactory.ToRawKubeConfigLoader().Namespace()
+		cmdutil.CheckErr(err)
+		r := streamDeleteResult(kubeFactory.NewBuilder(), cmdNamespace, enforceNamespace, deleteManifest)
+		cmdutil.CheckErr(r.Err())
+		o.Result = r
+	}
+
+	// Pre-delete hooks run while the app and its config still exist. They
+	// are created directly rather than through kubectl, so a dry run
+	// skips them.
+	if app.HasHooks(app2kube.HookPreDelete) && o.DryRunStrategy == cmdutil.DryRunNone {
+		kcs, err := kubeFactory.KubernetesClientSet()
+		cmdutil.CheckErr(err)
+		cmdutil.CheckErr(runHooks(cmd.Context(), kcs, app, app2kube.HookPreDelete, renderer, trackHookJob(defaultTrackTimeout)))
+	}
+
+	cmdutil.CheckErr(o.RunDelete(kubeFactory))
+
+	// Deleting by label or by namespace never reaches the app's
+	// cluster-scoped RBAC; remove it by name. The manifest path already
+	// lists it.
+	if !byManifest && !flagAllApplications && o.DryRunStrategy == cmdutil.DryRunNone {
+		kcs, err := kubeFactory.KubernetesClientSet()
+		cmdutil.CheckErr(err)
+		cmdutil.CheckErr(deleteClusterRBAC(cmd.Context(), kcs, app))
+	}
+
+	// A live canary carries its own instance label, so neither the
+	// manifest nor the "all" selector reaches it.
+	if !opts.includeNamespace && !flagAllApplications && o.DryRunStrategy == cmdutil.DryRunNone {
+		if canary, err := app.CanaryApp(); err == nil {
+			kcs, err := kubeFactory.KubernetesClientSet()
+			cmdutil.CheckErr(err)
+			cmdutil.CheckErr(deleteCanary(cmd.Context(), kcs, canary))
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdExport return export command
+func NewCmdExport() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export an application for other deployment tools",
+	}
+
+	var (
+		helmOut   string
+		helmForce bool
+		helmOpts  *appOptions
+	)
+	helmCmd := &cobra.Command{
+		Use:   "helm",
+		Short: "Export an application as a Helm chart",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := helmOpts.appName()
+			if err != nil {
+				return err
+			}
+			app, rawVals, err := helmOpts.loadAppValues(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			// Like manifest, the default namespace is left to the context.
+			if app.Namespace == app2kube.NamespaceDefault {
+				app.Namespace = ""
+			}
+			files, err := app.HelmChart(rawVals)
+			if err != nil {
+				return err
+			}
+			if err := writeExport(helmOut, files, helmForce); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote the chart %s to %s\n", app.Name, helmOut)
+			return nil
+		},
+	}
+	helmCmd.Flags().StringVar(&helmOut, "out", "chart", "Directory to write the chart to")
+	helmCmd.Flags().BoolVar(&helmForce, "force", false, "Overwrite the files of a directory that is not empty")
+	helmOpts = addAppFlags(helmCmd)
+	_ = helmCmd.Flags().MarkHidden("include-namespace")
+	exportCmd.AddCommand(helmCmd)
+
+	var (
+		kustomizeOut   string
+		kustomizeForce bool
+		kustomizeOpts  *appOptions
+	)
+	kustomizeCmd := &cobra.Command{
+		Use:   "kustomize",
+		Short: "Export an application as a Kustomize base with an overlay per environment",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := kustomizeOpts.appName()
+			if err != nil {
+				return err
+			}
+			app, err := kustomizeOpts.loadApp(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
+			if app.Staging.Active {
+				return errors.New("the base is the production render, but the values configure staging: the environments are the overlays of " + overlayDir)
+			}
+			names, err := environments(overlayDir)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+
+			envs := make(map[string]*app2kube.App, len(names))
+			for _, env := range names {
+				// Each environment renders as staging of its name.
+				opts := *kustomizeOpts
+				opts.environment = env
+				opts.stringValues = append(slices.Clone(opts.stringValues), "staging="+env)
+				if envs[env], err = opts.loadApp(cmd.Context(), name); err != nil {
+					return fmt.Errorf("%s: %w", env, err)
+				}
+			}
+			// Like manifest, the default namespace is left to the context.
+			for _, a := range append([]*app2kube.App{app}, slices.Collect(maps.Values(envs))...) {
+				if a.Namespace == app2kube.NamespaceDefault {
+					a.Namespace = ""
+				}
+			}
+			files, err := app.Kustomization(envs)
+			if err != nil {
+				return err
+			}
+			if err := writeExport(kustomizeOut, files, kustomizeForce); err != nil {
+				return err
+			}
+			msg := "Wrote the Kustomize base of " + app.Name
+			if len(names) > 0 {
+				msg += " and the overlays " + strings.Join(names, ", ")
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s to %s\n", msg, kustomizeOut)
+			return nil
+		},
+	}
+	kustomizeCmd.Flags().StringVar(&kustomizeOut, "out", "deploy", "Directory to write the base and the overlays to")
+	kustomizeCmd.Flags().BoolVar(&kustomizeForce, "force", false, "Overwrite the files of a directory that is not empty")
+	kustomizeOpts = addAppFlags(kustomizeCmd)
+	_ = kustomizeCmd.Flags().MarkHidden("include-namespace")
+	_ = kustomizeCmd.Flags().MarkHidden("env")
+	exportCmd.AddCommand(kustomizeCmd)
+
+	return exportCmd
+}
+
+// writeExport writes files, by path relative to dir, to dir. A directory that
+// is not empty is only written with force, and then loses the directories the
+// files are written to, such as templates, so no file of an earlier export
+// lingers. The secret values of a Kustomize export are only readable by the
+// owner.
+func writeExport(dir string, files map[string][]byte, force bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		if !force {
+			return fmt.Errorf("%s is not empty: use --force to overwrite it", dir)
+		}
+		for path := range files {
+			if top, _, ok := strings.Cut(path, "/"); ok {
+				if err := os.RemoveAll(filepath.Join(dir, top)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for path, content := range files {
+		perm := os.FileMode(0o644)
+		if slices.Contains(strings.Split(path, "/"), app2kube.KustomizeSecretsDir) {
+			perm = 0o600
+		}
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// environments returns the environments of the overlays in dir, in name
+// order: the names of its .yml files but <environment>-*.yml, the overlays of
+// a branch, when <environment>.yml exists. Those of the branch of the values
+// are loaded with their environment.
+func environments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".yml"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	all := slices.Clone(names)
+	return slices.DeleteFunc(names, func(name string) bool {
+		return slices.ContainsFunc(all, func(env string) bool {
+			return strings.HasPrefix(name, env+"-")
+		})
+	}), nil
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportHelmCmd(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile(defaultFile, []byte(`name: shop
+common:
+  image:
+    repository: example/shop
+    tag: "1.2"
+deployment:
+  containers:
+    app: {}
+env:
+  MODE: prod
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := NewCmdExport()
+		var stderr bytes.Buffer
+		cmd.SetErr(&stderr)
+		cmd.SetArgs(append([]string{"helm"}, args...))
+		err := cmd.Execute()
+		return stderr.String(), err
+	}
+
+	out, err := run("--out", "chart")
+	if err != nil {
+		t.Fatalf("export helm: %v", err)
+	}
+	if want := "Wrote the chart shop to chart"; !strings.Contains(out, want) {
+		t.Errorf("output: no %q in\n%s", want, out)
+	}
+	for _, file := range []string{"Chart.yaml", "values.yaml", "templates/00-deployment-shop.yaml"} {
+		if _, err := os.Stat(filepath.Join("chart", file)); err != nil {
+			t.Errorf("chart: %v", err)
+		}
+	}
+
+	// A stale template goes with --force, and only with it.
+	stale := filepath.Join("chart", "templates", "99-service-old.yaml")
+	if err := os.WriteFile(stale, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("--out", "chart"); err == nil || !strings.Contains(err.Error(), "use --force") {
+		t.Errorf("expected an error for a directory that is not empty, got %v", err)
+	}
+	if _, err := run("--out", "chart", "--force"); err != nil {
+		t.Fatalf("export helm --force: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("the stale template is still there: %v", err)
+	}
+}
+
+func TestExportKustomizeCmd(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for path, content := range map[string]string{
+		defaultFile: `name: shop
+branch: feature/cart
+common:
+  image:
+    repository: example/shop
+    tag: "1.2"
+deployment:
+  containers:
+    app: {}
+secrets:
+  API_TOKEN: abc
+`,
+		filepath.Join(overlayDir, "qa.yml"):              "env:\n  MODE: qa\n",
+		filepath.Join(overlayDir, "qa-feature-cart.yml"): "env:\n  CART: \"1\"\n",
+		// The overlay of another branch is no environment of its own.
+		filepath.Join(overlayDir, "qa-main.yml"): "env:\n  MAIN: \"1\"\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := NewCmdExport()
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"kustomize", "--out", "deploy"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("export kustomize: %v", err)
+	}
+	if want := "Wrote the Kustomize base of shop and the overlays qa to deploy"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("output: no %q in\n%s", want, stderr.String())
+	}
+	for _, file := range []string{".gitignore", "base/kustomization.yaml", "overlays/qa/kustomization.yaml"} {
+		if _, err := os.Stat(filepath.Join("deploy", file)); err != nil {
+			t.Errorf("export: %v", err)
+		}
+	}
+	// The overlay of the branch is loaded with its environment.
+	patch, err := os.ReadFile(filepath.Join("deploy", "overlays", "qa", "patches", "deployment-shop.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"- name: CART", "value: qa"} {
+		if !strings.Contains(string(patch), want) {
+			t.Errorf("patch: no %q in\n%s", want, patch)
+		}
+	}
+	if strings.Contains(string(patch), "MAIN") {
+		t.Errorf("patch: the overlay of another branch is loaded:\n%s", patch)
+	}
+	info, err := os.Stat(filepath.Join("deploy", "base", "secrets", "shop", "API_TOKEN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("secret file mode %v, want 0600", perm)
+	}
+
+	cmd = NewCmdExport()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"kustomize", "--out", "other", "--set", "staging=qa"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "the values configure staging") {
+		t.Errorf("expected an error for staging values, got %v", err)
+	}
+}
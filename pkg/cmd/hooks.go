@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+// hookJobGoneTimeout bounds the wait for a previous run's hook Job to be
+// deleted before it is recreated.
+const hookJobGoneTimeout = 2 * time.Minute
+
+// hookWaiter waits until the named hook Job completes, returning an error when
+// it fails or times out.
+type hookWaiter func(ctx context.Context, name, namespace string) error
+
+// trackHookJob is the production hookWaiter: it tracks the Job with kubedog
+// until it succeeds, streaming its pod logs, and fails as soon as the Job does.
+func trackHookJob(timeout int) hookWaiter {
+	return func(ctx context.Context, name, namespace string) error {
+		return trackReady(ctx, []workload{{kind: app2kube.KindJob, name: name}}, namespace, timeout, time.Time{})
+	}
+}
+
+// runHooks runs the app's hook Jobs of one phase in order. Each Job replaces
+// the one left by a previous run (a Job's pod template is immutable), is waited
+// for, and is deleted once it succeeded. The first failure aborts the run and
+// keeps the failed Job so its pods and logs can be inspected; the next run
+// replaces it. Unless renderer is nil, the Jobs are piped through it first.
+func runHooks(ctx context.Context, kcs kubernetes.Interface, app *app2kube.App, phase app2kube.HookPhase, renderer *app2kube.PostRenderer, waitJob hookWaiter) error {
+	var (
+		jobs []*batchv1.Job
+		err  error
+	)
+	if renderer != nil {
+		jobs, err = app.PostRenderHookJobs(renderer, phase)
+	} else {
+		jobs, err = app.GetHookJobs(phase)
+	}
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		// Progress goes to stderr so piped output on stdout stays clean (#61).
+		fmt.Fprintf(os.Stderr, "• Running %s hook %s:\n", phase, job.Name)
+
+		if err := deleteHookJob(ctx, kcs, job.Name, job.Namespace, true); err != nil {
+			return fmt.Errorf("removing previous %s hook job %s: %w", phase, job.Name, err)
+		}
+		if _, err := kcs.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating %s hook job %s: %w", phase, job.Name, err)
+		}
+		if err := waitJob(ctx, job.Name, job.Namespace); err != nil {
+			return fmt.Errorf("%s hook %s failed (job kept for inspection): %w", phase, job.Name, err)
+		}
+		if err := deleteHookJob(ctx, kcs, job.Name, job.Namespace, false); err != nil {
+			return fmt.Errorf("cleaning up %s hook job %s: %w", phase, job.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteHookJob deletes a hook Job together with its pods. A NotFound is
+// ignored. With waitGone it also waits until the Job object is gone, so a Job
+// of the same name can be created right after.
+func deleteHookJob(ctx context.Context, kcs kubernetes.Interface, name, namespace string, waitGone bool) error {
+	err := kcs.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: ptr.To(metav1.DeletePropagationBackground),
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil || !waitGone {
+		return err
+	}
+	return wait.PollUntilContextTimeout(ctx, time.Second, hookJobGoneTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := kcs.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
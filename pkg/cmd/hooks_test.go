@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func hookTestApp() *app2kube.App {
+	app := app2kube.NewApp()
+	app.Name = "demo"
+	app.Namespace = "ns"
+	app.Hooks.PreApply = map[string]app2kube.HookSpec{
+		"migrate": {Container: apiv1.Container{Image: "demo:v2", Command: []string{"migrate"}}},
+	}
+	return app
+}
+
+// A succeeded hook replaces the previous run's Job and is cleaned up.
+func TestRunHooksReplacesAndCleansUp(t *testing.T) {
+	ctx := context.Background()
+	stale := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "demo-migrate", Namespace: "ns", Labels: map[string]string{"stale": "true"}}}
+	kcs := fake.NewSimpleClientset(stale)
+
+	var waited []string
+	err := runHooks(ctx, kcs, hookTestApp(), app2kube.HookPreApply, nil, func(ctx context.Context, name, namespace string) error {
+		job, err := kcs.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if job.Labels["stale"] != "" {
+			t.Error("the previous run's Job must be replaced, not reused")
+		}
+		waited = append(waited, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runHooks: %v", err)
+	}
+	if len(waited) != 1 || waited[0] != "demo-migrate" {
+		t.Errorf("expected to wait on demo-migrate, got %v", waited)
+	}
+	if _, err := kcs.BatchV1().Jobs("ns").Get(ctx, "demo-migrate", metav1.GetOptions{}); err == nil {
+		t.Error("a succeeded hook Job must be deleted")
+	}
+}
+
+// A failed hook aborts and keeps its Job for inspection.
+func TestRunHooksFailureKeepsJob(t *testing.T) {
+	ctx := context.Background()
+	kcs := fake.NewSimpleClientset()
+	err := runHooks(ctx, kcs, hookTestApp(), app2kube.HookPreApply, nil, func(context.Context, string, string) error {
+		return errors.New("BackoffLimitExceeded")
+	})
+	if err == nil {
+		t.Fatal("a failed hook must abort")
+	}
+	if _, err := kcs.BatchV1().Jobs("ns").Get(ctx, "demo-migrate", metav1.GetOptions{}); err != nil {
+		t.Errorf("the failed hook Job must be kept: %v", err)
+	}
+}
+
+// apply and delete run the hook Jobs the --post-renderer returns, such as
+// those of a rewritten image registry.
+func TestRunHooksPostRenderer(t *testing.T) {
+	renderer, err := app2kube.NewPostRenderer("sed", "s#image: demo:#image: registry.example.com/demo:#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := hookTestApp()
+	app.Hooks.PreDelete = app.Hooks.PreApply
+	for _, phase := range []app2kube.HookPhase{app2kube.HookPreApply, app2kube.HookPreDelete} {
+		ctx := context.Background()
+		kcs := fake.NewSimpleClientset()
+		err := runHooks(ctx, kcs, app, phase, renderer, func(ctx context.Context, name, namespace string) error {
+			job, err := kcs.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if image := job.Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/demo:v2" {
+				t.Errorf("%s: image %q, want the post-rendered one", phase, image)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: runHooks: %v", phase, err)
+		}
+	}
+}
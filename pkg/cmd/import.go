@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdImport return import command
+func NewCmdImport() *cobra.Command {
+	var (
+		files       []string
+		fromCluster []string
+		output      string
+		force       bool
+		check       bool
+	)
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import existing Kubernetes manifests into values",
+		Args:  cobra.NoArgs,
+	}
+
+	importCmd.Flags().StringArrayVarP(&files, "filename", "f", []string{}, "Manifest file or directory of manifests to import, - for stdin (can specify multiple)")
+	importCmd.Flags().StringArrayVar(&fromCluster, "from-cluster", []string{}, "Import an object of the cluster, such as deployment/foo (can specify multiple)")
+	importCmd.PersistentFlags().StringVarP(&output, "output", "o", defaultFile, "File to write the values to, - for stdout")
+	importCmd.PersistentFlags().BoolVar(&force, "force", false, "Overwrite an existing output file")
+	importCmd.Flags().BoolVar(&check, "check", false, "Render the imported values and show how the result differs from the manifests")
+
+	importCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(files) == 0 && len(fromCluster) == 0 {
+			return errors.New("-f or --from-cluster is required")
+		}
+		cmd.SilenceUsage = true
+
+		manifests, err := readManifests(files, cmd.InOrStdin())
+		if err != nil {
+			return err
+		}
+		if len(fromCluster) > 0 {
+			objects, err := clusterManifests(fromCluster)
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, objects...)
+		}
+
+		imported, err := app2kube.ImportManifests(manifests)
+		if err != nil {
+			return err
+		}
+		if err := writeImport(cmd, imported, output, force); err != nil {
+			return err
+		}
+
+		if check {
+			stderr := cmd.ErrOrStderr()
+			diff, err := imported.Check()
+			if err != nil {
+				return fmt.Errorf("check: %w", err)
+			}
+			if len(diff) == 0 {
+				fmt.Fprintln(stderr, "The values render the manifests unchanged")
+			} else {
+				fmt.Fprintln(stderr, "The values render the manifests with these differences:")
+				for _, line := range diff {
+					fmt.Fprintln(stderr, "  "+line)
+				}
+			}
+		}
+		return nil
+	}
+
+	importCmd.AddCommand(newCmdImportCompose(&output, &force))
+
+	return importCmd
+}
+
+// composeFiles are the names docker compose looks for, in its order.
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// newCmdImportCompose returns the import compose command, writing to the
+// output of the import command.
+func newCmdImportCompose(output *string, force *bool) *cobra.Command {
+	var (
+		containers bool
+		name       string
+	)
+
+	composeCmd := &cobra.Command{
+		Use:   "compose [FILE]",
+		Short: "Import the services of a docker-compose file into values",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			} else {
+				for _, file := range composeFiles {
+					if _, err := os.Stat(file); err == nil {
+						path = file
+						break
+					}
+				}
+				if path == "" {
+					return fmt.Errorf("no compose file: specify one or create one of %s", strings.Join(composeFiles, ", "))
+				}
+			}
+			cmd.SilenceUsage = true
+
+			compose, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			imported, err := app2kube.ImportCompose(compose, app2kube.ComposeOptions{
+				Dir:        filepath.Dir(path),
+				Name:       name,
+				Containers: containers,
+				Encrypt:    app2kube.NewApp().EncryptSecret,
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			return writeImport(cmd, imported, *output, *force)
+		},
+	}
+
+	composeCmd.Flags().BoolVar(&containers, "containers", false, "Make the services containers of one deployment instead of applications of their own")
+	composeCmd.Flags().StringVar(&name, "name", "", "Application name with --containers (default the compose project name)")
+
+	return composeCmd
+}
+
+// writeImport writes the imported values to output, or stdout for "-", and
+// lists what is not imported on stderr.
+func writeImport(cmd *cobra.Command, imported *app2kube.Import, output string, force bool) error {
+	values, err := imported.YAML()
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		if _, err := cmd.OutOrStdout().Write(values); err != nil {
+			return err
+		}
+	} else {
+		if _, err := os.Stat(output); err == nil && !force {
+			return fmt.Errorf("%s exists: use --force to overwrite it", output)
+		}
+		if err := os.WriteFile(output, values, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", output)
+	}
+
+	if len(imported.Problems) > 0 {
+		stderr := cmd.ErrOrStderr()
+		fmt.Fprintln(stderr, "Not imported:")
+		for _, problem := range imported.Problems {
+			fmt.Fprintln(stderr, "  "+problem)
+		}
+	}
+	return nil
+}
+
+// readManifests returns the documents of the files, of the .yaml, .yml and
+// .json files of the directories, in name order, and of stdin for "-".
+func readManifests(paths []string, stdin io.Reader) ([]byte, error) {
+	var manifests []byte
+	add := func(data []byte) {
+		// A separator before each file keeps the stream YAML, which reads
+		// JSON documents too.
+		manifests = append(manifests, "---\n"...)
+		manifests = append(manifests, data...)
+		manifests = append(manifests, '\n')
+	}
+	for _, path := range paths {
+		if path == "-" {
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, err
+			}
+			add(data)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			files = nil
+			for _, e := range entries {
+				if !e.IsDir() && slices.Contains([]string{".yaml", ".yml", ".json"}, filepath.Ext(e.Name())) {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no manifests in %s", path)
+			}
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			add(data)
+		}
+	}
+	return manifests, nil
+}
+
+// clusterManifests fetches objects such as deployment/foo from the namespace
+// of the current context, or --namespace, as JSON documents.
+func clusterManifests(refs []string) ([]byte, error) {
+	namespace, _, err := kubeFactory.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := kubeFactory.NewBuilder().
+		Unstructured().
+		NamespaceParam(namespace).DefaultNamespace().
+		ResourceTypeOrNameArgs(true, refs...).
+		Latest().
+		Flatten().
+		Do().
+		Infos()
+	if err != nil {
+		return nil, err
+	}
+	var manifests []byte
+	for _, info := range infos {
+		data, err := json.Marshal(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, "---\n"...)
+		manifests = append(manifests, data...)
+		manifests = append(manifests, '\n')
+	}
+	return manifests, nil
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readManifests reads the manifests of a directory in name order, JSON ones
+// included, after the files named before it and stdin.
+func TestReadManifests(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"b.yaml":    "kind: B\n",
+		"a.json":    `{"kind": "A"}`,
+		"notes.txt": "not a manifest",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := readManifests([]string{"-", dir}, strings.NewReader("kind: Stdin\n"))
+	if err != nil {
+		t.Fatalf("readManifests: %v", err)
+	}
+	want := "---\nkind: Stdin\n\n---\n{\"kind\": \"A\"}\n---\nkind: B\n\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := readManifests([]string{t.TempDir()}, nil); err == nil || !strings.Contains(err.Error(), "no manifests in") {
+		t.Errorf("expected an error for a directory without manifests, got %v", err)
+	}
+}
+
+func TestImportCmd(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "deployment.yaml")
+	if err := os.WriteFile(manifest, []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: app
+          image: example/web:v1
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "values.yml")
+
+	run := func(args ...string) (string, error) {
+		cmd := NewCmdImport()
+		var stderr bytes.Buffer
+		cmd.SetErr(&stderr)
+		cmd.SetOut(&stderr)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return stderr.String(), err
+	}
+
+	out, err := run("-f", manifest, "-o", output, "--check")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	values, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(values), "name: web") || !strings.Contains(string(values), "image: example/web:v1") {
+		t.Errorf("values:\n%s", values)
+	}
+	for _, want := range []string{"Wrote " + output, "Not imported:", "The values render the manifests with these differences:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output: no %q in\n%s", want, out)
+		}
+	}
+
+	// An existing file is only overwritten with --force.
+	if _, err := run("-f", manifest, "-o", output); err == nil || !strings.Contains(err.Error(), "use --force") {
+		t.Errorf("expected an error for an existing output file, got %v", err)
+	}
+	if _, err := run("-f", manifest, "-o", output, "--force"); err != nil {
+		t.Errorf("import --force: %v", err)
+	}
+	if _, err := run(); err == nil || !strings.Contains(err.Error(), "-f or --from-cluster is required") {
+		t.Errorf("expected an error without manifests, got %v", err)
+	}
+}
+
+func TestImportComposeCmd(t *testing.T) {
+	dir := t.TempDir()
+	compose := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(compose, []byte(`services:
+  web:
+    image: example/web:v1
+    ports: ["8080:80"]
+    depends_on: [cache]
+  cache:
+    image: redis:7
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewCmdImport()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"compose", compose, "--containers", "--name", "shop", "-o", "-"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("import compose: %v", err)
+	}
+	for _, want := range []string{"name: shop", "image: redis:7", "repository: example/web", "externalPort: 8080"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("values: no %q in\n%s", want, stdout.String())
+		}
+	}
+	if want := "services.web.depends_on: not imported"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("output: no %q in\n%s", want, stderr.String())
+	}
+
+	t.Chdir(t.TempDir())
+	cmd = NewCmdImport()
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"compose"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "no compose file") {
+		t.Errorf("expected an error without a compose file, got %v", err)
+	}
+}
@@ -3,12 +3,15 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
@@ -76,6 +79,41 @@ func scopedSelector(labels map[string]string) (string, error) {
 	return selector, nil
 }
 
+// appsSelector builds the prune selector of several applications: the labels
+// they share, with the application name matching any of theirs. It refuses
+// applications whose other labels differ: dropping those labels would widen
+// the selector to objects of none of them (another instance's, say). Each
+// application is held to scopedSelector's scope.
+func appsSelector(apps []*app2kube.App) (string, error) {
+	var names []string
+	for _, app := range apps {
+		if _, err := scopedSelector(app.Labels); err != nil {
+			return "", err
+		}
+		if a, b := unnamedLabels(apps[0].Labels), unnamedLabels(app.Labels); !maps.Equal(a, b) {
+			return "", fmt.Errorf("refusing to prune %s and %s with one selector: their labels differ (%s, %s)",
+				apps[0].Name, app.Name, labels.SelectorFromSet(a), labels.SelectorFromSet(b))
+		}
+		names = append(names, app.Labels[app2kube.LabelName])
+	}
+
+	shared := unnamedLabels(apps[0].Labels)
+	var selector []string
+	for _, k := range slices.Sorted(maps.Keys(shared)) {
+		selector = append(selector, k+"="+shared[k])
+	}
+	slices.Sort(names)
+	selector = append(selector, app2kube.LabelName+" in ("+strings.Join(slices.Compact(names), ",")+")")
+	return strings.Join(selector, ","), nil
+}
+
+// unnamedLabels returns the labels without the application name.
+func unnamedLabels(set map[string]string) map[string]string {
+	unnamed := maps.Clone(set)
+	delete(unnamed, app2kube.LabelName)
+	return unnamed
+}
+
 func deleteDeployment(ctx context.Context, name, namespace string) error {
 	kcs, err := kubeFactory.KubernetesClientSet()
 	if err != nil {
@@ -105,6 +143,62 @@ func prunePodDisruptionBudget(ctx context.Context, kcs kubernetes.Interface, nam
 	return err
 }
 
+// pruneHorizontalPodAutoscaler removes the per-color HorizontalPodAutoscaler
+// alongside its Deployment, like prunePodDisruptionBudget. An HPA is only
+// emitted with deployment.autoscaling.enabled, so a NotFound is ignored.
+func pruneHorizontalPodAutoscaler(ctx context.Context, kcs kubernetes.Interface, name, namespace string) error {
+	err := kcs.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// pruneNetworkPolicy removes the per-color NetworkPolicy alongside its
+// Deployment, like prunePodDisruptionBudget. A NetworkPolicy is only emitted
+// with networkPolicy.enabled, so a NotFound is ignored.
+func pruneNetworkPolicy(ctx context.Context, kcs kubernetes.Interface, name, namespace string) error {
+	err := kcs.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// deleteClusterRBAC removes the app's ClusterRoleBinding and ClusterRole by
+// their exact name. Cluster-scoped kinds are not in the prune/delete-all kind
+// lists (a label selector would reach the same app in other namespaces), so
+// `apply --prune` and `delete all` call this instead. An object of that name
+// without the app's labels belongs to something else and is kept. A NotFound
+// is ignored, as is a Forbidden for an app rendering no cluster RBAC: most
+// apps have none and their deployers may not read cluster-scoped objects.
+func deleteClusterRBAC(ctx context.Context, kcs kubernetes.Interface, app *app2kube.App) error {
+	name := app.GetClusterRBACName()
+	owned := labels.SelectorFromSet(app.Labels)
+	ignore := func(err error) error {
+		if apierrors.IsNotFound(err) || (apierrors.IsForbidden(err) && !app.UsesClusterRBAC()) {
+			return nil
+		}
+		return err
+	}
+
+	bindings := kcs.RbacV1().ClusterRoleBindings()
+	binding, err := bindings.Get(ctx, name, metav1.GetOptions{})
+	if err == nil && owned.Matches(labels.Set(binding.Labels)) {
+		err = bindings.Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	if err := ignore(err); err != nil {
+		return err
+	}
+
+	roles := kcs.RbacV1().ClusterRoles()
+	role, err := roles.Get(ctx, name, metav1.GetOptions{})
+	if err == nil && owned.Matches(labels.Set(role.Labels)) {
+		err = roles.Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	return ignore(err)
+}
+
 // preDeleteDeployment removes the stale target-color Deployment before a
 // blue/green rotation recreates it. A NotFound is the expected case — on the
 // first (zero) deploy of a color there is nothing to delete — and is ignored;
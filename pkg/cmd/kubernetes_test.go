@@ -5,8 +5,13 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/n0madic/app2kube/pkg/app2kube"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
@@ -74,3 +79,101 @@ func TestPrunePodDisruptionBudget(t *testing.T) {
 		t.Errorf("a real delete error must abort, not be swallowed")
 	}
 }
+
+// blue-green prune removes the per-color HPA with its Deployment; a missing one
+// (autoscaling disabled) is not an error.
+func TestPruneHorizontalPodAutoscaler(t *testing.T) {
+	ctx := context.Background()
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: "web-blue", Namespace: "prod"}}
+	kcs := fake.NewSimpleClientset(hpa)
+	if err := pruneHorizontalPodAutoscaler(ctx, kcs, "web-blue", "prod"); err != nil {
+		t.Errorf("deleting an existing HPA must succeed: %v", err)
+	}
+	if _, err := kcs.AutoscalingV2().HorizontalPodAutoscalers("prod").Get(ctx, "web-blue", metav1.GetOptions{}); err == nil {
+		t.Errorf("HPA must be gone after prune")
+	}
+
+	kcs = fake.NewSimpleClientset()
+	if err := pruneHorizontalPodAutoscaler(ctx, kcs, "web-blue", "prod"); err != nil {
+		t.Errorf("a missing HPA must be ignored, got %v", err)
+	}
+}
+
+func TestPruneNetworkPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	policy := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "web-blue", Namespace: "prod"}}
+	kcs := fake.NewSimpleClientset(policy)
+	if err := pruneNetworkPolicy(ctx, kcs, "web-blue", "prod"); err != nil {
+		t.Errorf("deleting an existing NetworkPolicy must succeed: %v", err)
+	}
+	if _, err := kcs.NetworkingV1().NetworkPolicies("prod").Get(ctx, "web-blue", metav1.GetOptions{}); err == nil {
+		t.Errorf("NetworkPolicy must be gone after prune")
+	}
+
+	kcs = fake.NewSimpleClientset()
+	if err := pruneNetworkPolicy(ctx, kcs, "web-blue", "prod"); err != nil {
+		t.Errorf("a missing NetworkPolicy must be ignored, got %v", err)
+	}
+}
+
+// The ClusterRole and its binding are deleted by exact name, never by label
+// selector: another namespace's app with the same labels must keep its own.
+// Only objects carrying the app's labels are deleted.
+func TestDeleteClusterRBAC(t *testing.T) {
+	ctx := context.Background()
+	app := app2kube.NewApp()
+	if _, err := app.LoadValues(nil, []string{"name=web", "namespace=prod"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	name := app.GetClusterRBACName()
+
+	kcs := fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: app.Labels}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: app.Labels}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "stage-web", Labels: app.Labels}},
+	)
+	if err := deleteClusterRBAC(ctx, kcs, app); err != nil {
+		t.Fatalf("deleting existing cluster RBAC must succeed: %v", err)
+	}
+	if _, err := kcs.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{}); err == nil {
+		t.Error("ClusterRole must be gone")
+	}
+	if _, err := kcs.RbacV1().ClusterRoleBindings().Get(ctx, name, metav1.GetOptions{}); err == nil {
+		t.Error("ClusterRoleBinding must be gone")
+	}
+	if _, err := kcs.RbacV1().ClusterRoles().Get(ctx, "stage-web", metav1.GetOptions{}); err != nil {
+		t.Errorf("another namespace's ClusterRole must be kept: %v", err)
+	}
+
+	if err := deleteClusterRBAC(ctx, kcs, app); err != nil {
+		t.Errorf("missing cluster RBAC must be ignored, got %v", err)
+	}
+
+	// An object of that name without the app's labels is not the app's.
+	kcs = fake.NewSimpleClientset(
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": "platform"}}},
+	)
+	if err := deleteClusterRBAC(ctx, kcs, app); err != nil {
+		t.Fatalf("deleteClusterRBAC: %v", err)
+	}
+	if _, err := kcs.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{}); err != nil {
+		t.Errorf("a foreign ClusterRole must be kept: %v", err)
+	}
+
+	// A deployer that may not read cluster-scoped objects has nothing to
+	// prune for an app without cluster RBAC, but must see the error for one
+	// with it.
+	kcs = fake.NewSimpleClientset()
+	kcs.PrependReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(rbacv1.Resource("clusterroles"), name, errors.New("denied"))
+	})
+	if err := deleteClusterRBAC(ctx, kcs, app); err != nil {
+		t.Errorf("a Forbidden must be ignored without cluster RBAC, got %v", err)
+	}
+	app.RBAC.ClusterRules = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}}}
+	if err := deleteClusterRBAC(ctx, kcs, app); !apierrors.IsForbidden(err) {
+		t.Errorf("a Forbidden must be returned with cluster RBAC, got %v", err)
+	}
+}
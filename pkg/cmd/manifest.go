@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
@@ -13,6 +17,9 @@ func NewCmdManifest() *cobra.Command {
 	var (
 		output     string
 		typeOutput []string
+		outputDir  string
+		index      string
+		pruneDir   bool
 	)
 
 	manifestCmd := &cobra.Command{
@@ -23,24 +30,52 @@ func NewCmdManifest() *cobra.Command {
 
 	manifestCmd.Flags().StringVarP(&output, "output", "o", "yaml", "Output format")
 	manifestCmd.Flags().StringArrayVar(&typeOutput, "type", []string{"all"}, "Types of output resources (several can be specified)")
+	manifestCmd.Flags().StringVar(&outputDir, "output-dir", "", "Write each object to <kind>-<name>.yaml in this directory instead of stdout")
+	manifestCmd.Flags().StringVar(&index, "index", string(app2kube.IndexKustomization), "Index of the files of --output-dir: kustomization, list or none")
+	manifestCmd.Flags().BoolVar(&pruneDir, "prune-output-dir", false, "Delete the other manifest files of --output-dir")
 	opts := addAppFlags(manifestCmd)
 	addBlueGreenFlag(manifestCmd, opts)
+	addPostRendererFlags(manifestCmd, opts)
 
 	manifestCmd.RunE = func(cmd *cobra.Command, args []string) error {
 		// Don't print full usage on runtime errors (only on arg-parse errors),
 		// matching the other subcommands; manifest output is piped to kubectl.
 		cmd.SilenceUsage = true
 
-		app, err := opts.initApp(cmd.Context())
+		if outputDir == "" && (pruneDir || cmd.Flags().Changed("index")) {
+			return errors.New("--index and --prune-output-dir require --output-dir")
+		}
+
+		renderer, err := opts.newPostRenderer()
 		if err != nil {
 			return err
 		}
-
-		out, err := buildManifest(app, typeOutput, output, opts.includeNamespace)
+		apps, err := opts.initApps(cmd.Context())
 		if err != nil {
 			return err
 		}
 
+		// Applications sharing a namespace render its manifest once.
+		var out string
+		namespaces := map[string]bool{}
+		for _, app := range apps {
+			manifest, err := buildManifest(app, typeOutput, output, opts.includeNamespace && !namespaces[app.Namespace], renderer)
+			if err != nil {
+				return err
+			}
+			namespaces[app.Namespace] = true
+			out += manifest
+		}
+
+		if outputDir != "" {
+			written, unchanged, pruned, err := writeManifestDir(outputDir, out, output, app2kube.ManifestIndex(index), pruneDir)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d written, %d unchanged, %d pruned\n", outputDir, written, unchanged, pruned)
+			return nil
+		}
+
 		fmt.Println(out)
 
 		return nil
@@ -67,9 +102,9 @@ func parseOutputTypes(types []string) ([]app2kube.OutputResource, error) {
 }
 
 // buildManifest renders the manifest string for the given app and selected
-// resource types. It is split out from manifest() so the rendering logic can be
-// tested without capturing stdout.
-func buildManifest(app *app2kube.App, types []string, outputFormat string, includeNamespace bool) (string, error) {
+// resource types, through renderer unless it is nil. It is split out from
+// manifest() so the rendering logic can be tested without capturing stdout.
+func buildManifest(app *app2kube.App, types []string, outputFormat string, includeNamespace bool, renderer *app2kube.PostRenderer) (string, error) {
 	if app.Namespace == app2kube.NamespaceDefault {
 		app.Namespace = ""
 	}
@@ -79,18 +114,117 @@ func buildManifest(app *app2kube.App, types []string, outputFormat string, inclu
 		return "", err
 	}
 
-	out, err := app.GetManifest(outputFormat, outputTypes...)
+	return renderManifest(app, renderer, outputFormat, app.Namespace != "" && includeNamespace, outputTypes...)
+}
+
+// addPostRendererFlags binds --post-renderer and --post-renderer-args to the
+// command's own appOptions.
+func addPostRendererFlags(cmd *cobra.Command, o *appOptions) {
+	cmd.Flags().StringVar(&o.postRenderer, "post-renderer", "", "Pipe the rendered manifests through this executable, which writes the objects to use to stdout")
+	cmd.Flags().StringArrayVar(&o.postRendererArgs, "post-renderer-args", []string{}, "Argument of the --post-renderer (can specify multiple)")
+}
+
+// newPostRenderer returns the executable of --post-renderer, or nil without
+// one.
+func (o *appOptions) newPostRenderer() (*app2kube.PostRenderer, error) {
+	if o.postRenderer == "" {
+		if len(o.postRendererArgs) > 0 {
+			return nil, errors.New("--post-renderer-args requires --post-renderer")
+		}
+		return nil, nil
+	}
+	return app2kube.NewPostRenderer(o.postRenderer, o.postRendererArgs...)
+}
+
+// renderManifest renders the output types of app in outputFormat, after its
+// Namespace when includeNamespace is set. Unless renderer is nil, the
+// manifest is rendered as YAML, which post-renderers read, piped through it
+// and printed back in outputFormat.
+func renderManifest(app *app2kube.App, renderer *app2kube.PostRenderer, outputFormat string, includeNamespace bool, output ...app2kube.OutputResource) (string, error) {
+	format := outputFormat
+	if renderer != nil {
+		format = "yaml"
+	}
+
+	manifest, err := app.GetManifest(format, output...)
 	if err != nil {
 		return "", err
 	}
 
-	if app.Namespace != "" && includeNamespace {
-		namespace, err := app.GetManifest(outputFormat, app2kube.OutputNamespace)
+	if includeNamespace {
+		namespace, err := app.GetManifest(format, app2kube.OutputNamespace)
 		if err != nil {
 			return "", err
 		}
-		out = namespace + out
+		manifest = namespace + manifest
 	}
 
-	return out, nil
+	if renderer == nil {
+		return manifest, nil
+	}
+	return app.PostRender(renderer, manifest, outputFormat)
+}
+
+// writeManifestDir writes each object of manifest, rendered in outputFormat,
+// to a file of dir, and the index of the files. A file already holding its
+// content is left as it is, so a GitOps repository only sees the objects that
+// changed. With prune, the other .yaml, .yml and .json files of dir are
+// deleted. It returns the number of files written, unchanged and pruned.
+func writeManifestDir(dir, manifest, outputFormat string, index app2kube.ManifestIndex, prune bool) (written, unchanged, pruned int, err error) {
+	files, err := app2kube.ManifestFiles(manifest, outputFormat)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	indexFile, err := index.Index(files)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if indexFile != nil {
+		files = append(files, *indexFile)
+	}
+	// Objects of several applications could land in the same file.
+	names := map[string]bool{}
+	for _, f := range files {
+		if names[f.Name] {
+			return 0, 0, 0, fmt.Errorf("two objects render %s", f.Name)
+		}
+		names[f.Name] = true
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, 0, 0, err
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name)
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, f.Content) {
+			unchanged++
+			continue
+		}
+		if err := os.WriteFile(path, f.Content, 0o644); err != nil {
+			return written, unchanged, pruned, err
+		}
+		written++
+	}
+
+	if prune {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return written, unchanged, pruned, err
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+			default:
+				continue
+			}
+			if entry.IsDir() || names[entry.Name()] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return written, unchanged, pruned, err
+			}
+			pruned++
+		}
+	}
+	return written, unchanged, pruned, nil
 }
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
 	apiv1 "k8s.io/api/core/v1"
@@ -66,7 +69,7 @@ func TestParseOutputTypes(t *testing.T) {
 
 func TestBuildManifestDeploymentOnly(t *testing.T) {
 	app := manifestTestApp(t)
-	out, err := buildManifest(app, []string{"deployment"}, "yaml", false)
+	out, err := buildManifest(app, []string{"deployment"}, "yaml", false, nil)
 	if err != nil {
 		t.Fatalf("buildManifest: %v", err)
 	}
@@ -80,7 +83,7 @@ func TestBuildManifestDeploymentOnly(t *testing.T) {
 
 func TestBuildManifestIncludeNamespace(t *testing.T) {
 	app := manifestTestApp(t)
-	out, err := buildManifest(app, []string{"deployment"}, "yaml", true)
+	out, err := buildManifest(app, []string{"deployment"}, "yaml", true, nil)
 	if err != nil {
 		t.Fatalf("buildManifest: %v", err)
 	}
@@ -96,7 +99,7 @@ func TestBuildManifestIncludeNamespace(t *testing.T) {
 func TestBuildManifestDefaultNamespaceOmitted(t *testing.T) {
 	app := manifestTestApp(t)
 	app.Namespace = app2kube.NamespaceDefault
-	out, err := buildManifest(app, []string{"deployment"}, "yaml", true)
+	out, err := buildManifest(app, []string{"deployment"}, "yaml", true, nil)
 	if err != nil {
 		t.Fatalf("buildManifest: %v", err)
 	}
@@ -105,3 +108,82 @@ func TestBuildManifestDefaultNamespaceOmitted(t *testing.T) {
 		t.Errorf("default namespace must not be emitted:\n%s", out)
 	}
 }
+
+func TestWriteManifestDir(t *testing.T) {
+	app := manifestTestApp(t)
+	manifest, err := buildManifest(app, []string{"configmap", "deployment"}, "yaml", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "service-old.yaml")
+	notes := filepath.Join(dir, "README.md")
+	for _, path := range []string{stale, notes} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(prune bool, wantWritten, wantUnchanged, wantPruned int) {
+		t.Helper()
+		written, unchanged, pruned, err := writeManifestDir(dir, manifest, "yaml", app2kube.IndexKustomization, prune)
+		if err != nil {
+			t.Fatalf("writeManifestDir: %v", err)
+		}
+		if written != wantWritten || unchanged != wantUnchanged || pruned != wantPruned {
+			t.Errorf("got %d written, %d unchanged, %d pruned, want %d, %d, %d",
+				written, unchanged, pruned, wantWritten, wantUnchanged, wantPruned)
+		}
+	}
+	check(false, 3, 0, 0)
+	index, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "resources:\n- configmap-example.yaml\n- deployment-example.yaml\n"; !strings.Contains(string(index), want) {
+		t.Errorf("kustomization.yaml: no %q in\n%s", want, index)
+	}
+
+	// Files holding their content are not rewritten.
+	configMap := filepath.Join(dir, "configmap-example.yaml")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(configMap, past, past); err != nil {
+		t.Fatal(err)
+	}
+	check(true, 0, 3, 1)
+	if info, err := os.Stat(configMap); err != nil || !info.ModTime().Equal(past) {
+		t.Errorf("configmap-example.yaml was rewritten: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("the stale manifest is still there: %v", err)
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Errorf("a file other than a manifest was pruned: %v", err)
+	}
+
+	if _, _, _, err := writeManifestDir(dir, manifest+manifest, "yaml", app2kube.IndexNone, false); err == nil || !strings.Contains(err.Error(), "two objects render") {
+		t.Errorf("expected an error for objects rendering the same file, got %v", err)
+	}
+}
+
+func TestBuildManifestPostRenderer(t *testing.T) {
+	app := manifestTestApp(t)
+	opts := &appOptions{postRenderer: "sed", postRendererArgs: []string{"s#example/app#registry.example.com/app#"}}
+	renderer, err := opts.newPostRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := buildManifest(app, []string{"deployment"}, "json", true, renderer)
+	if err != nil {
+		t.Fatalf("buildManifest: %v", err)
+	}
+	for _, want := range []string{"# Namespace: prod\n", `"image": "registry.example.com/app:v1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %q in\n%s", want, out)
+		}
+	}
+
+	if _, err := (&appOptions{postRendererArgs: []string{"-x"}}).newPostRenderer(); err == nil || !strings.Contains(err.Error(), "requires --post-renderer") {
+		t.Errorf("expected an error for --post-renderer-args alone, got %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/n0madic/app2kube/pkg/app2kube"
 	"github.com/spf13/cobra"
 )
 
@@ -15,14 +16,18 @@ var rootCmd = &cobra.Command{Use: "app2kube"}
 // Execute cmd
 func Execute(version string) error {
 	rootCmd.Version = version
+	app2kube.Version = version
 	rootCmd.Short = fmt.Sprintf("Kubernetes application deployment (app2kube %s)", rootCmd.Version)
 
 	rootCmd.AddCommand(NewCmdApply())
 	rootCmd.AddCommand(NewCmdBlueGreen())
 	rootCmd.AddCommand(NewCmdBuild())
+	rootCmd.AddCommand(NewCmdCanary())
 	rootCmd.AddCommand(NewCmdCompletion())
 	rootCmd.AddCommand(NewCmdConfig())
 	rootCmd.AddCommand(NewCmdDelete())
+	rootCmd.AddCommand(NewCmdExport())
+	rootCmd.AddCommand(NewCmdImport())
 	rootCmd.AddCommand(NewCmdManifest())
 	rootCmd.AddCommand(NewCmdStatus())
 	rootCmd.AddCommand(NewCmdTrack())
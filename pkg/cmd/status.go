@@ -63,14 +63,22 @@ func NewCmdStatus() *cobra.Command {
 		Short: "Show application resources status in kubernetes",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			app, err := opts.initApp(cmd.Context())
+			apps, err := opts.initApps(cmd.Context())
 			if err != nil {
 				return err
 			}
 
 			cmd.SilenceUsage = true
 
-			return status(cmd.Context(), app)
+			for i, app := range apps {
+				if i > 0 {
+					fmt.Println()
+				}
+				if err := status(cmd.Context(), app); err != nil {
+					return err
+				}
+			}
+			return nil
 		},
 	}
 
@@ -106,12 +114,26 @@ func status(ctx context.Context, app *app2kube.App) error {
 		{name: "Deployment", fn: func(ctx context.Context, kcs kubernetes.Interface, namespace string, labels map[string]string) (string, error) {
 			return getDeploymentStatus(ctx, kcs, namespace, labels, svc)
 		}},
+		{name: "StatefulSet", fn: getStatefulSetStatus},
+		{name: "HorizontalPodAutoscaler", fn: getHPAStatus},
 		{name: "Pod (related)", fn: getPodsStatus},
+	}
+	// The pods of a named workload carry their own instance label.
+	for _, wa := range app.WorkloadApps() {
+		podLabels := wa.GetColorLabels()
+		tables = append(tables, tableFunc{
+			name: "Pod (" + wa.Labels[app2kube.LabelComponent] + ")",
+			fn: func(ctx context.Context, kcs kubernetes.Interface, namespace string, _ map[string]string) (string, error) {
+				return getPodsStatus(ctx, kcs, namespace, podLabels)
+			},
+		})
+	}
+	tables = append(tables, []tableFunc{
 		{name: "Service", fn: func(ctx context.Context, kcs kubernetes.Interface, namespace string, labels map[string]string) (string, error) {
 			return getServicesStatus(ctx, kcs, namespace, labels, svc)
 		}},
 		{name: "Ingress", fn: getIngressStatus},
-	}
+	}...)
 
 	for _, res := range tables {
 		table, err := res.fn(ctx, kcs, app.Namespace, app.Labels)
@@ -309,6 +331,58 @@ func getDeploymentStatus(ctx context.Context, kcs kubernetes.Interface, namespac
 	}), nil
 }
 
+func getStatefulSetStatus(ctx context.Context, kcs kubernetes.Interface, namespace string, labels map[string]string) (string, error) {
+	list, err := kcs.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: getSelector(labels),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(list.Items) == 0 {
+		return "", nil
+	}
+	return renderTable([]string{"NAME", "READY", "UP-TO-DATE", "CURRENT-REVISION", "AGE"}, func(w io.Writer) {
+		for _, sts := range list.Items {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+				sts.Name,
+				fmt.Sprintf("%d/%d", sts.Status.ReadyReplicas, sts.Status.Replicas),
+				sts.Status.UpdatedReplicas,
+				sts.Status.CurrentRevision,
+				metatable.ConvertToHumanReadableDateType(sts.CreationTimestamp),
+			)
+		}
+	}), nil
+}
+
+func getHPAStatus(ctx context.Context, kcs kubernetes.Interface, namespace string, labels map[string]string) (string, error) {
+	list, err := kcs.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: getSelector(labels),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(list.Items) == 0 {
+		return "", nil
+	}
+	return renderTable([]string{"NAME", "REFERENCE", "MINPODS", "MAXPODS", "REPLICAS", "AGE"}, func(w io.Writer) {
+		for _, hpa := range list.Items {
+			minPods := int32(1)
+			if hpa.Spec.MinReplicas != nil {
+				minPods = *hpa.Spec.MinReplicas
+			}
+			fmt.Fprintf(w, "%s\t%s/%s\t%d\t%d\t%d\t%s\n",
+				hpa.Name,
+				hpa.Spec.ScaleTargetRef.Kind,
+				hpa.Spec.ScaleTargetRef.Name,
+				minPods,
+				hpa.Spec.MaxReplicas,
+				hpa.Status.CurrentReplicas,
+				metatable.ConvertToHumanReadableDateType(hpa.CreationTimestamp),
+			)
+		}
+	}), nil
+}
+
 func getPodsStatus(ctx context.Context, kcs kubernetes.Interface, namespace string, labels map[string]string) (string, error) {
 	list, err := kcs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: getSelector(labels),
@@ -7,6 +7,7 @@ import (
 
 	"github.com/n0madic/app2kube/pkg/app2kube"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
@@ -249,3 +250,37 @@ func TestGetIngressStatus(t *testing.T) {
 		t.Errorf("expected ingress name and host in output, got %q", out)
 	}
 }
+
+func TestGetStatefulSetStatus(t *testing.T) {
+	labels := statusLabels()
+	kcs := fake.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-sts", Namespace: "ns", Labels: labels},
+		Status:     appsv1.StatefulSetStatus{Replicas: 3, ReadyReplicas: 2, CurrentRevision: "demo-sts-abc"},
+	})
+	out, err := getStatefulSetStatus(context.Background(), kcs, "ns", labels)
+	if err != nil {
+		t.Fatalf("getStatefulSetStatus: %v", err)
+	}
+	if !strings.Contains(out, "demo-sts") || !strings.Contains(out, "2/3") {
+		t.Errorf("expected statefulset name and ready ratio 2/3 in output, got %q", out)
+	}
+}
+
+func TestGetHPAStatus(t *testing.T) {
+	labels := statusLabels()
+	kcs := fake.NewSimpleClientset(&autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "ns", Labels: labels},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "demo"},
+			MaxReplicas:    7,
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: 3},
+	})
+	out, err := getHPAStatus(context.Background(), kcs, "ns", labels)
+	if err != nil {
+		t.Fatalf("getHPAStatus: %v", err)
+	}
+	if !strings.Contains(out, "Deployment/demo") || !strings.Contains(out, "7") {
+		t.Errorf("expected target reference and max replicas in output, got %q", out)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/n0madic/app2kube/pkg/app2kube"
 	"github.com/spf13/cobra"
 
 	"github.com/werf/kubedog/pkg/kube"
@@ -57,15 +59,15 @@ func NewCmdTrack() *cobra.Command {
 
 	// addTrackSub wires a track subcommand with its own appOptions so no state
 	// is shared between commands. run receives the cancellable command context,
-	// the resolved deployment name/namespace, the timeout and the log start time
-	// computed at execution time (trackFollow/trackReady match this signature).
-	addTrackSub := func(use, short string, run func(ctx context.Context, name, namespace string, timeout int, logsFrom time.Time) error) {
+	// the app's workloads and namespace, the timeout and the log start time
+	// computed at execution time, once per application in turn.
+	addTrackSub := func(use, short string, run func(ctx context.Context, workloads []workload, namespace string, timeout int, logsFrom time.Time) error) {
 		c := &cobra.Command{Use: use, Short: short, Args: cobra.NoArgs}
 		opts := addAppFlags(c)
 		addBlueGreenFlag(c, opts)
 		_ = c.Flags().MarkHidden("include-namespace")
 		c.RunE = func(cmd *cobra.Command, args []string) error {
-			app, err := opts.initApp(cmd.Context())
+			apps, err := opts.initApps(cmd.Context())
 			if err != nil {
 				return err
 			}
@@ -74,7 +76,15 @@ func NewCmdTrack() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			return run(cmd.Context(), app.GetDeploymentName(), app.Namespace, trackTimeout, logsFrom)
+			for _, app := range apps {
+				if err := run(cmd.Context(), appWorkloads(app), app.Namespace, trackTimeout, logsFrom); err != nil {
+					if len(apps) > 1 {
+						err = fmt.Errorf("%s: %w", app.Name, err)
+					}
+					return err
+				}
+			}
+			return nil
 		}
 		trackCmd.AddCommand(c)
 	}
@@ -85,7 +95,36 @@ func NewCmdTrack() *cobra.Command {
 	return trackCmd
 }
 
-func kubedogInit() error {
+// workload identifies one app workload for kubedog: its kind
+// (app2kube.KindDeployment, app2kube.KindStatefulSet or, for hook Jobs,
+// app2kube.KindJob) and object name.
+type workload struct {
+	kind string
+	name string
+}
+
+// appWorkloads returns the workloads the app renders, so tracking follows a
+// StatefulSet app with the StatefulSet tracker instead of waiting on a
+// Deployment that does not exist, and follows every named workload along with
+// the main one.
+func appWorkloads(app *app2kube.App) []workload {
+	var workloads []workload
+	if len(app.Deployment.Containers) > 0 {
+		kind := app2kube.KindDeployment
+		if app.IsStatefulSet() {
+			kind = app2kube.KindStatefulSet
+		}
+		workloads = append(workloads, workload{kind: kind, name: app.GetDeploymentName()})
+	}
+	for _, wa := range app.WorkloadApps() {
+		workloads = append(workloads, workload{kind: app2kube.KindDeployment, name: wa.GetDeploymentName()})
+	}
+	return workloads
+}
+
+// kubedogInit initializes kubedog's global client once: applications applied
+// in parallel track concurrently.
+var kubedogInit = sync.OnceValue(func() error {
 	var kubeConfigPathMergeList []string
 	if v := os.Getenv("KUBECONFIG"); v != "" {
 		kubeConfigPathMergeList = append(kubeConfigPathMergeList, filepath.SplitList(v)...)
@@ -95,39 +134,64 @@ func kubedogInit() error {
 		ConfigPath:          *kubeConfigFlags.KubeConfig,
 		ConfigPathMergeList: kubeConfigPathMergeList,
 	}})
-}
+})
 
-func trackFollow(ctx context.Context, name, namespace string, timeout int, logsFrom time.Time) error {
+// trackFollow follows the rollout and logs of each workload in turn; kubedog's
+// follow trackers watch a single resource each.
+func trackFollow(ctx context.Context, workloads []workload, namespace string, timeout int, logsFrom time.Time) error {
 	err := kubedogInit()
 	if err != nil {
 		return fmt.Errorf("unable to initialize kubedog: %w", err)
 	}
 
-	return follow.TrackDeployment(
-		name,
-		namespace,
-		kube.Kubernetes,
-		tracker.Options{
-			ParentContext: ctx,
-			LogsFromTime:  logsFrom,
-			Timeout:       time.Minute * time.Duration(timeout),
-		},
-	)
+	opts := tracker.Options{
+		ParentContext: ctx,
+		LogsFromTime:  logsFrom,
+		Timeout:       time.Minute * time.Duration(timeout),
+	}
+	for _, w := range workloads {
+		switch w.kind {
+		case app2kube.KindStatefulSet:
+			err = follow.TrackStatefulSet(w.name, namespace, kube.Kubernetes, opts)
+		default:
+			err = follow.TrackDeployment(w.name, namespace, kube.Kubernetes, opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func trackReady(ctx context.Context, name, namespace string, timeout int, logsFrom time.Time) error {
+// multitrackSpecs sorts the workloads into kubedog's per-kind spec lists, each
+// tracked until ready (for a Job: until it succeeded).
+func multitrackSpecs(workloads []workload, namespace string) multitrack.MultitrackSpecs {
+	var specs multitrack.MultitrackSpecs
+	for _, w := range workloads {
+		spec := multitrack.MultitrackSpec{
+			ResourceName:         w.name,
+			Namespace:            namespace,
+			TrackTerminationMode: multitrack.WaitUntilResourceReady,
+		}
+		switch w.kind {
+		case app2kube.KindStatefulSet:
+			specs.StatefulSets = append(specs.StatefulSets, spec)
+		case app2kube.KindJob:
+			specs.Jobs = append(specs.Jobs, spec)
+		default:
+			specs.Deployments = append(specs.Deployments, spec)
+		}
+	}
+	return specs
+}
+
+func trackReady(ctx context.Context, workloads []workload, namespace string, timeout int, logsFrom time.Time) error {
 	err := kubedogInit()
 	if err != nil {
 		return fmt.Errorf("unable to initialize kubedog: %w", err)
 	}
 
-	return multitrack.Multitrack(kube.Kubernetes, multitrack.MultitrackSpecs{
-		Deployments: []multitrack.MultitrackSpec{{
-			ResourceName:         name,
-			Namespace:            namespace,
-			TrackTerminationMode: multitrack.WaitUntilResourceReady,
-		}},
-	}, multitrack.MultitrackOptions{
+	return multitrack.Multitrack(kube.Kubernetes, multitrackSpecs(workloads, namespace), multitrack.MultitrackOptions{
 		Options: tracker.Options{
 			ParentContext: ctx,
 			LogsFromTime:  logsFrom,
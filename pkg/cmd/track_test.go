@@ -3,6 +3,9 @@ package cmd
 import (
 	"testing"
 	"time"
+
+	"github.com/n0madic/app2kube/pkg/app2kube"
+	apiv1 "k8s.io/api/core/v1"
 )
 
 // Regression (#32): the "logs since" start time must be computed at command
@@ -25,3 +28,50 @@ func TestResolveLogsFrom(t *testing.T) {
 		t.Errorf("invalid duration must return an error, got nil")
 	}
 }
+
+// A StatefulSet app must be tracked with kubedog's StatefulSet tracker, not as
+// a Deployment that does not exist.
+func TestMultitrackSpecsByKind(t *testing.T) {
+	app := app2kube.NewApp()
+	app.Name = "demo"
+	app.Deployment.Containers = map[string]apiv1.Container{"app": {Image: "demo:v1"}}
+
+	specs := multitrackSpecs(appWorkloads(app), "ns")
+	if len(specs.Deployments) != 1 || len(specs.StatefulSets) != 0 {
+		t.Errorf("deployment app: got %d deployments, %d statefulsets", len(specs.Deployments), len(specs.StatefulSets))
+	}
+
+	app.Deployment.Kind = app2kube.KindStatefulSet
+	specs = multitrackSpecs(appWorkloads(app), "ns")
+	if len(specs.StatefulSets) != 1 || len(specs.Deployments) != 0 {
+		t.Fatalf("statefulset app: got %d deployments, %d statefulsets", len(specs.Deployments), len(specs.StatefulSets))
+	}
+	if specs.StatefulSets[0].ResourceName != "demo" || specs.StatefulSets[0].Namespace != "ns" {
+		t.Errorf("statefulset spec: got %+v", specs.StatefulSets[0])
+	}
+}
+
+func TestMultitrackSpecsJob(t *testing.T) {
+	specs := multitrackSpecs([]workload{{kind: app2kube.KindJob, name: "demo-migrate"}}, "ns")
+	if len(specs.Jobs) != 1 || len(specs.Deployments) != 0 || specs.Jobs[0].ResourceName != "demo-migrate" {
+		t.Errorf("hook Job must be tracked as a Job: %+v", specs)
+	}
+}
+
+// `apply --track` follows every named workload along with the main one.
+func TestAppWorkloadsIncludesNamedWorkloads(t *testing.T) {
+	app := app2kube.NewApp()
+	app.Name = "demo"
+	app.Deployment.Containers = map[string]apiv1.Container{"web": {Image: "demo:v1"}}
+	app.Workloads = map[string]app2kube.WorkloadSpec{
+		"worker": {DeploymentSpec: app2kube.DeploymentSpec{Containers: map[string]apiv1.Container{"worker": {Image: "demo:v1"}}}},
+	}
+
+	specs := multitrackSpecs(appWorkloads(app), "ns")
+	if len(specs.Deployments) != 2 {
+		t.Fatalf("expected the main and the worker Deployment, got %+v", specs.Deployments)
+	}
+	if specs.Deployments[0].ResourceName != "demo" || specs.Deployments[1].ResourceName != "demo-worker" {
+		t.Errorf("tracked deployments: got %s, %s", specs.Deployments[0].ResourceName, specs.Deployments[1].ResourceName)
+	}
+}